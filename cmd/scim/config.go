@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
+	"github.com/imulab/go-scim/pkg/protocol/handler"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	schemasDir                = "schemas"
+	resourceTypesDir          = "resource_types"
+	serviceProviderConfigFile = "service_provider_config.json"
+	policyFile                = "policy.json"
+)
+
+// Server configuration loaded from the configuration directory. The directory is expected to have the layout:
+//
+//	<dir>/service_provider_config.json
+//	<dir>/schemas/*.json
+//	<dir>/resource_types/*.json
+//	<dir>/policy.json (optional)
+//
+// Schemas are loaded first and registered in spec.SchemaHub, so that resource types can refer to them by id. The
+// discovery schemas and resource types are registered as well. When the authorization policy is absent, authenticated
+// principals may read and write all attributes.
+type config struct {
+	serviceProviderConfig *spec.ServiceProviderConfig
+	schemas               []*spec.Schema
+	resourceTypes         []*spec.ResourceType
+	policy                *auth.Policy
+}
+
+// Load the configuration from the given directory, or return any error.
+func loadConfig(dir string) (*config, error) {
+	cfg := new(config)
+
+	cfg.serviceProviderConfig = new(spec.ServiceProviderConfig)
+	if err := readJSON(filepath.Join(dir, serviceProviderConfigFile), cfg.serviceProviderConfig); err != nil {
+		return nil, err
+	}
+
+	schemaFiles, err := listJSON(filepath.Join(dir, schemasDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range schemaFiles {
+		sch := new(spec.Schema)
+		if err := readJSON(f, sch); err != nil {
+			return nil, err
+		} else if len(sch.ID()) == 0 {
+			return nil, fmt.Errorf("schema in '%s' has no id", f)
+		}
+		spec.SchemaHub.Put(sch)
+		cfg.schemas = append(cfg.schemas, sch)
+	}
+	handler.RegisterDiscovery()
+
+	resourceTypeFiles, err := listJSON(filepath.Join(dir, resourceTypesDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range resourceTypeFiles {
+		rt, err := readResourceType(f)
+		if err != nil {
+			return nil, err
+		}
+		expr.Register(rt)
+		cfg.resourceTypes = append(cfg.resourceTypes, rt)
+	}
+	if len(cfg.resourceTypes) == 0 {
+		return nil, fmt.Errorf("no resource type is defined in '%s'", filepath.Join(dir, resourceTypesDir))
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, policyFile)); err == nil {
+		cfg.policy = new(auth.Policy)
+		if err := readJSON(filepath.Join(dir, policyFile), cfg.policy); err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// Read resource type from file. Resource type unmarshaling panics when a referenced schema is not registered, this
+// function turns it into an error.
+func readResourceType(file string) (rt *spec.ResourceType, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			rt = nil
+			err = fmt.Errorf("failed to load resource type from '%s': %v", file, r)
+		}
+	}()
+
+	rt = new(spec.ResourceType)
+	if err = readJSON(file, rt); err != nil {
+		return
+	}
+	return
+}
+
+// List all JSON files in the directory, sorted by name.
+func listJSON(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readJSON(file string, v interface{}) error {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("failed to parse '%s': %s", file, err.Error())
+	}
+	return nil
+}
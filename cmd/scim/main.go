@@ -0,0 +1,112 @@
+// Command scim starts a SCIM server assembled from the schemas, resource types and service provider config in a
+// configuration directory. Resources are kept in memory, unless a data directory is given to persist them in files.
+//
+// Usage:
+//
+//	scim -config ./cmd/scim/config -address :8080 -bearer-tokens s3cret=admin -jwt-secret changeit -data ./data
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	var (
+		configDir       = flag.String("config", "./config", "directory containing schemas, resource types and service provider config")
+		address         = flag.String("address", ":8080", "address for the server to listen on")
+		bcryptCost      = flag.Int("bcrypt-cost", 10, "cost of the bcrypt algorithm used to hash passwords")
+		shutdownTimeout = flag.Duration("shutdown-timeout", 10*time.Second, "time to wait for in-flight requests on shutdown")
+		bearerTokens    = flag.String("bearer-tokens", "", "comma separated static bearer tokens in the form of token=subject")
+		jwtSecret       = flag.String("jwt-secret", "", "secret to verify HMAC signed JWT bearer tokens")
+		dataDir         = flag.String("data", "", "directory to persist resources in; resources are kept in memory if empty")
+	)
+	flag.Parse()
+
+	logger := log.Default()
+
+	cfg, err := loadConfig(*configDir)
+	if err != nil {
+		logger.Fatal("failed to load configuration: %s", err.Error())
+		return
+	}
+
+	tokens, err := parseBearerTokens(*bearerTokens)
+	if err != nil {
+		logger.Fatal("invalid bearer tokens: %s", err.Error())
+		return
+	}
+
+	opts := &options{
+		bcryptCost:   *bcryptCost,
+		bearerTokens: tokens,
+		jwtSecret:    []byte(*jwtSecret),
+	}
+	if len(*dataDir) > 0 {
+		databases, closeDatabases, err := openDatabases(cfg, *dataDir)
+		if err != nil {
+			logger.Fatal("failed to open databases: %s", err.Error())
+			return
+		}
+		defer func() {
+			if err := closeDatabases(); err != nil {
+				logger.Error("failed to close databases: %s", err.Error())
+			}
+		}()
+		opts.databases = databases
+	}
+
+	router, err := newRouter(cfg, logger, opts)
+	if err != nil {
+		logger.Fatal("failed to assemble server: %s", err.Error())
+		return
+	}
+
+	server := &http.Server{
+		Addr:    *address,
+		Handler: router,
+	}
+
+	go func() {
+		logger.Info("server listening on %s", *address)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("server stopped unexpectedly: %s", err.Error())
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+
+	logger.Info("shutting down server")
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error("server did not shutdown gracefully: %s", err.Error())
+	}
+}
+
+// Parse the comma separated token=subject pairs into a map from token to subject.
+func parseBearerTokens(value string) (map[string]string, error) {
+	tokens := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if len(pair) == 0 {
+			continue
+		}
+		i := strings.IndexByte(pair, '=')
+		if i <= 0 || i == len(pair)-1 {
+			return nil, fmt.Errorf("'%s' is not in the form of token=subject", pair)
+		}
+		tokens[pair[:i]] = pair[i+1:]
+	}
+	return tokens, nil
+}
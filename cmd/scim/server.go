@@ -0,0 +1,354 @@
+package main
+
+import (
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
+	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/handler"
+	scimHTTP "github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/imulab/go-scim/pkg/protocol/services"
+	"github.com/imulab/go-scim/pkg/protocol/services/filter"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	resourceIDPathParam = "id"
+	schemaIDPathParam   = "id"
+	resourceTypeParam   = "name"
+	searchSuffix        = "/.search"
+	// Resources of this resource type are served at /Me, if the authenticated subject matches their userName.
+	meResourceTypeID = "User"
+	// Maximum time to wait for another update to the same resource to finish.
+	lockTimeout = 10 * time.Second
+)
+
+// Options of the server which are not part of the configuration directory.
+type options struct {
+	// Cost of the bcrypt algorithm used to hash passwords.
+	bcryptCost int
+	// Static bearer tokens, mapped to the subject they authenticate.
+	bearerTokens map[string]string
+	// Secret to verify HMAC signed JWT bearer tokens.
+	jwtSecret []byte
+	// Databases of the resource types, by resource type id. Resources are kept in memory if not provided.
+	databases map[string]db.DB
+}
+
+// Create a router that serves the discovery endpoints, the resource endpoints of every configured resource type, the
+// bulk endpoint, and the root query endpoints. Each resource type is backed by its own database. All endpoints other
+// than the discovery endpoints require authentication, if any authentication scheme is enabled.
+func newRouter(cfg *config, logger log.Logger, opts *options) (*scimHTTP.Router, error) {
+	router := scimHTTP.NewRouter()
+
+	databases := opts.databases
+	if databases == nil {
+		databases = make(map[string]db.DB)
+		for _, rt := range cfg.resourceTypes {
+			database, err := db.IndexedMemory(rt)
+			if err != nil {
+				return nil, err
+			}
+			databases[rt.ID()] = database
+		}
+	}
+	authenticate := newAuthentication(cfg, opts, databases[meResourceTypeID], logger)
+
+	spcHandler := &handler.ServiceProviderConfig{
+		Log: logger,
+		SPC: cfg.serviceProviderConfig,
+	}
+	if err := router.Handle(http.MethodGet, "/ServiceProviderConfig", spcHandler.Handle); err != nil {
+		return nil, err
+	}
+
+	schemasHandler := &handler.Schemas{
+		Log:               logger,
+		SchemaIDPathParam: schemaIDPathParam,
+		Location:          serverURL(cfg) + "/Schemas",
+	}
+	if err := router.Handle(http.MethodGet, "/Schemas", schemasHandler.Handle); err != nil {
+		return nil, err
+	}
+	if err := router.Handle(http.MethodGet, "/Schemas/{"+schemaIDPathParam+"}", schemasHandler.Handle); err != nil {
+		return nil, err
+	}
+
+	resourceTypesHandler := &handler.ResourceTypes{
+		Log:                   logger,
+		ResourceTypes:         cfg.resourceTypes,
+		ResourceTypePathParam: resourceTypeParam,
+		Location:              serverURL(cfg) + "/ResourceTypes",
+	}
+	if err := router.Handle(http.MethodGet, "/ResourceTypes", resourceTypesHandler.Handle); err != nil {
+		return nil, err
+	}
+	if err := router.Handle(http.MethodGet, "/ResourceTypes/{"+resourceTypeParam+"}", resourceTypesHandler.Handle); err != nil {
+		return nil, err
+	}
+
+	bulkService := &services.BulkService{
+		Logger:                logger,
+		ServiceProviderConfig: cfg.serviceProviderConfig,
+	}
+	rootQueryService := &services.QueryService{
+		Logger:                logger,
+		ServiceProviderConfig: cfg.serviceProviderConfig,
+	}
+	meHandler := &handler.Me{Log: logger}
+	locks := lock.Default(lockTimeout)
+	for _, rt := range cfg.resourceTypes {
+		m, err := mountResourceType(router, rt, cfg, databases[rt.ID()], locks, logger, opts.bcryptCost, authenticate)
+		if err != nil {
+			return nil, err
+		}
+		bulkService.Endpoints = append(bulkService.Endpoints, m.bulk)
+		rootQueryService.Databases = append(rootQueryService.Databases, m.database)
+		if rt.ID() == meResourceTypeID {
+			meHandler.Resolver = handler.MeByUserName(m.database)
+			meHandler.Get, meHandler.Replace, meHandler.Patch, meHandler.Delete = m.get, m.replace, m.patch, m.delete
+		}
+	}
+
+	bulkHandler := &handler.Bulk{Log: logger, Service: bulkService}
+	if err := router.Handle(http.MethodPost, "/Bulk", authenticate(bulkHandler.Handle)); err != nil {
+		return nil, err
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if err := router.Handle(method, "/Me", authenticate(meHandler.Handle)); err != nil {
+			return nil, err
+		}
+	}
+
+	rootQueryHandler := &handler.Query{Log: logger, Service: rootQueryService, Policy: cfg.policy, ResourceTypes: cfg.resourceTypes, Stream: true}
+	if err := router.Handle(http.MethodGet, "/", authenticate(rootQueryHandler.Handle)); err != nil {
+		return nil, err
+	}
+	if err := router.Handle(http.MethodPost, searchSuffix, authenticate(rootQueryHandler.Handle)); err != nil {
+		return nil, err
+	}
+
+	return router, nil
+}
+
+// Open a file database for each resource type, in a sub directory of the data directory named after the resource type
+// id. The returned function closes all of them.
+func openDatabases(cfg *config, dataDir string) (map[string]db.DB, func() error, error) {
+	var (
+		databases = make(map[string]db.DB)
+		opened    = make([]db.FileDB, 0, len(cfg.resourceTypes))
+		closeAll  = func() error {
+			var err error
+			for _, f := range opened {
+				if e := f.Close(); e != nil && err == nil {
+					err = e
+				}
+			}
+			return err
+		}
+	)
+	for _, rt := range cfg.resourceTypes {
+		f, err := db.File(filepath.Join(dataDir, rt.ID()), rt, nil)
+		if err != nil {
+			_ = closeAll()
+			return nil, nil, err
+		}
+		opened = append(opened, f)
+		databases[rt.ID()] = f
+	}
+	return databases, closeAll, nil
+}
+
+// Returns a function which wraps handlers to require authentication with the authentication schemes advertised in the
+// service provider config: httpbasic is verified against the passwords of users, and oauthbearertoken is verified
+// against the static bearer tokens and the JWT secret in options. When no scheme is enabled, handlers are not wrapped.
+func newAuthentication(cfg *config, opts *options, userDatabase db.DB, logger log.Logger) func(fn handler.Func) handler.Func {
+	authenticator := &auth.Authenticator{Log: logger}
+	for _, scheme := range cfg.serviceProviderConfig.AuthSchemes {
+		switch strings.ToLower(scheme.Type) {
+		case auth.TypeHTTPBasic:
+			if userDatabase != nil {
+				authenticator.Verifiers = append(authenticator.Verifiers, auth.Basic(userDatabase))
+			}
+		case auth.TypeOAuthBearerToken:
+			if len(opts.bearerTokens) > 0 {
+				authenticator.Verifiers = append(authenticator.Verifiers, auth.StaticBearer(opts.bearerTokens))
+			}
+			if len(opts.jwtSecret) > 0 {
+				authenticator.Verifiers = append(authenticator.Verifiers, auth.HMACJWT(opts.jwtSecret))
+			}
+		}
+	}
+
+	if len(authenticator.Verifiers) == 0 {
+		logger.Warning("no authentication scheme is enabled, endpoints are not protected")
+		return func(fn handler.Func) handler.Func {
+			return fn
+		}
+	}
+	return func(fn handler.Func) handler.Func {
+		return authenticator.Wrap(fn)
+	}
+}
+
+// Database, services and handlers assembled for a resource type, which are shared with the bulk, /Me and root query
+// endpoints.
+type mountedResourceType struct {
+	database db.DB
+	bulk     *services.BulkEndpoint
+	get      *handler.Get
+	replace  *handler.Replace
+	patch    *handler.Patch
+	delete   *handler.Delete
+}
+
+// Assemble the standard services and handlers for the resource type, and mount them at the path of the resource
+// type's endpoint. The collection path serves create and query; the '.search' path serves query via POST; and the
+// resource path serves get, replace, patch and delete. Reads and writes are authorized by the policy, if configured.
+// Replace, patch and delete of the same resource are serialized by the locks.
+func mountResourceType(router *scimHTTP.Router, rt *spec.ResourceType, cfg *config,
+	database db.DB, locks lock.Lock, logger log.Logger, bcryptCost int, authenticate func(fn handler.Func) handler.Func) (*mountedResourceType, error) {
+	base, err := endpointPath(rt)
+	if err != nil {
+		return nil, err
+	}
+
+	spc := cfg.serviceProviderConfig
+	endpoint := &services.BulkEndpoint{
+		ResourceType: rt,
+		Create:       newCreateService(rt, cfg.policy, database, logger, bcryptCost),
+		Replace:      newReplaceService(rt, spc, cfg.policy, database, logger, bcryptCost),
+		Patch:        newPatchService(rt, spc, cfg.policy, database, logger, bcryptCost),
+		Delete:       &services.DeleteService{Logger: logger, Database: database, ServiceProviderConfig: spc, Lock: locks, Policy: cfg.policy},
+	}
+	endpoint.Replace.Lock = locks
+	endpoint.Patch.Lock = locks
+
+	var (
+		createHandler  = &handler.Create{Log: logger, ResourceType: rt, Service: endpoint.Create, Policy: cfg.policy}
+		queryHandler   = &handler.Query{Log: logger, Service: &services.QueryService{Logger: logger, Database: database, ServiceProviderConfig: spc, ResourceType: rt}, Policy: cfg.policy, Stream: true}
+		getHandler     = &handler.Get{Log: logger, ResourceIDPathParam: resourceIDPathParam, Service: &services.GetService{Logger: logger, Database: database, ServiceProviderConfig: spc}, Policy: cfg.policy}
+		replaceHandler = &handler.Replace{Log: logger, ResourceType: rt, ResourceIDPathParam: resourceIDPathParam, Service: endpoint.Replace, Policy: cfg.policy}
+		patchHandler   = &handler.Patch{Log: logger, ResourceIDPathParam: resourceIDPathParam, Service: endpoint.Patch, Policy: cfg.policy}
+		deleteHandler  = &handler.Delete{Log: logger, ResourceIDPathParam: resourceIDPathParam, Service: endpoint.Delete}
+	)
+
+	resourcePath := base + "/{" + resourceIDPathParam + "}"
+	for _, route := range []struct {
+		method   string
+		template string
+		fn       handler.Func
+	}{
+		{method: http.MethodGet, template: base, fn: queryHandler.Handle},
+		{method: http.MethodPost, template: base, fn: createHandler.Handle},
+		{method: http.MethodPost, template: base + searchSuffix, fn: queryHandler.Handle},
+		{method: http.MethodGet, template: resourcePath, fn: getHandler.Handle},
+		{method: http.MethodPut, template: resourcePath, fn: replaceHandler.Handle},
+		{method: http.MethodPatch, template: resourcePath, fn: patchHandler.Handle},
+		{method: http.MethodDelete, template: resourcePath, fn: deleteHandler.Handle},
+	} {
+		if err := router.Handle(route.method, route.template, authenticate(route.fn)); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Info("mounted resource type [id=%s] at %s", rt.ID(), base)
+	return &mountedResourceType{
+		database: database,
+		bulk:     endpoint,
+		get:      getHandler,
+		replace:  replaceHandler,
+		patch:    patchHandler,
+		delete:   deleteHandler,
+	}, nil
+}
+
+func newCreateService(rt *spec.ResourceType, policy *auth.Policy, database db.DB, logger log.Logger, bcryptCost int) *services.CreateService {
+	filters := append(authorization(policy), filter.ClearReadOnly(), filter.ID())
+	if hasPassword(rt) {
+		filters = append(filters, filter.Password(bcryptCost))
+	}
+	filters = append(filters, filter.Meta(), filter.Validation(database))
+
+	return &services.CreateService{
+		Logger:   logger,
+		Filters:  filters,
+		Database: database,
+	}
+}
+
+func newReplaceService(rt *spec.ResourceType, spc *spec.ServiceProviderConfig, policy *auth.Policy, database db.DB, logger log.Logger, bcryptCost int) *services.ReplaceService {
+	filters := append(authorization(policy), filter.ClearReadOnly(), filter.CopyReadOnly())
+	if hasPassword(rt) {
+		filters = append(filters, filter.Password(bcryptCost))
+	}
+	filters = append(filters, filter.Validation(database), filter.Meta())
+
+	return &services.ReplaceService{
+		Logger:                logger,
+		Filters:               filters,
+		Database:              database,
+		ServiceProviderConfig: spc,
+	}
+}
+
+func newPatchService(rt *spec.ResourceType, spc *spec.ServiceProviderConfig, policy *auth.Policy, database db.DB, logger log.Logger, bcryptCost int) *services.PatchService {
+	postFilters := append(authorization(policy), filter.CopyReadOnly())
+	if hasPassword(rt) {
+		postFilters = append(postFilters, filter.Password(bcryptCost))
+	}
+	postFilters = append(postFilters, filter.Validation(database), filter.Meta())
+
+	return &services.PatchService{
+		Logger:                logger,
+		PrePatchFilters:       []filter.ForResource{},
+		PostPatchFilters:      postFilters,
+		Database:              database,
+		ServiceProviderConfig: spc,
+	}
+}
+
+// Returns the authorization filter for the policy, which must run before other filters change the resource, or no
+// filter if the policy is absent.
+func authorization(policy *auth.Policy) []filter.ForResource {
+	if policy == nil {
+		return []filter.ForResource{}
+	}
+	return []filter.ForResource{filter.Authorization(policy)}
+}
+
+// Returns true if the resource type defines a top level password attribute, which needs to be hashed before saving.
+func hasPassword(rt *spec.ResourceType) bool {
+	return rt.SuperAttribute(true).SubAttributeForName("password") != nil
+}
+
+// Returns the URL path of the resource type endpoint, which may be defined as either an absolute URL or a path.
+func endpointPath(rt *spec.ResourceType) (string, error) {
+	u, err := url.Parse(rt.Endpoint())
+	if err != nil {
+		return "", errors.Internal("resource type [id=%s] has invalid endpoint: %s", rt.ID(), err.Error())
+	}
+	p := strings.TrimSuffix(u.Path, "/")
+	if len(p) == 0 {
+		return "", errors.Internal("resource type [id=%s] has no endpoint path", rt.ID())
+	}
+	return p, nil
+}
+
+// Returns the scheme and host of the server, as inferred from the first resource type endpoint defined as an absolute
+// URL, or empty string if none is defined as such.
+func serverURL(cfg *config) string {
+	for _, rt := range cfg.resourceTypes {
+		if u, err := url.Parse(rt.Endpoint()); err == nil && u.IsAbs() {
+			return u.Scheme + "://" + u.Host
+		}
+	}
+	return ""
+}
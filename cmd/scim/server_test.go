@@ -0,0 +1,698 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s := new(ServerTestSuite)
+	s.configDir = "./config"
+	suite.Run(t, s)
+}
+
+type ServerTestSuite struct {
+	suite.Suite
+	configDir string
+}
+
+func (s *ServerTestSuite) TestServe() {
+	cfg, err := loadConfig(s.configDir)
+	s.Require().Nil(err)
+	s.Require().Len(cfg.resourceTypes, 2)
+
+	secret := []byte("jwt-secret")
+	router, err := newRouter(cfg, log.None(), &options{
+		bcryptCost:   4,
+		bearerTokens: map[string]string{"t0ken": "admin"},
+		jwtSecret:    secret,
+	})
+	s.Require().Nil(err)
+
+	var userID, userVersion string
+
+	tests := []struct {
+		name string
+		// When false, requests without the Authorization header are authenticated with the static bearer token.
+		anonymous bool
+		getReq    func(t *testing.T) *http.Request
+		expect    func(t *testing.T, rr *httptest.ResponseRecorder)
+	}{
+		{
+			name: "get service provider config",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+			},
+		},
+		{
+			name: "list schemas",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/Schemas", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.NotEmpty(t, body["Resources"])
+			},
+		},
+		{
+			name: "list schemas by filter",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/Schemas?filter=name+eq+%22User%22", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, float64(1), body["totalResults"])
+			},
+		},
+		{
+			name: "get schema",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/Schemas/urn:ietf:params:scim:schemas:core:2.0:User", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.Equal(t, "http://localhost:8080/Schemas/urn:ietf:params:scim:schemas:core:2.0:User", rr.Result().Header.Get("Location"))
+			},
+		},
+		{
+			name: "get resource type",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/ResourceTypes/User", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Len(t, body["schemaExtensions"], 1)
+				assert.Equal(t, "http://localhost:8080/ResourceTypes/User", body["meta"].(map[string]interface{})["location"])
+			},
+		},
+		{
+			name: "create user",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/Users", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"userName": "imulab",
+	"password": "s3cret"
+}
+`))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 201, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "imulab", body["userName"])
+				assert.Nil(t, body["password"])
+				userID = body["id"].(string)
+				header := rr.Result().Header
+				assert.Equal(t, "application/json+scim", header.Get("Content-Type"))
+				assert.NotEmpty(t, header.Get("ETag"))
+				assert.Equal(t, "http://localhost:8080/Users/"+userID, header.Get("Location"))
+			},
+		},
+		{
+			name: "replace user",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodPut, "/Users/"+userID, strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"userName": "imulab",
+	"displayName": "Weinan",
+	"password": "s3cret"
+}
+`))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				header := rr.Result().Header
+				assert.Equal(t, "application/json+scim", header.Get("Content-Type"))
+				assert.NotEmpty(t, header.Get("ETag"))
+				assert.Equal(t, "http://localhost:8080/Users/"+userID, header.Get("Location"))
+			},
+		},
+		{
+			name: "patch user",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodPatch, "/Users/"+userID, strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	"Operations": [{"op": "replace", "path": "displayName", "value": "David"}]
+}
+`))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				header := rr.Result().Header
+				assert.Equal(t, "application/json+scim", header.Get("Content-Type"))
+				assert.NotEmpty(t, header.Get("ETag"))
+				assert.Equal(t, "http://localhost:8080/Users/"+userID, header.Get("Location"))
+			},
+		},
+		{
+			name: "get user",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/Users/"+userID, nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				header := rr.Result().Header
+				assert.Equal(t, "application/json+scim", header.Get("Content-Type"))
+				assert.NotEmpty(t, header.Get("ETag"))
+				userVersion = header.Get("ETag")
+			},
+		},
+		{
+			name: "get unmodified user",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/Users/"+userID, nil)
+				req.Header.Set("If-None-Match", userVersion)
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 304, rr.Code)
+				assert.Empty(t, rr.Body.String())
+			},
+		},
+		{
+			name: "get user with failed pre condition",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/Users/"+userID, nil)
+				req.Header.Set("If-Match", `W/"stale"`)
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 412, rr.Code)
+			},
+		},
+		{
+			name: "me with basic authentication",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/Me", nil)
+				req.SetBasicAuth("imulab", "s3cret")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, userID, body["id"])
+			},
+		},
+		{
+			name: "me with jwt",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/Me", nil)
+				req.Header.Set("Authorization", "Bearer "+s.signJWT(secret, "imulab"))
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, userID, body["id"])
+			},
+		},
+		{
+			name: "basic authentication with wrong password",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/Me", nil)
+				req.SetBasicAuth("imulab", "wrong")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 401, rr.Code)
+			},
+		},
+		{
+			name: "search users",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/Users/.search", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+	"filter": "userName eq \"imulab\""
+}
+`))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, float64(1), body["totalResults"])
+				assert.Equal(t, "application/json+scim", rr.Result().Header.Get("Content-Type"))
+			},
+		},
+		{
+			name: "search root",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/.search", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+	"filter": "meta.resourceType eq \"User\" and userName eq \"imulab\""
+}
+`))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, float64(1), body["totalResults"])
+			},
+		},
+		{
+			name: "query root",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/?filter=meta.resourceType+eq+%22Group%22", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, float64(0), body["totalResults"], rr.Body.String())
+			},
+		},
+		{
+			name: "users are not visible to groups",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/Groups/"+userID, nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 404, rr.Code)
+				assert.Equal(t, "application/json+scim", rr.Result().Header.Get("Content-Type"))
+			},
+		},
+		{
+			name: "delete user",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodDelete, "/Users/"+userID, nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 204, rr.Code)
+			},
+		},
+		{
+			name: "bulk",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [
+		{
+			"method": "POST",
+			"path": "/Users",
+			"bulkId": "qwerty",
+			"data": {
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+				"userName": "bulk"
+			}
+		},
+		{
+			"method": "POST",
+			"path": "/Groups",
+			"bulkId": "ytrewq",
+			"data": {
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+				"displayName": "Bulk",
+				"members": [{"value": "bulkId:qwerty"}]
+			}
+		}
+	]
+}
+`))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := new(struct {
+					Operations []struct {
+						Status string `json:"status"`
+					} `json:"Operations"`
+				})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), body), rr.Body.String())
+				require.Len(t, body.Operations, 2)
+				assert.Equal(t, "201", body.Operations[0].Status, rr.Body.String())
+				assert.Equal(t, "201", body.Operations[1].Status, rr.Body.String())
+			},
+		},
+		{
+			name:      "resources require authentication",
+			anonymous: true,
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/Users", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 401, rr.Code)
+				assert.Equal(t, []string{`Basic realm="scim"`, `Bearer realm="scim"`}, rr.Result().Header["Www-Authenticate"])
+			},
+		},
+		{
+			name:      "me requires authentication",
+			anonymous: true,
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/Me", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 401, rr.Code)
+			},
+		},
+		{
+			name: "unsupported method",
+			getReq: func(t *testing.T) *http.Request {
+				return httptest.NewRequest(http.MethodPut, "/Users", nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 405, rr.Code)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			req := test.getReq(t)
+			if !test.anonymous && len(req.Header.Get("Authorization")) == 0 {
+				req.Header.Set("Authorization", "Bearer t0ken")
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			test.expect(t, rr)
+		})
+	}
+}
+
+func (s *ServerTestSuite) TestServeWithPolicy() {
+	cfg, err := loadConfig(s.configDir)
+	s.Require().Nil(err)
+	cfg.policy = &auth.Policy{
+		Rules: []*auth.Rule{
+			{Subjects: []string{"provisioner"}, Operations: []auth.Operation{auth.OperationCreate}, Paths: []string{"*"}},
+			{Subjects: []string{"admin"}, Operations: []auth.Operation{auth.OperationRead}, Paths: []string{"*"}},
+			{Subjects: []string{"admin"}, ResourceType: "User", Operations: []auth.Operation{auth.OperationUpdate}, Paths: []string{"active"}},
+			{Subjects: []string{"*"}, Self: true, Operations: []auth.Operation{auth.OperationRead, auth.OperationUpdate, auth.OperationDelete}, Paths: []string{"phoneNumbers"}},
+			{Subjects: []string{"deleter"}, Operations: []auth.Operation{auth.OperationDelete}, Paths: []string{"*"}},
+			{Subjects: []string{"typist"}, Operations: []auth.Operation{auth.OperationUpdate}, Paths: []string{"emails.type"}},
+		},
+	}
+
+	router, err := newRouter(cfg, log.None(), &options{
+		bcryptCost:   4,
+		bearerTokens: map[string]string{"admin-t0ken": "admin", "provisioner-t0ken": "provisioner", "deleter-t0ken": "deleter", "typist-t0ken": "typist"},
+	})
+	s.Require().Nil(err)
+
+	var userID string
+	patch := func(path string, value string) io.Reader {
+		return strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	"Operations": [{"op": "replace", "path": "` + path + `", "value": ` + value + `}]
+}
+`)
+	}
+
+	tests := []struct {
+		name   string
+		getReq func(t *testing.T) *http.Request
+		expect func(t *testing.T, rr *httptest.ResponseRecorder)
+	}{
+		{
+			name: "provisioner creates user",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/Users", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"userName": "imulab",
+	"displayName": "Weinan",
+	"password": "s3cret",
+	"emails": [{"value": "a@x.com", "type": "work"}, {"value": "b@x.com", "type": "home"}]
+}
+`))
+				req.Header.Set("Authorization", "Bearer provisioner-t0ken")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 201, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Nil(t, body["userName"])
+				userID = body["id"].(string)
+			},
+		},
+		{
+			name: "admin cannot create user",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/Users", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"userName": "foo"
+}
+`))
+				req.Header.Set("Authorization", "Bearer admin-t0ken")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Code)
+			},
+		},
+		{
+			name: "typist updates type of email",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPatch, "/Users/"+userID, patch(`emails[value eq \"a@x.com\"].type`, `"other"`))
+				req.Header.Set("Authorization", "Bearer typist-t0ken")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+			},
+		},
+		{
+			name: "typist cannot remove email",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPatch, "/Users/"+userID, strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	"Operations": [{"op": "remove", "path": "emails[value eq \"b@x.com\"]"}]
+}
+`))
+				req.Header.Set("Authorization", "Bearer typist-t0ken")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Code)
+			},
+		},
+		{
+			name: "admin cannot update displayName",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPatch, "/Users/"+userID, patch("displayName", `"foo"`))
+				req.Header.Set("Authorization", "Bearer admin-t0ken")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Code)
+			},
+		},
+		{
+			name: "admin updates active",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPatch, "/Users/"+userID, patch("active", `false`))
+				req.Header.Set("Authorization", "Bearer admin-t0ken")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, false, body["active"])
+				assert.Equal(t, "Weinan", body["displayName"])
+			},
+		},
+		{
+			name: "self updates phone numbers",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPatch, "/Me", patch("phoneNumbers", `[{"value": "123-45678", "type": "work"}]`))
+				req.SetBasicAuth("imulab", "s3cret")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Len(t, body["phoneNumbers"], 1)
+				assert.Nil(t, body["displayName"])
+			},
+		},
+		{
+			name: "self updates phone numbers without returning attributes",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPatch, "/Me?attributes=", patch("phoneNumbers", `[{"value": "123-45679", "type": "work"}]`))
+				req.SetBasicAuth("imulab", "s3cret")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 204, rr.Code)
+				assert.Empty(t, rr.Body.String())
+			},
+		},
+		{
+			name: "self cannot update displayName",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPatch, "/Me", patch("displayName", `"foo"`))
+				req.SetBasicAuth("imulab", "s3cret")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Code)
+			},
+		},
+		{
+			name: "self cannot delete itself",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodDelete, "/Me", nil)
+				req.SetBasicAuth("imulab", "s3cret")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Code)
+			},
+		},
+		{
+			name: "admin cannot delete user",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodDelete, "/Users/"+userID, nil)
+				req.Header.Set("Authorization", "Bearer admin-t0ken")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Code)
+			},
+		},
+		{
+			name: "self cannot delete user in bulk",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/Bulk", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [{"method": "DELETE", "path": "/Users/`+userID+`"}]
+}
+`))
+				req.SetBasicAuth("imulab", "s3cret")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := new(struct {
+					Operations []struct {
+						Status string `json:"status"`
+					} `json:"Operations"`
+				})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), body), rr.Body.String())
+				require.Len(t, body.Operations, 1)
+				assert.Equal(t, "403", body.Operations[0].Status, rr.Body.String())
+			},
+		},
+		{
+			name: "deleter deletes user",
+			getReq: func(t *testing.T) *http.Request {
+				req := httptest.NewRequest(http.MethodDelete, "/Users/"+userID, nil)
+				req.Header.Set("Authorization", "Bearer deleter-t0ken")
+				return req
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 204, rr.Code)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, test.getReq(t))
+			test.expect(t, rr)
+		})
+	}
+}
+
+func (s *ServerTestSuite) TestServeWithDataDirectory() {
+	cfg, err := loadConfig(s.configDir)
+	s.Require().Nil(err)
+
+	dataDir, err := ioutil.TempDir("", "scim-data")
+	s.Require().Nil(err)
+	defer os.RemoveAll(dataDir)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		databases, closeDatabases, err := openDatabases(cfg, dataDir)
+		s.Require().Nil(err)
+		defer func() {
+			s.Require().Nil(closeDatabases())
+		}()
+
+		router, err := newRouter(cfg, log.None(), &options{
+			bcryptCost:   4,
+			bearerTokens: map[string]string{"t0ken": "admin"},
+			databases:    databases,
+		})
+		s.Require().Nil(err)
+
+		if len(req.Header.Get("Authorization")) == 0 {
+			req.Header.Set("Authorization", "Bearer t0ken")
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(httptest.NewRequest(http.MethodPost, "/Users", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"userName": "imulab",
+	"password": "s3cret"
+}
+`)))
+	s.Require().Equal(201, rr.Code)
+	body := make(map[string]interface{})
+	s.Require().Nil(json.Unmarshal(rr.Body.Bytes(), &body))
+	userID := body["id"].(string)
+
+	// the user survives the restart of the server, and can still authenticate with its password
+	rr = serve(httptest.NewRequest(http.MethodGet, "/Users/"+userID, nil))
+	assert.Equal(s.T(), 200, rr.Code)
+	assert.Equal(s.T(), rr.Result().Header.Get("ETag"), body["meta"].(map[string]interface{})["version"])
+
+	req := httptest.NewRequest(http.MethodGet, "/Me", nil)
+	req.SetBasicAuth("imulab", "s3cret")
+	rr = serve(req)
+	assert.Equal(s.T(), 200, rr.Code)
+}
+
+func (s *ServerTestSuite) signJWT(secret []byte, subject string) string {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	s.Require().Nil(err)
+	payload, err := json.Marshal(map[string]interface{}{"sub": subject})
+	s.Require().Nil(err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -6,19 +6,26 @@ import (
 
 // Defined error types. Each error type will have a corresponding constructor.
 const (
-	TypeInvalidRequest = "invalidRequest"
-	TypeInvalidFilter  = "invalidFilter"
-	TypeTooMany        = "tooMany"
-	TypeUniqueness     = "uniqueness"
-	TypeMutability     = "mutability"
-	TypeInvalidSyntax  = "invalidSyntax"
-	TypeInvalidPath    = "invalidPath"
-	TypeNoTarget       = "noTarget"
-	TypeInvalidValue   = "invalidValue"
-	TypePreCondition   = "preCondition"
-	TypeSensitive      = "sensitive"
-	TypeNotFound       = "notFound"
-	TypeInternal       = "internal"
+	TypeInvalidRequest   = "invalidRequest"
+	TypeInvalidFilter    = "invalidFilter"
+	TypeTooMany          = "tooMany"
+	TypeUniqueness       = "uniqueness"
+	TypeMutability       = "mutability"
+	TypeInvalidSyntax    = "invalidSyntax"
+	TypeInvalidPath      = "invalidPath"
+	TypeNoTarget         = "noTarget"
+	TypeInvalidValue     = "invalidValue"
+	TypeInvalidCursor    = "invalidCursor"
+	TypePreCondition     = "preCondition"
+	TypeSensitive        = "sensitive"
+	TypeNotFound         = "notFound"
+	TypeMethodNotAllowed = "methodNotAllowed"
+	TypeTooLarge         = "tooLarge"
+	TypeUnauthorized     = "unauthorized"
+	TypeForbidden        = "forbidden"
+	TypeNotImplemented   = "notImplemented"
+	TypeUnavailable      = "unavailable"
+	TypeInternal         = "internal"
 )
 
 // Returns error to describe that the request is invalid. This is a generic error description,
@@ -108,6 +115,16 @@ func InvalidValue(format string, args ...interface{}) error {
 	}
 }
 
+// Returns error to describe that the cursor of a cursor paginated query was malformed, or was obtained with different
+// query parameters.
+func InvalidCursor(format string, args ...interface{}) error {
+	return &Error{
+		Status:  400,
+		Type:    TypeInvalidCursor,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
 // Returns error to describe that the specified precondition failed and request cannot proceed.
 func PreConditionFailed(format string, args ...interface{}) error {
 	return &Error{
@@ -136,6 +153,62 @@ func NotFound(format string, args ...interface{}) error {
 	}
 }
 
+// Returns error to describe that the requested endpoint does not support the HTTP method.
+func MethodNotAllowed(format string, args ...interface{}) error {
+	return &Error{
+		Status:  405,
+		Type:    TypeMethodNotAllowed,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Returns error to describe that the request exceeds the limits of the service provider, i.e. the maximum number of
+// bulk operations or the maximum bulk payload size.
+func TooLarge(format string, args ...interface{}) error {
+	return &Error{
+		Status:  413,
+		Type:    TypeTooLarge,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Returns error to describe that the request is not authenticated.
+func Unauthorized(format string, args ...interface{}) error {
+	return &Error{
+		Status:  401,
+		Type:    TypeUnauthorized,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Returns error to describe that the authenticated principal is not allowed to perform the request.
+func Forbidden(format string, args ...interface{}) error {
+	return &Error{
+		Status:  403,
+		Type:    TypeForbidden,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Returns error to describe that the requested feature is not implemented or not configured by the server.
+func NotImplemented(format string, args ...interface{}) error {
+	return &Error{
+		Status:  501,
+		Type:    TypeNotImplemented,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Returns error to describe that the server is temporarily unable to process the request, i.e. the resource is locked by
+// a concurrent operation. The request may be retried later.
+func Unavailable(format string, args ...interface{}) error {
+	return &Error{
+		Status:  503,
+		Type:    TypeUnavailable,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
 // Returns error to describe that server encountered internal error. This should be the returned error when the user
 // input is not at fault.
 func Internal(format string, args ...interface{}) error {
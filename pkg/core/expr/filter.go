@@ -45,8 +45,9 @@ func CompileFilter(filter string) (*Expression, error) {
 					return !top.IsLeftParenthesis()
 				})
 				if popped != nil {
-					// ignore error. we are sure it won't err
-					_ = compiler.pushBuildResult(popped)
+					if err := compiler.pushBuildResult(popped); err != nil {
+						return nil, err
+					}
 				} else {
 					break
 				}
@@ -63,11 +64,12 @@ func CompileFilter(filter string) (*Expression, error) {
 			minPriority := opPriority(step.token)
 			for {
 				popped := compiler.popOperatorIf(func(top *Expression) bool {
-					return opPriority(top.token) >= minPriority
+					return top.IsOperator() && opPriority(top.token) >= minPriority
 				})
 				if popped != nil {
-					// ignore error. we are sure it won't err
-					_ = compiler.pushBuildResult(popped)
+					if err := compiler.pushBuildResult(popped); err != nil {
+						return nil, err
+					}
 				} else {
 					break
 				}
@@ -81,14 +83,18 @@ func CompileFilter(filter string) (*Expression, error) {
 
 	// pop all remaining operators
 	for len(compiler.opStack) > 0 {
-		_ = compiler.pushBuildResult(compiler.popOperatorIf(func(top *Expression) bool {
+		if err := compiler.pushBuildResult(compiler.popOperatorIf(func(top *Expression) bool {
 			return true
-		}))
+		})); err != nil {
+			return nil, err
+		}
 	}
 
 	// assertion check
-	if len(compiler.rsStack) != 1 || !compiler.rsStack[0].IsOperator() {
+	if len(compiler.rsStack) != 1 {
 		panic("flaw in algorithm")
+	} else if !isPredicate(compiler.rsStack[0]) {
+		return nil, errors.InvalidFilter("'%s' is not a filter", compiler.rsStack[0].token)
 	}
 
 	// pop off the root so the rest could be GC'ed
@@ -198,13 +204,12 @@ func (c *filterCompiler) pushBuildResult(step *Expression) error {
 		return nil
 	}
 
-	// Path: re-compile and push
+	// Path: re-compile and push. The path may contain a value filter (i.e. emails[type eq "work"]), which is a
+	// predicate on its own when the path ends with it.
 	if step.IsPath() {
 		head, err := CompilePath(step.token)
 		if err != nil {
 			return errors.InvalidFilter("path in filter is invalid. (" + err.Error() + ")")
-		} else if head.ContainsFilter() {
-			return errors.InvalidFilter("nested filter is invalid.")
 		}
 		c.rsStack = append(c.rsStack, head)
 		return nil
@@ -235,11 +240,35 @@ func (c *filterCompiler) pushBuildResult(step *Expression) error {
 	default:
 		panic("unsupported cardinality")
 	}
+
+	// Operands of logical operators must be predicates
+	if step.IsLogicalOperator() {
+		for _, operand := range []*Expression{step.left, step.right} {
+			if operand != nil && !isPredicate(operand) {
+				return errors.InvalidFilter("'%s' is not a filter", operand.token)
+			}
+		}
+	}
 	c.rsStack = append(c.rsStack, step)
 
 	return nil
 }
 
+// Returns true if the expression is a predicate: either an operator, or a path ending with a value filter, which
+// matches if any element of the multiValued attribute matches the filter.
+func isPredicate(e *Expression) bool {
+	if e.IsOperator() {
+		return true
+	}
+	if !e.IsPath() {
+		return false
+	}
+	for e.next != nil {
+		e = e.next
+	}
+	return e.IsRootOfFilter()
+}
+
 // Returns true if there could be more meaningful information to parsed.
 func (c *filterCompiler) hasMore() bool {
 	return c.op != scanFilterEnd && c.op != scanFilterError
@@ -318,7 +347,7 @@ func (c *filterCompiler) scanOperatorOrPath() (*Expression, error) {
 	start := c.off - 1
 	end := c.scanWhile(scanFilterContinue)
 	switch c.op {
-	case scanFilterEndPath:
+	case scanFilterEndPath, scanFilterEnd:
 		return newPath(string(c.data[start:end])), nil
 	case scanFilterEndOp:
 		return newOperator(string(c.data[start:end])), nil
@@ -568,9 +597,77 @@ func (fs *filterScanner) stateInPath(scan *filterScanner, c byte) int {
 		return scanFilterContinue
 	}
 
+	if c == '[' {
+		scan.step = fs.stateInValueFilter
+		return scanFilterContinue
+	}
+
 	return fs.error(c, "invalid character in path")
 }
 
+// Intermediate state where we are inside the value filter of a path (i.e. emails[type eq "work"]). The value filter is
+// part of the path, and is compiled along with it, so we only look for the closing bracket, which does not count when
+// it is inside a string literal.
+func (fs *filterScanner) stateInValueFilter(scan *filterScanner, c byte) int {
+	switch c {
+	case '"':
+		scan.step = fs.stateInValueFilterString
+	case ']':
+		scan.step = fs.stateEndValueFilter
+	case '[':
+		return fs.error(c, "nested value filter is not allowed")
+	case 0:
+		return fs.error(c, "mismatched bracket")
+	}
+	return scanFilterContinue
+}
+
+// Intermediate state where we are inside a string literal of the value filter. Another double quote ends the string,
+// unless it is escaped.
+func (fs *filterScanner) stateInValueFilterString(scan *filterScanner, c byte) int {
+	switch c {
+	case '\\':
+		scan.step = fs.stateInValueFilterStringEsc
+	case '"':
+		scan.step = fs.stateInValueFilter
+	case 0:
+		return fs.error(c, "unterminated string literal")
+	}
+	return scanFilterContinue
+}
+
+// Intermediate state where we are after the escape character in a string literal of the value filter. The escaped
+// character is validated when the value filter is compiled.
+func (fs *filterScanner) stateInValueFilterStringEsc(scan *filterScanner, c byte) int {
+	if c == 0 {
+		return fs.error(c, "unterminated string literal")
+	}
+	scan.step = fs.stateInValueFilterString
+	return scanFilterContinue
+}
+
+// Intermediate state after the closing bracket of the value filter. A path separator continues the path with a sub
+// attribute, to be compared by an operator (i.e. emails[type eq "work"].value co "@foo.com"); otherwise, the path ends
+// the predicate on its own.
+func (fs *filterScanner) stateEndValueFilter(scan *filterScanner, c byte) int {
+	switch c {
+	case '.':
+		scan.step = fs.stateInPath
+		return scanFilterContinue
+	case ' ':
+		scan.step = fs.stateEndPredicate
+		return scanFilterEndPath
+	case ')':
+		// ask caller to replay with a space so the path is ended, and then the parenthesis
+		return scanFilterInsertSpace
+	case 0:
+		scan.step = fs.stateEof
+		return scanFilterEnd
+	}
+
+	return fs.error(c, "invalid character after value filter")
+}
+
 // Intermediate state at the beginning of an operator defined by SCIM query protocol.
 func (fs *filterScanner) stateBeginOp(scan *filterScanner, c byte) int {
 	if c == ' ' {
@@ -137,6 +137,107 @@ func (s *FilterTestSuite) TestFilterCompiler() {
 				assert.Equal(t, literal, trail[6].typ)
 			},
 		},
+		{
+			name:   "logical operators within parenthesis",
+			filter: "(username eq \"foo\" or age gt 10) and active eq true",
+			assert: func(t *testing.T, trail []expect, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, trail, 11)
+
+				assert.Equal(t, And, trail[0].value)
+				assert.Equal(t, Or, trail[1].value)
+				assert.Equal(t, Eq, trail[2].value)
+				assert.Equal(t, "username", trail[3].value)
+				assert.Equal(t, "\"foo\"", trail[4].value)
+				assert.Equal(t, Gt, trail[5].value)
+				assert.Equal(t, "age", trail[6].value)
+				assert.Equal(t, "10", trail[7].value)
+				assert.Equal(t, Eq, trail[8].value)
+				assert.Equal(t, "active", trail[9].value)
+				assert.Equal(t, "true", trail[10].value)
+			},
+		},
+		{
+			name:   "value path filter",
+			filter: "emails[type eq \"work\"]",
+			assert: func(t *testing.T, trail []expect, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, trail, 4)
+				assert.Equal(t, expect{"emails", step}, trail[0])
+				assert.Equal(t, expect{Eq, operator}, trail[1])
+				assert.Equal(t, expect{"type", step}, trail[2])
+				assert.Equal(t, expect{"\"work\"", literal}, trail[3])
+			},
+		},
+		{
+			name:   "value path filter with logical operators",
+			filter: "emails[type eq \"work\" and value co \"@example.com\"] and displayName sw \"Eng\"",
+			assert: func(t *testing.T, trail []expect, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, trail, 12)
+				assert.Equal(t, expect{And, operator}, trail[0])
+				assert.Equal(t, expect{"emails", step}, trail[1])
+				assert.Equal(t, expect{And, operator}, trail[2])
+				assert.Equal(t, expect{Eq, operator}, trail[3])
+				assert.Equal(t, expect{"type", step}, trail[4])
+				assert.Equal(t, expect{"\"work\"", literal}, trail[5])
+				assert.Equal(t, expect{Co, operator}, trail[6])
+				assert.Equal(t, expect{"value", step}, trail[7])
+				assert.Equal(t, expect{"\"@example.com\"", literal}, trail[8])
+				assert.Equal(t, expect{Sw, operator}, trail[9])
+				assert.Equal(t, expect{"displayName", step}, trail[10])
+				assert.Equal(t, expect{"\"Eng\"", literal}, trail[11])
+			},
+		},
+		{
+			name:   "value path filter with sub attribute",
+			filter: "emails[type eq \"work\"].value co \"@example.com\"",
+			assert: func(t *testing.T, trail []expect, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, trail, 7)
+				assert.Equal(t, expect{Co, operator}, trail[0])
+				assert.Equal(t, expect{"emails", step}, trail[1])
+				assert.Equal(t, expect{Eq, operator}, trail[2])
+				assert.Equal(t, expect{"type", step}, trail[3])
+				assert.Equal(t, expect{"\"work\"", literal}, trail[4])
+				assert.Equal(t, expect{"value", step}, trail[5])
+				assert.Equal(t, expect{"\"@example.com\"", literal}, trail[6])
+			},
+		},
+		{
+			name:   "value path filter within parenthesis",
+			filter: "not (members[value eq \"a]b\"])",
+			assert: func(t *testing.T, trail []expect, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, trail, 5)
+				assert.Equal(t, expect{Not, operator}, trail[0])
+				assert.Equal(t, expect{"members", step}, trail[1])
+				assert.Equal(t, expect{Eq, operator}, trail[2])
+				assert.Equal(t, expect{"value", step}, trail[3])
+				assert.Equal(t, expect{"\"a]b\"", literal}, trail[4])
+			},
+		},
+		{
+			name:   "invalid filter: operator after value path",
+			filter: "emails[type eq \"work\"] eq \"foo\"",
+			assert: func(t *testing.T, trail []expect, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "invalid filter: unclosed value path",
+			filter: "emails[type eq \"work\"",
+			assert: func(t *testing.T, trail []expect, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "invalid filter: sub attribute of value path without operator",
+			filter: "emails[type eq \"work\"].value and userName pr",
+			assert: func(t *testing.T, trail []expect, err error) {
+				assert.NotNil(t, err)
+			},
+		},
 		{
 			name:   "invalid filter: starts with literal",
 			filter: "\"hello\" eq false",
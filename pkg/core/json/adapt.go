@@ -1,13 +1,17 @@
 package json
 
-import "github.com/imulab/go-scim/pkg/core/prop"
+import (
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+)
 
 type ResourceMarshalAdapter struct {
 	Resource	*prop.Resource
 	Include		[]string
 	Exclude		[]string
+	Allow		func(attribute *spec.Attribute) bool
 }
 
 func (r ResourceMarshalAdapter) MarshalJSON() ([]byte, error) {
-	return Serialize(r.Resource, Options().Include(r.Include...).Exclude(r.Exclude...))
+	return Serialize(r.Resource, Options().Include(r.Include...).Exclude(r.Exclude...).Allow(r.Allow))
 }
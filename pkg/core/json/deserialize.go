@@ -1,6 +1,8 @@
 package json
 
 import (
+	"bytes"
+	encodingJSON "encoding/json"
 	"github.com/imulab/go-scim/pkg/core/errors"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
@@ -223,6 +225,15 @@ func (d *deserializeState) parseMultiValuedProperty() error {
 	// Skip any spaces between '[' and the potential first element
 	d.scanWhile(scanSkipSpace)
 
+	// Empty array: consume ']' and any spaces after it, as there are no elements to parse.
+	if d.opCode == scanEndArray {
+		d.scanNext()
+		if d.opCode == scanSkipSpace {
+			d.scanWhile(scanSkipSpace)
+		}
+		return nil
+	}
+
 elements:
 	for d.opCode != scanEndArray {
 		// Create the place-holding element prototype and focus on it
@@ -293,7 +304,15 @@ func (d *deserializeState) parseStringProperty() error {
 		return d.errInvalidSyntax("expects string literal value for '%s'", p.Attribute().Path())
 	}
 
-	return d.navigator.Current().Replace(string(d.data[start+1 : end-1]))
+	if bytes.IndexByte(d.data[start+1:end-1], '\\') < 0 {
+		return d.navigator.Current().Replace(string(d.data[start+1 : end-1]))
+	}
+
+	var unescaped string
+	if err := encodingJSON.Unmarshal(d.data[start:end], &unescaped); err != nil {
+		return d.errInvalidSyntax("invalid string literal value for '%s'", p.Attribute().Path())
+	}
+	return d.navigator.Current().Replace(unescaped)
 }
 
 // Parses a JSON integer. This method expects an integer literal and the null literal.
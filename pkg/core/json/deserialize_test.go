@@ -430,7 +430,7 @@ func (s *JSONDeserializeTestSuite) TestDeserialize() {
 					}
 					{
 						_, _ = nav.FocusName("version")
-						assert.Equal(t, "W/\\\"1\\\"", nav.Current().Raw())
+						assert.Equal(t, "W/\"1\"", nav.Current().Raw())
 						nav.Retract()
 					}
 					nav.Retract()
@@ -618,6 +618,34 @@ func (s *JSONDeserializeTestSuite) TestDeserialize() {
 				assert.False(t, nav.Current().Dirty())
 			},
 		},
+		{
+			name: "empty arrays",
+			getResource: func(t *testing.T) *prop.Resource {
+				_ = s.mustSchema("/user_schema.json")
+				return prop.NewResource(s.mustResourceType("/user_resource_type.json"))
+			},
+			json: `
+{
+	"emails": [ ],
+	"userName": "imulab",
+	"groups": []
+}
+`,
+			expect: func(t *testing.T, resource *prop.Resource, err error) {
+				assert.Nil(t, err)
+				nav := resource.NewNavigator()
+				{
+					_, _ = nav.FocusName("emails")
+					assert.Equal(t, 0, nav.Current().(prop.Container).CountChildren())
+					nav.Retract()
+				}
+				{
+					_, _ = nav.FocusName("userName")
+					assert.Equal(t, "imulab", nav.Current().Raw())
+					nav.Retract()
+				}
+			},
+		},
 		{
 			name: "explicit nulls",
 			getResource: func(t *testing.T) *prop.Resource {
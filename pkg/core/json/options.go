@@ -1,5 +1,7 @@
 package json
 
+import "github.com/imulab/go-scim/pkg/core/spec"
+
 // Create a new empty JSON serialization option.
 func Options() *options {
 	return &options{}
@@ -9,6 +11,8 @@ func Options() *options {
 type options struct {
 	included []string
 	excluded []string
+	allow    func(attribute *spec.Attribute) bool
+	full     bool
 }
 
 // Specify included attributes to the options.
@@ -28,3 +32,17 @@ func (opt *options) Exclude(fields ...string) *options {
 	opt.excluded = append(opt.excluded, fields...)
 	return opt
 }
+
+// Specify the function which decides whether the attribute may be returned, in addition to its returned-ability and
+// the included or excluded attributes. Attributes which are always returned are not subject to this function.
+func (opt *options) Allow(allow func(attribute *spec.Attribute) bool) *options {
+	opt.allow = allow
+	return opt
+}
+
+// Serialize all assigned attributes regardless of their returned-ability and mutability, as required to persist the
+// resource. Included, excluded and allowed attributes are ignored. The result must never be returned to clients.
+func (opt *options) Full() *options {
+	opt.full = true
+	return opt
+}
@@ -31,6 +31,8 @@ type (
 		bytes.Buffer
 		includes []string
 		excludes []string
+		allow    func(attribute *spec.Attribute) bool
+		full     bool
 		stack    []*frame
 		scratch  [64]byte
 	}
@@ -42,11 +44,19 @@ func Serialize(resource *prop.Resource, options *options) ([]byte, error) {
 		options = Options()
 	}
 
+	if options.full {
+		s := &serializer{full: true}
+		if err := resource.Visit(s); err != nil {
+			return nil, errors.Internal("JSON serialization error: %s", err.Error())
+		}
+		return s.Bytes(), nil
+	}
+
 	if len(options.included) > 0 && len(options.excluded) > 0 {
 		return nil, errors.InvalidRequest("only one of 'attributes' and 'excludedAttributes' may be used")
 	}
 
-	s := new(serializer)
+	s := &serializer{allow: options.allow}
 	if len(options.included) > 0 {
 		for _, path := range options.included {
 			if len(path) > 0 {
@@ -78,12 +88,20 @@ func Serialize(resource *prop.Resource, options *options) ([]byte, error) {
 func (s *serializer) ShouldVisit(property prop.Property) bool {
 	attr := property.Attribute()
 
+	if s.full {
+		return attr.Returned() == spec.ReturnedAlways || !property.IsUnassigned()
+	}
+
 	// Write only properties are never returned. It is usually coupled
 	// with returned=never, but we will check it to make sure.
 	if attr.Mutability() == spec.MutabilityWriteOnly {
 		return false
 	}
 
+	if s.allow != nil && attr.Returned() != spec.ReturnedAlways && !s.allow(attr) {
+		return false
+	}
+
 	switch attr.Returned() {
 	case spec.ReturnedAlways:
 		return true
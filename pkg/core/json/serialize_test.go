@@ -346,6 +346,96 @@ func (s *JSONSerializeTestSuite) TestSerialize() {
       }
    ]
 }
+`
+				assert.JSONEq(t, expect, string(raw))
+			},
+		},
+		{
+			name: "serialize with allow function",
+			getResource: func(t *testing.T) *prop.Resource {
+				_ = s.mustSchema("/user_schema.json")
+				resource := prop.NewResourceOf(s.mustResourceType("/user_resource_type.json"), map[string]interface{}{
+					"schemas": []interface{}{
+						"urn:ietf:params:scim:schemas:core:2.0:User",
+					},
+					"id": "3cc032f5-2361-417f-9e2f-bc80adddf4a3",
+					"meta": map[string]interface{}{
+						"resourceType": "User",
+						"version":      "W/\"1\"",
+					},
+					"userName": "imulab",
+					"name": map[string]interface{}{
+						"familyName": "Qiu",
+						"givenName":  "Weinan",
+					},
+					"displayName": "Weinan",
+					"active":      true,
+				})
+				require.NotNil(t, resource)
+				return resource
+			},
+			getOption: func() *options {
+				return Options().Allow(func(attribute *spec.Attribute) bool {
+					switch attribute.Path() {
+					case "userName", "name", "name.givenName":
+						return true
+					default:
+						return false
+					}
+				})
+			},
+			expect: func(t *testing.T, raw []byte, err error) {
+				assert.Nil(t, err)
+				expect := `
+{
+   "schemas":[
+      "urn:ietf:params:scim:schemas:core:2.0:User"
+   ],
+   "id":"3cc032f5-2361-417f-9e2f-bc80adddf4a3",
+   "userName":"imulab",
+   "name":{
+      "givenName":"Weinan"
+   }
+}
+`
+				assert.JSONEq(t, expect, string(raw))
+			},
+		},
+		{
+			name: "full serialize",
+			getResource: func(t *testing.T) *prop.Resource {
+				_ = s.mustSchema("/user_schema.json")
+				resource := prop.NewResourceOf(s.mustResourceType("/user_resource_type.json"), map[string]interface{}{
+					"schemas": []interface{}{
+						"urn:ietf:params:scim:schemas:core:2.0:User",
+					},
+					"id":       "3cc032f5-2361-417f-9e2f-bc80adddf4a3",
+					"userName": "imulab",
+					"password": "s3cret",
+					"meta": map[string]interface{}{
+						"version": "W/\"1\"",
+					},
+				})
+				require.NotNil(t, resource)
+				return resource
+			},
+			getOption: func() *options {
+				return Options().Full().Include("userName")
+			},
+			expect: func(t *testing.T, raw []byte, err error) {
+				assert.Nil(t, err)
+				expect := `
+{
+   "schemas":[
+      "urn:ietf:params:scim:schemas:core:2.0:User"
+   ],
+   "id":"3cc032f5-2361-417f-9e2f-bc80adddf4a3",
+   "userName":"imulab",
+   "password":"s3cret",
+   "meta":{
+      "version":"W/\"1\""
+   }
+}
 `
 				assert.JSONEq(t, expect, string(raw))
 			},
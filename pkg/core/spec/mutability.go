@@ -47,7 +47,7 @@ func (m Mutability) String() string {
 }
 
 func (m Mutability) MarshalJSON() ([]byte, error) {
-	return []byte(m.String()), nil
+	return json.Marshal(m.String())
 }
 
 var (
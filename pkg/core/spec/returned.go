@@ -47,7 +47,7 @@ func (r Returned) String() string {
 }
 
 func (r Returned) MarshalJSON() ([]byte, error) {
-	return []byte(r.String()), nil
+	return json.Marshal(r.String())
 }
 
 var (
@@ -3,6 +3,7 @@ package spec
 import (
 	"encoding/json"
 	"github.com/imulab/go-scim/pkg/core/annotations"
+	"sort"
 )
 
 // Central repository for schemas
@@ -109,6 +110,19 @@ func (h *schemaHub) MustGet(id string) *Schema {
 	return s
 }
 
+// Iterate all schemas in the hub in the order of their ids and invoke the callback function. The core schema is not
+// included, as it is not registered in the hub. Callback function SHALL NOT block.
+func (h *schemaHub) ForEachSchema(callback func(schema *Schema)) {
+	ids := make([]string, 0, len(h.schemaById))
+	for id := range h.schemaById {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		callback(h.schemaById[id])
+	}
+}
+
 // Return the core schema, which includes the common attributes of schemas, id, externalId, meta.
 func (h *schemaHub) CoreSchema() *Schema {
 	return &Schema{
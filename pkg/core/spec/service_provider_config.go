@@ -25,6 +25,14 @@ type ServiceProviderConfig struct {
 	ETag struct {
 		Supported bool `json:"supported"`
 	} `json:"etag"`
+	Pagination struct {
+		Cursor                  bool   `json:"cursor"`
+		Index                   bool   `json:"index"`
+		DefaultPaginationMethod string `json:"defaultPaginationMethod,omitempty"`
+		DefaultPageSize         int    `json:"defaultPageSize,omitempty"`
+		MaxPageSize             int    `json:"maxPageSize,omitempty"`
+		CursorTimeout           int    `json:"cursorTimeout,omitempty"`
+	} `json:"pagination"`
 	AuthSchemes []struct {
 		Type        string `json:"type"`
 		Name        string `json:"name"`
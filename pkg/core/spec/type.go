@@ -67,7 +67,7 @@ func (t Type) String() string {
 }
 
 func (t Type) MarshalJSON() ([]byte, error) {
-	return []byte(t.String()), nil
+	return json.Marshal(t.String())
 }
 
 var (
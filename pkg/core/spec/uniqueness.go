@@ -42,7 +42,7 @@ func (u Uniqueness) String() string {
 }
 
 func (u Uniqueness) MarshalJSON() ([]byte, error) {
-	return []byte(u.String()), nil
+	return json.Marshal(u.String())
 }
 
 var (
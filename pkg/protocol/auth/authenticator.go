@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"strings"
+)
+
+const (
+	headerAuthorization   = "Authorization"
+	headerWWWAuthenticate = "WWW-Authenticate"
+	defaultRealm          = "scim"
+)
+
+// Authenticator verifies the Authorization header of requests with the verifier(s) registered for its scheme. When
+// more than one verifier is registered for the same scheme (i.e. static bearer tokens and JWT), they are attempted in
+// order until one succeeds.
+type Authenticator struct {
+	Log       log.Logger
+	Verifiers []Verifier
+	// Realm reported in the WWW-Authenticate challenges. Defaults to "scim".
+	Realm string
+}
+
+// Authenticate the request and return the principal, or return an error.
+func (a *Authenticator) Authenticate(request http.Request) (*Principal, error) {
+	header := strings.TrimSpace(request.Header(headerAuthorization))
+	if len(header) == 0 {
+		return nil, errors.Unauthorized("request is not authenticated")
+	}
+
+	i := strings.IndexByte(header, ' ')
+	if i < 0 {
+		return nil, errors.Unauthorized("malformed authorization header")
+	}
+	scheme, credentials := header[:i], strings.TrimSpace(header[i+1:])
+
+	err := errors.Unauthorized("authentication scheme '%s' is not supported", scheme)
+	for _, verifier := range a.Verifiers {
+		if !strings.EqualFold(verifier.Scheme(), scheme) {
+			continue
+		}
+		principal, verifyErr := verifier.Verify(request.Context(), credentials)
+		if verifyErr == nil {
+			return principal, nil
+		}
+		err = verifyErr
+		if scimError, ok := verifyErr.(*errors.Error); !ok || scimError.Type != errors.TypeUnauthorized {
+			break
+		}
+	}
+	return nil, err
+}
+
+// Wrap the handler function so that it is only invoked for authenticated requests, whose context carries the
+// authenticated principal (see PrincipalFrom). Unauthenticated requests are responded with 401 and WWW-Authenticate
+// challenges for every supported scheme.
+func (a *Authenticator) Wrap(next func(request http.Request, response http.Response)) func(request http.Request, response http.Response) {
+	return func(request http.Request, response http.Response) {
+		principal, err := a.Authenticate(request)
+		if err != nil {
+			a.Log.Info("request failed authentication: %s", err.Error())
+			a.writeError(response, err)
+			return
+		}
+		next(&authenticatedRequest{Request: request, ctx: WithPrincipal(request.Context(), principal)}, response)
+	}
+}
+
+func (a *Authenticator) writeError(response http.Response, err error) {
+	scimError, ok := err.(*errors.Error)
+	if !ok {
+		scimError = errors.Internal(err.Error()).(*errors.Error)
+	}
+	if scimError.Status == 401 {
+		for _, challenge := range a.challenges() {
+			response.WriteHeader(headerWWWAuthenticate, challenge)
+		}
+	}
+	response.WriteSCIMContentType()
+	response.WriteStatus(scimError.Status)
+	raw, _ := json.Marshal(scimError)
+	response.WriteBody(raw)
+}
+
+// Returns a challenge for each distinct scheme of the verifiers, in the order they are registered.
+func (a *Authenticator) challenges() []string {
+	realm := a.Realm
+	if len(realm) == 0 {
+		realm = defaultRealm
+	}
+
+	seen := make(map[string]struct{})
+	challenges := make([]string, 0, len(a.Verifiers))
+	for _, verifier := range a.Verifiers {
+		if _, ok := seen[verifier.Scheme()]; ok {
+			continue
+		}
+		seen[verifier.Scheme()] = struct{}{}
+		challenges = append(challenges, fmt.Sprintf("%s realm=%q", verifier.Scheme(), realm))
+	}
+	return challenges
+}
+
+// Request whose context carries the authenticated principal.
+type authenticatedRequest struct {
+	http.Request
+	ctx context.Context
+}
+
+func (r *authenticatedRequest) Context() context.Context {
+	return r.ctx
+}
+
+func (r *authenticatedRequest) Unwrap() http.Request {
+	return r.Request
+}
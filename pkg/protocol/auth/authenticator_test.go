@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAuthenticator(t *testing.T) {
+	s := new(AuthenticatorTestSuite)
+	s.resourceBase = "../../tests/authenticator_test_suite"
+	suite.Run(t, s)
+}
+
+type AuthenticatorTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *AuthenticatorTestSuite) TestWrap() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	database := db.Memory()
+	require.Nil(s.T(), database.Insert(context.Background(), s.mustResource("/user_001.json", resourceType)))
+
+	secret := []byte("jwt-secret")
+	authenticator := &Authenticator{
+		Log: log.None(),
+		Verifiers: []Verifier{
+			Basic(database),
+			StaticBearer(map[string]string{"t0ken": "admin"}),
+			HMACJWT(secret),
+		},
+	}
+
+	basic := func(userName, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(userName+":"+password))
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		expect        func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal)
+	}{
+		{
+			name:          "basic",
+			authorization: basic("user001", "s3cret"),
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 200, rr.Code)
+				require.NotNil(t, principal)
+				assert.Equal(t, "user001", principal.Subject)
+				assert.Equal(t, TypeHTTPBasic, principal.Scheme)
+			},
+		},
+		{
+			name:          "basic with wrong password",
+			authorization: basic("user001", "wrong"),
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 401, rr.Code)
+				assert.Nil(t, principal)
+			},
+		},
+		{
+			name:          "basic with unknown user",
+			authorization: basic("user002", "s3cret"),
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 401, rr.Code)
+				assert.Nil(t, principal)
+			},
+		},
+		{
+			name:          "static bearer",
+			authorization: "Bearer t0ken",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 200, rr.Code)
+				require.NotNil(t, principal)
+				assert.Equal(t, "admin", principal.Subject)
+				assert.Equal(t, TypeOAuthBearerToken, principal.Scheme)
+			},
+		},
+		{
+			name: "jwt",
+			authorization: "Bearer " + s.signJWT(secret, map[string]interface{}{
+				"sub": "user001",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}),
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 200, rr.Code)
+				require.NotNil(t, principal)
+				assert.Equal(t, "user001", principal.Subject)
+			},
+		},
+		{
+			name: "expired jwt",
+			authorization: "Bearer " + s.signJWT(secret, map[string]interface{}{
+				"sub": "user001",
+				"exp": time.Now().Add(-time.Hour).Unix(),
+			}),
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 401, rr.Code)
+				assert.Nil(t, principal)
+			},
+		},
+		{
+			name:          "jwt with wrong secret",
+			authorization: "Bearer " + s.signJWT([]byte("other"), map[string]interface{}{"sub": "user001"}),
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 401, rr.Code)
+				assert.Nil(t, principal)
+			},
+		},
+		{
+			name:          "unsupported scheme",
+			authorization: "Digest foo",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 401, rr.Code)
+				assert.Nil(t, principal)
+			},
+		},
+		{
+			name: "missing authorization",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, principal *Principal) {
+				assert.Equal(t, 401, rr.Code)
+				assert.Nil(t, principal)
+				assert.Equal(t, []string{`Basic realm="scim"`, `Bearer realm="scim"`}, rr.Header()["Www-Authenticate"])
+				assert.Equal(t, "application/json+scim", rr.Header().Get("Content-Type"))
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "401", body["status"])
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			var principal *Principal
+			fn := authenticator.Wrap(func(request http.Request, response http.Response) {
+				principal = PrincipalFrom(request.Context())
+				response.WriteStatus(200)
+			})
+
+			req := httptest.NewRequest("GET", "/Me", nil)
+			if len(test.authorization) > 0 {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			rr := httptest.NewRecorder()
+			fn(http.DefaultRequest(req, nil), http.DefaultResponse(rr))
+			test.expect(t, rr, principal)
+		})
+	}
+}
+
+func (s *AuthenticatorTestSuite) signJWT(secret []byte, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	s.Require().Nil(err)
+	payload, err := json.Marshal(claims)
+	s.Require().Nil(err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *AuthenticatorTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	resource := prop.NewResource(resourceType)
+	err = scimJSON.Deserialize(raw, resource)
+	s.Require().Nil(err)
+
+	return resource
+}
+
+func (s *AuthenticatorTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *AuthenticatorTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"hash"
+	"strings"
+	"time"
+)
+
+// Returns a Verifier for bearer tokens in the form of JSON Web Tokens signed with HMAC (HS256, HS384 or HS512) using
+// the shared secret. The token must carry the 'sub' claim, which becomes the subject of the principal. The 'exp' and
+// 'nbf' claims are honored when present.
+func HMACJWT(secret []byte) Verifier {
+	return &hmacJWTVerifier{secret: secret, now: time.Now}
+}
+
+type hmacJWTVerifier struct {
+	secret []byte
+	now    func() time.Time
+}
+
+func (v *hmacJWTVerifier) Type() string {
+	return TypeOAuthBearerToken
+}
+
+func (v *hmacJWTVerifier) Scheme() string {
+	return "Bearer"
+}
+
+func (v *hmacJWTVerifier) Verify(_ context.Context, credentials string) (*Principal, error) {
+	parts := strings.Split(credentials, ".")
+	if len(parts) != 3 {
+		return nil, errors.Unauthorized("malformed token")
+	}
+
+	header := new(struct {
+		Alg string `json:"alg"`
+	})
+	if err := decodeSegment(parts[0], header); err != nil {
+		return nil, err
+	}
+	var hashFunc func() hash.Hash
+	switch header.Alg {
+	case "HS256":
+		hashFunc = sha256.New
+	case "HS384":
+		hashFunc = sha512.New384
+	case "HS512":
+		hashFunc = sha512.New
+	default:
+		return nil, errors.Unauthorized("token algorithm '%s' is not supported", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.Unauthorized("malformed token")
+	}
+	mac := hmac.New(hashFunc, v.secret)
+	_, _ = mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.Unauthorized("invalid token signature")
+	}
+
+	claims := new(struct {
+		Sub string   `json:"sub"`
+		Exp *float64 `json:"exp"`
+		Nbf *float64 `json:"nbf"`
+	})
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, err
+	}
+	now := float64(v.now().Unix())
+	if claims.Exp != nil && now >= *claims.Exp {
+		return nil, errors.Unauthorized("token is expired")
+	}
+	if claims.Nbf != nil && now < *claims.Nbf {
+		return nil, errors.Unauthorized("token is not yet valid")
+	}
+	if len(claims.Sub) == 0 {
+		return nil, errors.Unauthorized("token has no subject")
+	}
+
+	return &Principal{Subject: claims.Sub, Scheme: TypeOAuthBearerToken}, nil
+}
+
+// Decode the base64url encoded JSON segment of the token into v.
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.Unauthorized("malformed token")
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return errors.Unauthorized("malformed token")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"strings"
+)
+
+// Operation on the attributes of a resource which is subject to authorization.
+type Operation string
+
+const (
+	OperationRead   Operation = "read"
+	OperationCreate Operation = "create"
+	OperationUpdate Operation = "update"
+	// Deleting a resource removes all of its attributes, hence it is only allowed by rules whose paths include "*".
+	OperationDelete Operation = "delete"
+
+	// Matches any authenticated subject, or any attribute path.
+	wildcard = "*"
+)
+
+// Rule that allows principals to perform operations on attributes of resources.
+type Rule struct {
+	// Subjects of the principals this rule applies to. "*" matches any authenticated principal.
+	Subjects []string `json:"subjects"`
+	// If true, this rule only applies when the principal operates on its own resource, as decided by Policy.Owner.
+	Self bool `json:"self"`
+	// Id of the resource type this rule applies to. Empty matches any resource type.
+	ResourceType string `json:"resourceType"`
+	// Operations allowed by this rule.
+	Operations []Operation `json:"operations"`
+	// Paths of the attributes which the operations are allowed on, including their sub attributes. "*" matches
+	// all attributes.
+	Paths []string `json:"paths"`
+}
+
+// Policy to authorize operations on attributes of resources with rules. An operation on an attribute is allowed if any
+// rule allows it, and denied otherwise. Requests without an authenticated principal are denied.
+type Policy struct {
+	Rules []*Rule `json:"rules"`
+	// Returns true if the resource is owned by the principal. When nil, a resource is owned by the principal whose
+	// subject equals to its userName, consistent with the /Me endpoint.
+	Owner func(principal *Principal, resource *prop.Resource) bool `json:"-"`
+}
+
+// Returns true if the principal in the context is allowed to perform the operation on the attribute of the resource.
+func (p *Policy) Allowed(ctx context.Context, op Operation, resource *prop.Resource, attribute *spec.Attribute) bool {
+	return p.Allows(ctx, op, resource)(attribute)
+}
+
+// Returns a function which reports whether the principal in the context is allowed to perform the operation on the
+// attribute of the resource. The rules applicable to the principal, operation and resource are selected once, so the
+// returned function is cheap to call for every attribute of the resource.
+func (p *Policy) Allows(ctx context.Context, op Operation, resource *prop.Resource) func(attribute *spec.Attribute) bool {
+	return allows(p.paths(ctx, op, resource.ResourceType(), resource))
+}
+
+// Returns a function which reports whether the principal in the context is allowed to perform the operation on the
+// attribute of every resource of the resource type. Unlike Allows, rules which only apply to the principal's own
+// resource are not considered, as no particular resource is known.
+func (p *Policy) AllowsResourceType(ctx context.Context, op Operation, resourceType *spec.ResourceType) func(attribute *spec.Attribute) bool {
+	return allows(p.paths(ctx, op, resourceType, nil))
+}
+
+func allows(paths []string) func(attribute *spec.Attribute) bool {
+	return func(attribute *spec.Attribute) bool {
+		for _, path := range paths {
+			if pathAllows(path, attribute.Path()) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Returns true if the principal in the context is allowed to perform the operation on all attributes of the resource,
+// which is required to delete the resource.
+func (p *Policy) AllowedAll(ctx context.Context, op Operation, resource *prop.Resource) bool {
+	for _, path := range p.paths(ctx, op, resource.ResourceType(), resource) {
+		if path == wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the paths of the rules applicable to the principal in the context, the operation and the resource of the
+// resource type. Rules which only apply to the principal's own resource are skipped when the resource is nil.
+func (p *Policy) paths(ctx context.Context, op Operation, resourceType *spec.ResourceType, resource *prop.Resource) []string {
+	paths := make([]string, 0)
+
+	principal := PrincipalFrom(ctx)
+	if principal == nil {
+		return paths
+	}
+
+	var isOwner *bool
+	for _, rule := range p.Rules {
+		if !rule.appliesTo(principal, op, resourceType) {
+			continue
+		}
+		if rule.Self {
+			if resource == nil {
+				continue
+			}
+			if isOwner == nil {
+				owned := p.owns(principal, resource)
+				isOwner = &owned
+			}
+			if !*isOwner {
+				continue
+			}
+		}
+		paths = append(paths, rule.Paths...)
+	}
+	return paths
+}
+
+func (p *Policy) owns(principal *Principal, resource *prop.Resource) bool {
+	if p.Owner != nil {
+		return p.Owner(principal, resource)
+	}
+	userName, err := resource.NewNavigator().FocusName("userName")
+	if err != nil || userName.IsUnassigned() {
+		return false
+	}
+	return userName.Raw() == principal.Subject
+}
+
+func (r *Rule) appliesTo(principal *Principal, op Operation, resourceType *spec.ResourceType) bool {
+	if len(r.ResourceType) > 0 && r.ResourceType != resourceType.ID() {
+		return false
+	}
+
+	hasOp := false
+	for _, each := range r.Operations {
+		if each == op {
+			hasOp = true
+			break
+		}
+	}
+	if !hasOp {
+		return false
+	}
+
+	for _, subject := range r.Subjects {
+		if subject == wildcard || subject == principal.Subject {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the rule path allows the attribute path. A rule path allows the attribute at the path, and all of
+// its sub attributes. It also allows the containing attributes of the path, so that the allowed sub attributes can
+// be reached.
+func pathAllows(rulePath string, attributePath string) bool {
+	if rulePath == wildcard {
+		return true
+	}
+	rulePath, attributePath = strings.ToLower(rulePath), strings.ToLower(attributePath)
+	return rulePath == attributePath ||
+		strings.HasPrefix(attributePath, rulePath+".") ||
+		strings.HasPrefix(rulePath, attributePath+".")
+}
@@ -0,0 +1,26 @@
+package auth
+
+import "context"
+
+// The authenticated subject of a request.
+type Principal struct {
+	// Identifier of the subject, i.e. the user name of basic authentication, or the subject of a token.
+	Subject string
+	// Type of the authentication scheme which authenticated the subject, i.e. httpbasic or oauthbearertoken.
+	Scheme string
+}
+
+type principalKey struct{}
+
+// Return a copy of the context carrying the authenticated principal.
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// Return the authenticated principal carried by the context, or nil if the request was not authenticated.
+func PrincipalFrom(ctx context.Context) *Principal {
+	if p, ok := ctx.Value(principalKey{}).(*Principal); ok {
+		return p
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/protocol/db"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	// Authentication scheme types, as advertised in the authenticationSchemes of ServiceProviderConfig.
+	TypeHTTPBasic        = "httpbasic"
+	TypeOAuthBearerToken = "oauthbearertoken"
+)
+
+// Verifier of the credentials carried by the Authorization header.
+type Verifier interface {
+	// Returns the type of the authentication scheme, as advertised in the authenticationSchemes of
+	// ServiceProviderConfig (i.e. httpbasic, oauthbearertoken).
+	Type() string
+	// Returns the scheme of the Authorization header that this verifier accepts (i.e. Basic, Bearer).
+	Scheme() string
+	// Verify the credentials following the scheme in the Authorization header, and return the authenticated principal.
+	// Invalid credentials are reported with errors.Unauthorized; other errors indicate the credentials could not be
+	// verified at all.
+	Verify(ctx context.Context, credentials string) (*Principal, error)
+}
+
+// Returns a Verifier for HTTP Basic authentication. The user name is matched against the userName attribute of the
+// resources in the database, and the password is compared against the bcrypt hash in their password attribute.
+func Basic(database db.DB) Verifier {
+	return &basicVerifier{database: database}
+}
+
+type basicVerifier struct {
+	database db.DB
+}
+
+func (v *basicVerifier) Type() string {
+	return TypeHTTPBasic
+}
+
+func (v *basicVerifier) Scheme() string {
+	return "Basic"
+}
+
+func (v *basicVerifier) Verify(ctx context.Context, credentials string) (*Principal, error) {
+	raw, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return nil, errors.Unauthorized("malformed basic credentials")
+	}
+	i := strings.IndexByte(string(raw), ':')
+	if i < 0 {
+		return nil, errors.Unauthorized("malformed basic credentials")
+	}
+	userName, password := string(raw[:i]), string(raw[i+1:])
+
+	quoted, _ := json.Marshal(userName)
+	resources, err := v.database.Query(ctx, "userName eq "+string(quoted), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	} else if len(resources) != 1 {
+		return nil, errors.Unauthorized("invalid user name or password")
+	}
+
+	pwdProp, err := resources[0].NewNavigator().FocusName("password")
+	if err != nil || pwdProp.IsUnassigned() {
+		return nil, errors.Unauthorized("invalid user name or password")
+	}
+	hashed, ok := pwdProp.Raw().(string)
+	if !ok || bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) != nil {
+		return nil, errors.Unauthorized("invalid user name or password")
+	}
+
+	return &Principal{Subject: userName, Scheme: TypeHTTPBasic}, nil
+}
+
+// Returns a Verifier for static bearer tokens, which maps each token to the subject it authenticates.
+func StaticBearer(tokens map[string]string) Verifier {
+	return &staticBearerVerifier{tokens: tokens}
+}
+
+type staticBearerVerifier struct {
+	tokens map[string]string
+}
+
+func (v *staticBearerVerifier) Type() string {
+	return TypeOAuthBearerToken
+}
+
+func (v *staticBearerVerifier) Scheme() string {
+	return "Bearer"
+}
+
+func (v *staticBearerVerifier) Verify(_ context.Context, credentials string) (*Principal, error) {
+	for token, subject := range v.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(credentials)) == 1 {
+			return &Principal{Subject: subject, Scheme: TypeOAuthBearerToken}, nil
+		}
+	}
+	return nil, errors.Unauthorized("invalid bearer token")
+}
@@ -57,3 +57,49 @@ func Delete(resource *prop.Resource, path string) error {
 		return target.Delete()
 	})
 }
+
+// Get the assigned properties in the SCIM resource at the given SCIM path, which may be qualified by the namespace of
+// a schema, and may contain value filters to select elements of multiValued properties. All matching properties are
+// returned, or an empty slice if none matches. A noTarget error is returned only when the path does not address an
+// attribute of the resource type.
+func Get(resource *prop.Resource, path string) ([]prop.Property, error) {
+	if len(path) == 0 {
+		return nil, errors.InvalidPath("path must not be empty when getting from resource")
+	}
+
+	head, err := expr.CompilePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// validate ahead, as properties of absent elements are never visited
+	resourceType := resource.ResourceType()
+	if _, err := validatePath(head, resourceType.SuperAttribute(true), resourceType.Schema().ID()); err != nil {
+		return nil, errors.NoTarget("path '%s' does not address an attribute: %s", path, err.Error())
+	}
+
+	targets := make([]prop.Property, 0)
+	err = traverse(resource.NewNavigator(), skipMainSchemaNamespace(resource, head), func(target prop.Property) error {
+		if !target.IsUnassigned() {
+			targets = append(targets, target)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// Get the raw values of the assigned properties in the SCIM resource at the given SCIM path. See Get.
+func GetRaw(resource *prop.Resource, path string) ([]interface{}, error) {
+	targets, err := Get(resource, path)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, 0, len(targets))
+	for _, target := range targets {
+		values = append(values, target.Raw())
+	}
+	return values, nil
+}
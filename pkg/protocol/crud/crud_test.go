@@ -2,11 +2,13 @@ package crud
 
 import (
 	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
 	"github.com/imulab/go-scim/pkg/core/expr"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"io/ioutil"
 	"os"
@@ -420,6 +422,207 @@ func (s *CRUDTestSuite) TestDelete() {
 	}
 }
 
+func (s *CRUDTestSuite) TestGet() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_enterprise_extension_schema.json")
+	resourceType := s.mustResourceType("/user_enterprise_resource_type.json")
+	expr.Register(resourceType)
+
+	tests := []struct {
+		name   string
+		file   string
+		path   string
+		expect func(t *testing.T, values []interface{}, err error)
+	}{
+		{
+			name: "get top level simple property",
+			file: "/user_001.json",
+			path: "userName",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{"imulab"}, values)
+			},
+		},
+		{
+			name: "get with namespace of main schema",
+			file: "/user_001.json",
+			path: "urn:ietf:params:scim:schemas:core:2.0:User:name.givenName",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{"Weinan"}, values)
+			},
+		},
+		{
+			name: "get with namespace of schema extension",
+			file: "/user_002.json",
+			path: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{"8f33f3e4-33ff-4549-adc1-12694c9cbdc3"}, values)
+			},
+		},
+		{
+			name: "get sub property of all elements",
+			file: "/user_001.json",
+			path: "phoneNumbers.value",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{"123-45678", "123-45679"}, values)
+			},
+		},
+		{
+			name: "get with value filter",
+			file: "/user_001.json",
+			path: "emails[type eq \"work\"].value",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{"imulab@foo.com"}, values)
+			},
+		},
+		{
+			name: "get with value filter matching nothing",
+			file: "/user_001.json",
+			path: "emails[type eq \"other\"].value",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Empty(t, values)
+			},
+		},
+		{
+			name: "get unassigned property",
+			file: "/user_001.json",
+			path: "nickName",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Empty(t, values)
+			},
+		},
+		{
+			name: "get sub property of absent schema extension",
+			file: "/user_001.json",
+			path: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Empty(t, values)
+			},
+		},
+		{
+			name: "get non existing field yields error",
+			file: "/user_001.json",
+			path: "foobar",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeNoTarget, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name: "get non existing sub field of elements yields error",
+			file: "/user_001.json",
+			path: "x509Certificates.foobar",
+			expect: func(t *testing.T, values []interface{}, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeNoTarget, err.(*errors.Error).Type)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			values, err := GetRaw(s.mustResource(test.file, resourceType), test.path)
+			test.expect(t, values, err)
+		})
+	}
+}
+
+func (s *CRUDTestSuite) TestProject() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	tests := []struct {
+		name       string
+		projection *Projection
+		expect     func(t *testing.T, r *prop.Resource, err error)
+	}{
+		{
+			name: "no projection keeps all attributes",
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "Weinan", r.NewFluentNavigator().FocusName("displayName").Current().Raw())
+				assert.False(t, r.NewFluentNavigator().FocusName("emails").Current().IsUnassigned())
+			},
+		},
+		{
+			name:       "attributes keep included attributes",
+			projection: &Projection{Attributes: []string{"userName", "name.givenName"}},
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "imulab", r.NewFluentNavigator().FocusName("userName").Current().Raw())
+				assert.Equal(t, "Weinan", r.NewFluentNavigator().FocusName("name").FocusName("givenName").Current().Raw())
+				assert.Nil(t, r.NewFluentNavigator().FocusName("name").FocusName("familyName").Current().Raw())
+				assert.True(t, r.NewFluentNavigator().FocusName("displayName").Current().IsUnassigned())
+				assert.True(t, r.NewFluentNavigator().FocusName("emails").Current().IsUnassigned())
+			},
+		},
+		{
+			name:       "attributes keep always returned attributes and meta",
+			projection: &Projection{Attributes: []string{"urn:ietf:params:scim:schemas:core:2.0:User:userName"}},
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "imulab", r.NewFluentNavigator().FocusName("userName").Current().Raw())
+				assert.False(t, r.NewFluentNavigator().FocusName("schemas").Current().IsUnassigned())
+				assert.Equal(t, "foo", r.ID())
+				assert.Equal(t, "W/\"1\"", r.Version())
+				assert.Nil(t, r.NewFluentNavigator().FocusName("meta").FocusName("resourceType").Current().Raw())
+			},
+		},
+		{
+			name:       "attributes keep included sub attributes of multiValued attributes",
+			projection: &Projection{Attributes: []string{"emails.value"}},
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []interface{}{
+					map[string]interface{}{"value": "imulab@foo.com", "type": nil, "primary": nil, "display": nil},
+					map[string]interface{}{"value": "imulab@bar.com", "type": nil, "primary": nil, "display": nil},
+				}, r.NewFluentNavigator().FocusName("emails").Current().Raw())
+			},
+		},
+		{
+			name:       "excluded attributes are deleted",
+			projection: &Projection{ExcludedAttributes: []string{"emails", "name.givenName", "meta"}},
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, "imulab", r.NewFluentNavigator().FocusName("userName").Current().Raw())
+				assert.Equal(t, "Qiu", r.NewFluentNavigator().FocusName("name").FocusName("familyName").Current().Raw())
+				assert.Nil(t, r.NewFluentNavigator().FocusName("name").FocusName("givenName").Current().Raw())
+				assert.True(t, r.NewFluentNavigator().FocusName("emails").Current().IsUnassigned())
+				assert.Equal(t, "W/\"1\"", r.Version())
+			},
+		},
+		{
+			name: "attributes and excluded attributes yields error",
+			projection: &Projection{
+				Attributes:         []string{"userName"},
+				ExcludedAttributes: []string{"emails"},
+			},
+			expect: func(t *testing.T, r *prop.Resource, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			r := s.mustResource("/user_001.json", resourceType)
+			require.Nil(t, Replace(r, "id", "foo"))
+			require.Nil(t, Replace(r, "meta.resourceType", "User"))
+			require.Nil(t, Replace(r, "meta.version", "W/\"1\""))
+			err := Project(r, test.projection)
+			test.expect(t, r, err)
+		})
+	}
+}
+
 func (s *CRUDTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
 	f, err := os.Open(s.resourceBase + filePath)
 	s.Require().Nil(err)
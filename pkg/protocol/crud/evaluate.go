@@ -40,8 +40,18 @@ func Evaluate(property prop.Property, filter *expr.Expression) (bool, error) {
 		}
 	}
 
-	if filter.Left().ContainsFilter() {
-		return false, errors.InvalidFilter("nested filter is not allowed")
+	// A value path filter, i.e. emails[type eq "work" and value co "@foo.com"], matches if any element of the
+	// multiValued property matches the value filter. All conditions of the value filter are evaluated against the
+	// same element.
+	if filter.IsPath() {
+		matched := false
+		if err := traverse(prop.NewNavigator(property), filter, func(target prop.Property) error {
+			matched = true
+			return nil
+		}); err != nil {
+			return false, errors.InvalidFilter(err.Error())
+		}
+		return matched, nil
 	}
 
 	// Normally, we are expecting a single boolean result. For instance, conventional filters like
@@ -81,7 +91,7 @@ func Evaluate(property prop.Property, filter *expr.Expression) (bool, error) {
 		)
 
 		if filter.Token() != expr.Pr {
-			value, fe = normalize(target.Attribute(), filter.Right().Token())
+			value, fe = Normalize(target.Attribute(), filter.Right().Token())
 			if fe != nil {
 				return
 			}
@@ -154,8 +164,10 @@ func Evaluate(property prop.Property, filter *expr.Expression) (bool, error) {
 	return false, nil
 }
 
-// Take the raw string presentation of a value and normalize it to corresponding types according to the attribute.
-func normalize(attr *spec.Attribute, token string) (interface{}, error) {
+// Take the raw string presentation of a filter literal and normalize it to the corresponding type of the attribute:
+// string for string, dateTime, binary and reference attributes, int64 for integer, float64 for decimal and bool for
+// boolean attributes.
+func Normalize(attr *spec.Attribute, token string) (interface{}, error) {
 	switch attr.Type() {
 	case spec.TypeString, spec.TypeDateTime, spec.TypeBinary, spec.TypeReference:
 		if strings.HasPrefix(token, "\"") && strings.HasSuffix(token, "\"") {
@@ -0,0 +1,85 @@
+package crud
+
+import (
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"strings"
+)
+
+// Apply the projection to the resource by deleting the attributes that are not included, or that are excluded, by the
+// projection. Attributes whose returned-ability is always, and the meta.version and meta.location attributes which
+// services rely on to identify the state and the location of the resource, are kept regardless of the projection. A
+// nil or empty projection keeps all attributes.
+//
+// As the resource is modified in place, database implementations should project a clone of the stored resource.
+func Project(resource *prop.Resource, projection *Projection) error {
+	if projection == nil || (len(projection.Attributes) == 0 && len(projection.ExcludedAttributes) == 0) {
+		return nil
+	}
+	if len(projection.Attributes) > 0 && len(projection.ExcludedAttributes) > 0 {
+		return errors.InvalidRequest("only one of 'attributes' and 'excludedAttributes' may be used")
+	}
+
+	var (
+		namespace = strings.ToLower(resource.ResourceType().Schema().ID() + ":")
+		normalize = func(paths []string) []string {
+			normalized := make([]string, 0, len(paths))
+			for _, path := range paths {
+				if len(path) > 0 {
+					normalized = append(normalized, strings.TrimPrefix(strings.ToLower(path), namespace))
+				}
+			}
+			return normalized
+		}
+		includes = normalize(projection.Attributes)
+		excludes = normalize(projection.ExcludedAttributes)
+	)
+
+	keep := func(attr *spec.Attribute) bool {
+		if attr.Returned() == spec.ReturnedAlways {
+			return true
+		}
+		test := strings.ToLower(attr.Path())
+		switch test {
+		case "meta", "meta.version", "meta.location":
+			return true
+		}
+		if len(includes) > 0 {
+			for _, include := range includes {
+				if include == test || strings.HasPrefix(include, test+".") || strings.HasPrefix(test, include+".") {
+					return true
+				}
+			}
+			return false
+		}
+		for _, exclude := range excludes {
+			if exclude == test || strings.HasPrefix(test, exclude+".") {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Deletion is deferred until the traversal completes, as deleting may compact multiValued properties.
+	trimmed := make([]prop.Property, 0)
+	var visit func(property prop.Property)
+	visit = func(property prop.Property) {
+		_ = property.(prop.Container).ForEachChild(func(_ int, child prop.Property) error {
+			if !keep(child.Attribute()) {
+				trimmed = append(trimmed, child)
+			} else if child.Attribute().MultiValued() || child.Attribute().Type() == spec.TypeComplex {
+				visit(child)
+			}
+			return nil
+		})
+	}
+	visit(resource.NewNavigator().Current())
+
+	for _, property := range trimmed {
+		if err := property.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -31,6 +31,12 @@ type (
 		StartIndex int
 		Count      int
 	}
+	// Cursor based pagination. Cursor is empty for the first page, and is the next cursor returned along with the
+	// previous page otherwise. A zero Count requests the default page size.
+	CursorPagination struct {
+		Cursor string
+		Count  int
+	}
 )
 
 // Sort the given list of resources according to the sort options.
@@ -0,0 +1,127 @@
+package crud
+
+import (
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"strings"
+)
+
+// Validate the compiled SCIM filter against the attributes of the resource type, so that filters which can never be
+// evaluated are rejected before any resource is visited. The paths in the filter must address attributes of the
+// resource type, the operators must be applicable to the type of the attributes, and the literals must be of the type
+// of the attributes. Any violation is reported as an invalidFilter error.
+func Validate(filter *expr.Expression, resourceType *spec.ResourceType) error {
+	return validateFilter(filter, resourceType.SuperAttribute(true), resourceType.Schema().ID())
+}
+
+func validateFilter(filter *expr.Expression, container *spec.Attribute, namespace string) error {
+	if filter == nil {
+		return errors.InvalidFilter("filter is invalid")
+	}
+
+	switch strings.ToLower(filter.Token()) {
+	case expr.And, expr.Or:
+		if err := validateFilter(filter.Left(), container, namespace); err != nil {
+			return err
+		}
+		return validateFilter(filter.Right(), container, namespace)
+	case expr.Not:
+		return validateFilter(filter.Left(), container, namespace)
+	}
+
+	// value path filter, i.e. emails[type eq "work"]
+	if filter.IsPath() {
+		if !filter.ContainsFilter() {
+			return errors.InvalidFilter("'%s' is not a filter", filter.Token())
+		}
+		_, err := validatePath(filter, container, namespace)
+		return err
+	}
+
+	if !filter.IsRelationalOperator() || filter.Left() == nil || !filter.Left().IsPath() {
+		return errors.InvalidFilter("filter is invalid")
+	}
+
+	attr, err := validatePath(filter.Left(), container, namespace)
+	if err != nil {
+		return err
+	}
+
+	op := strings.ToLower(filter.Token())
+	if op == expr.Pr {
+		return nil
+	}
+	if !operatorApplies(op, attr) {
+		return errors.InvalidFilter("operator '%s' cannot be applied to '%s' of type %s", op, attr.Path(), attr.Type().String())
+	}
+
+	if filter.Right() == nil || !filter.Right().IsLiteral() {
+		return errors.InvalidFilter("operator '%s' on '%s' expects a value", op, attr.Path())
+	}
+	if _, err := Normalize(attr, filter.Right().Token()); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Follow the path down the sub attributes of the container, and return the attribute at the end of the path. The
+// path may optionally be prefixed with the namespace of the main schema. Filters on multiValued attributes within the
+// path are validated against the sub attributes of the multiValued attribute.
+func validatePath(path *expr.Expression, container *spec.Attribute, namespace string) (*spec.Attribute, error) {
+	if path.IsPath() && strings.ToLower(path.Token()) == strings.ToLower(namespace) {
+		path = path.Next()
+	}
+	if path == nil {
+		return nil, errors.InvalidFilter("filter path is incomplete")
+	}
+
+	var attr = container
+	for step := path; step != nil; step = step.Next() {
+		if step.IsRootOfFilter() {
+			if attr == container || !attr.MultiValued() {
+				return nil, errors.InvalidFilter("filter cannot be applied to singular attribute '%s'", attr.Path())
+			}
+			if err := validateFilter(step, attr, ""); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if attr.Type() != spec.TypeComplex {
+			return nil, errors.InvalidFilter("'%s' does not have sub attribute '%s'", attr.Path(), step.Token())
+		}
+		sub := attr.SubAttributeForName(step.Token())
+		if sub == nil {
+			return nil, errors.InvalidFilter("'%s' is not a valid attribute", step.Token())
+		}
+		attr = sub
+	}
+
+	return attr, nil
+}
+
+// Returns true if the comparison operator can be applied to the attribute. Complex attributes are only subject to the
+// presence check; boolean and binary attributes are not ordered; and substring operators only apply to string values.
+func operatorApplies(op string, attr *spec.Attribute) bool {
+	switch attr.Type() {
+	case spec.TypeComplex:
+		return false
+	case spec.TypeBoolean:
+		return op == expr.Eq || op == expr.Ne
+	case spec.TypeBinary:
+		switch op {
+		case expr.Gt, expr.Ge, expr.Lt, expr.Le:
+			return false
+		}
+		return true
+	case spec.TypeInteger, spec.TypeDecimal:
+		switch op {
+		case expr.Sw, expr.Ew, expr.Co:
+			return false
+		}
+		return true
+	default:
+		return true
+	}
+}
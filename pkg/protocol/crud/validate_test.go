@@ -0,0 +1,210 @@
+package crud
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestValidateFilter(t *testing.T) {
+	s := new(ValidateFilterTestSuite)
+	s.resourceBase = "../../tests/validate_filter_test_suite"
+	suite.Run(t, s)
+}
+
+type ValidateFilterTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *ValidateFilterTestSuite) TestValidate() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	tests := []struct {
+		name   string
+		filter string
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name:   "string comparison",
+			filter: `userName eq "imulab"`,
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "logical combination",
+			filter: `(name.givenName sw "W" or displayName co "x") and active eq false`,
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "multiValued sub attribute",
+			filter: `emails.value ew "@foo.com" and emails.primary eq true`,
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "value path",
+			filter: `emails[type eq "work" and value co "@foo.com"] and emails[primary eq true].display pr`,
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "value path on singular attribute",
+			filter: `name[givenName eq "W"]`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "filter cannot be applied to singular attribute 'name'")
+			},
+		},
+		{
+			name:   "nonexistent attribute in value path",
+			filter: `emails[foo eq "W"]`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "'foo' is not a valid attribute")
+			},
+		},
+		{
+			name:   "core attribute",
+			filter: `meta.lastModified gt "2019-11-20T13:09:00Z"`,
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "main schema namespace",
+			filter: `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "imulab"`,
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "presence of complex attribute",
+			filter: `emails pr`,
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "nonexistent attribute",
+			filter: `nonexistent eq "x"`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "'nonexistent' is not a valid attribute")
+			},
+		},
+		{
+			name:   "nonexistent sub attribute",
+			filter: `name.nickName eq "x"`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "'nickName' is not a valid attribute")
+			},
+		},
+		{
+			name:   "sub attribute of simple attribute",
+			filter: `userName.value eq "x"`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "'userName' does not have sub attribute 'value'")
+			},
+		},
+		{
+			name:   "ordering boolean",
+			filter: `active gt true`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "operator 'gt' cannot be applied to 'active' of type boolean")
+			},
+		},
+		{
+			name:   "substring on boolean",
+			filter: `emails.primary co "t"`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "operator 'co' cannot be applied to 'emails.primary' of type boolean")
+			},
+		},
+		{
+			name:   "comparing complex",
+			filter: `name eq "x"`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "operator 'eq' cannot be applied to 'name' of type complex")
+			},
+		},
+		{
+			name:   "unquoted string literal",
+			filter: `userName eq 123`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "'userName' expects string value, but value was unquoted")
+			},
+		},
+		{
+			name:   "string literal for boolean",
+			filter: `active eq "true"`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "'active' expects boolean value")
+			},
+		},
+		{
+			name:   "invalid operand of logical operator",
+			filter: `userName eq "imulab" and active lt false`,
+			expect: func(t *testing.T, err error) {
+				s.assertInvalidFilter(t, err, "operator 'lt' cannot be applied to 'active' of type boolean")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			filter, err := expr.CompileFilter(test.filter)
+			require.Nil(t, err)
+			test.expect(t, Validate(filter, resourceType))
+		})
+	}
+}
+
+func (s *ValidateFilterTestSuite) assertInvalidFilter(t *testing.T, err error, message string) {
+	require.NotNil(t, err)
+	scimError, ok := err.(*errors.Error)
+	require.True(t, ok)
+	assert.Equal(t, errors.TypeInvalidFilter, scimError.Type)
+	assert.Equal(t, message, scimError.Message)
+}
+
+func (s *ValidateFilterTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *ValidateFilterTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"sort"
+	"strings"
+)
+
+// Position of a cursor paginated query, which is the sort key of the last resource of the previous page, along with the
+// filter and sort of the query it was obtained with. Because the position is a sort key rather than an offset, it stays
+// valid as resources are inserted or deleted between pages.
+type cursor struct {
+	Filter string          `json:"filter,omitempty"`
+	By     string          `json:"by,omitempty"`
+	Order  crud.SortOrder  `json:"order,omitempty"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	ID     string          `json:"id"`
+}
+
+func (c *cursor) encode() (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(encoded string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.InvalidCursor("cursor is malformed")
+	}
+	c := new(cursor)
+	if err := json.Unmarshal(raw, c); err != nil || len(c.ID) == 0 {
+		return nil, errors.InvalidCursor("cursor is malformed")
+	}
+	return c, nil
+}
+
+// Sort key of a resource. Value is the sort target, which is nil if it is absent. The key of a cursor position only
+// carries the raw value, which is resolved against the attribute of the sort target once it is compared.
+type sortKey struct {
+	id       string
+	resource *prop.Resource
+	present  bool
+	value    prop.Property
+	raw      json.RawMessage
+}
+
+func newSortKey(resource *prop.Resource, by *expr.Expression) *sortKey {
+	key := &sortKey{id: resource.ID(), resource: resource}
+	if by != nil {
+		if target, err := crud.SeekSortTarget(resource, by); err == nil && !target.IsUnassigned() {
+			key.present, key.value = true, target
+		}
+	}
+	return key
+}
+
+func (k *sortKey) resolve(attr *spec.Attribute) error {
+	if k.value != nil {
+		return nil
+	}
+	value := prop.New(attr, nil)
+	if err := scimJSON.DeserializeProperty(k.raw, value, false); err != nil {
+		return errors.InvalidCursor("cursor is malformed")
+	}
+	k.value = value
+	return nil
+}
+
+// Compare the sort keys by their values in the sort order, and then by their ids in ascending order. Consistent with
+// crud.Sort, absent values are last in ascending order and first in descending order.
+func compareSortKeys(a, b *sortKey, order crud.SortOrder) int {
+	r := 0
+	switch {
+	case a.present && !b.present:
+		r = -1
+	case !a.present && b.present:
+		r = 1
+	case a.present && b.present:
+		if less, err := a.value.LessThan(b.value.Raw()); err == nil && less {
+			r = -1
+		} else if less, err := b.value.LessThan(a.value.Raw()); err == nil && less {
+			r = 1
+		}
+	}
+	if order == crud.SortDesc {
+		r = -r
+	}
+	if r != 0 {
+		return r
+	}
+	return strings.Compare(a.id, b.id)
+}
+
+// Return the page of resources matching the filter after the cursor of the pagination, and the cursor to the next
+// page, which is empty if there are no more resources. Resources are ordered by the sort, and then by id, so that the
+// order is total. The returned resources are the stored instances, which the caller must not modify or hand out.
+func queryCursor(ctx context.Context, resources map[string]*prop.Resource, planner func(filter *expr.Expression) (map[string]struct{}, bool),
+	filter string, sorting *crud.Sort, pagination *crud.CursorPagination) ([]*prop.Resource, string, error) {
+	candidates, err := query(ctx, resources, planner, filter, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	next := &cursor{Filter: filter}
+	var by *expr.Expression
+	if sorting != nil && len(sorting.By) > 0 {
+		next.By, next.Order = sorting.By, sorting.Order
+		if by, err = expr.CompilePath(sorting.By); err != nil {
+			return nil, "", err
+		}
+	}
+
+	keys := make([]*sortKey, len(candidates))
+	for i, r := range candidates {
+		keys[i] = newSortKey(r, by)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return compareSortKeys(keys[i], keys[j], next.Order) < 0
+	})
+
+	lb := 0
+	if len(pagination.Cursor) > 0 {
+		prev, err := decodeCursor(pagination.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if prev.Filter != next.Filter || prev.By != next.By || prev.Order != next.Order {
+			return nil, "", errors.InvalidCursor("cursor was obtained with a different filter or sort")
+		}
+		position := &sortKey{id: prev.ID, present: len(prev.Value) > 0, raw: prev.Value}
+		for lb < len(keys) {
+			if keys[lb].present && position.present {
+				if err := position.resolve(keys[lb].value.Attribute()); err != nil {
+					return nil, "", err
+				}
+			}
+			if compareSortKeys(keys[lb], position, next.Order) > 0 {
+				break
+			}
+			lb++
+		}
+	}
+
+	ub := lb + pagination.Count
+	if ub > len(keys) {
+		ub = len(keys)
+	} else if ub < lb {
+		ub = lb
+	}
+
+	page := make([]*prop.Resource, 0, ub-lb)
+	for _, key := range keys[lb:ub] {
+		page = append(page, key.resource)
+	}
+	if ub == len(keys) || ub == lb {
+		return page, "", nil
+	}
+
+	last := keys[ub-1]
+	next.ID = last.id
+	if last.present {
+		if next.Value, err = json.Marshal(last.value.Raw()); err != nil {
+			return nil, "", err
+		}
+	}
+	encoded, err := next.encode()
+	if err != nil {
+		return nil, "", err
+	}
+	return page, encoded, nil
+}
@@ -18,10 +18,27 @@ type DB interface {
 	Get(ctx context.Context, id string, projection *crud.Projection) (*prop.Resource, error)
 	// Overwrite the existing resource with same ID with the new resource
 	Replace(ctx context.Context, resource *prop.Resource) error
+	// Overwrite the existing resource with same ID with the new resource, only if the meta.version of the existing
+	// resource is still the given version. Returns a preCondition error otherwise, so that concurrent updates based
+	// on the same version cannot overwrite each other.
+	CompareAndReplace(ctx context.Context, resource *prop.Resource, version string) error
 	// Delete a resource by its id
 	Delete(ctx context.Context, id string) error
+	// Delete a resource by its id, only if its meta.version is still the given version. Returns a preCondition error
+	// otherwise.
+	CompareAndDelete(ctx context.Context, id string, version string) error
 	// Query resources. The projection parameter specifies the attributes to be included or excluded from the
 	// response. Implementations may elect to ignore this parameter in case caller services need all the attributes for
 	// additional processing.
 	Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) ([]*prop.Resource, error)
+	// Query resources like Query, but return an iterator over the results, so that they can be processed one at a time
+	// rather than being held in memory all together. The iterator must be closed after use. Errors which occur while
+	// iterating, such as the context being done, are reported by the iterator.
+	QueryIterator(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) (Iterator, error)
+	// Query the page of resources after the cursor of the pagination, ordered by the sort and then by id. Also returns
+	// the cursor to the next page, which is empty if there are no more resources. Cursors are opaque to the caller, and
+	// stay valid as resources are inserted or deleted between pages, so that no resource is skipped or repeated unless
+	// its sort attribute is modified. Returns an invalidCursor error if the cursor is malformed, or was obtained with a
+	// different filter or sort.
+	QueryCursor(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.CursorPagination, projection *crud.Projection) ([]*prop.Resource, string, error)
 }
@@ -0,0 +1,585 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// File names within the directory of a file database
+	fileSnapshot     = "snapshot"
+	fileSnapshotTemp = "snapshot.tmp"
+	fileLog          = "wal"
+
+	// Record operations
+	recordPut    byte = 'P'
+	recordDelete byte = 'D'
+
+	// Length of the record header: payload length, checksum and operation
+	recordHeaderSize = 9
+	// Upper bound of the payload length, beyond which the length itself is deemed corrupted
+	recordMaxSize = 64 << 20
+)
+
+const (
+	// Flush the log to disk before every write returns. Acknowledged writes survive power loss.
+	SyncAlways SyncPolicy = iota
+	// Flush the log to disk periodically. Writes acknowledged within the last interval may be lost on power loss,
+	// but not when only the process crashes.
+	SyncInterval
+	// Never explicitly flush the log, and leave it to the operating system.
+	SyncNever
+)
+
+// Policy on when the write-ahead log of the file database is flushed to disk.
+type SyncPolicy int
+
+// Options of the file database. The zero value flushes on every write, and compacts after every 1000 records in the
+// log.
+type FileOptions struct {
+	// When to flush the log to disk.
+	Sync SyncPolicy
+	// Interval between flushes when Sync is SyncInterval. Defaults to one second.
+	SyncInterval time.Duration
+	// Number of records in the log which triggers compaction. Defaults to 1000, and negative values disable automatic
+	// compaction.
+	CompactThreshold int
+}
+
+// A DB backed by files in a single directory, suitable for small deployments and integration tests. It must be
+// closed when no longer used.
+type FileDB interface {
+	DB
+	// Write the current state of the database to a new snapshot, and truncate the log.
+	Compact() error
+	// Flush and close the log. The database cannot be used once closed.
+	Close() error
+}
+
+// Open the file database for resources of the resource type in the directory, which is created if it does not exist.
+// The options may be nil to use the defaults.
+//
+// All resources are kept in memory, and queries are evaluated against them. Every write is appended to a write-ahead
+// log before it is applied; the log is compacted into a snapshot once it has grown long enough. On open, the snapshot
+// is loaded and the log is replayed on top of it. A partially written record at the end of the log, which is left
+// behind by a crash during a write, is discarded. The directory must not be opened by more than one database at a
+// time.
+func File(dir string, resourceType *spec.ResourceType, options *FileOptions) (FileDB, error) {
+	var opts FileOptions
+	if options != nil {
+		opts = *options
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	if opts.CompactThreshold == 0 {
+		opts.CompactThreshold = 1000
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Internal("failed to create database directory: %s", err.Error())
+	}
+	_ = os.Remove(filepath.Join(dir, fileSnapshotTemp))
+
+	f := &fileDB{
+		dir:          dir,
+		resourceType: resourceType,
+		options:      opts,
+		db:           make(map[string]*prop.Resource),
+		done:         make(chan struct{}),
+	}
+	if err := f.recover(); err != nil {
+		return nil, err
+	}
+	if opts.Sync == SyncInterval {
+		go f.syncPeriodically()
+	}
+	return f, nil
+}
+
+type fileDB struct {
+	sync.RWMutex
+	dir          string
+	resourceType *spec.ResourceType
+	options      FileOptions
+	db           map[string]*prop.Resource
+	log          *os.File
+	// size of the log and the number of records in it
+	logSize    int64
+	logRecords int
+	dirty      bool
+	closed     bool
+	done       chan struct{}
+}
+
+func (f *fileDB) Insert(ctx context.Context, resource *prop.Resource) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	id := resource.ID()
+	if len(id) == 0 {
+		return errors.Internal("cannot save resource with empty id")
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if _, ok := f.db[id]; ok {
+		return errors.Internal("resource with id '%s' already exists", id)
+	}
+	return f.put(resource)
+}
+
+func (f *fileDB) Get(ctx context.Context, id string, projection *crud.Projection) (*prop.Resource, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	r, ok := f.db[id]
+	if !ok {
+		return nil, errors.NotFound("resource by id [%s] is not found", id)
+	}
+	return project(r, projection)
+}
+
+func (f *fileDB) Count(ctx context.Context, filter string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	if len(filter) == 0 {
+		return len(f.db), nil
+	}
+	candidates, err := query(ctx, f.db, nil, filter, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	return len(candidates), nil
+}
+
+func (f *fileDB) Replace(ctx context.Context, resource *prop.Resource) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	id := resource.ID()
+	if _, ok := f.db[id]; !ok {
+		return errors.NotFound("resource by id [%s] is not found", id)
+	}
+	return f.put(resource)
+}
+
+func (f *fileDB) CompareAndReplace(ctx context.Context, resource *prop.Resource, version string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	id := resource.ID()
+	existing, ok := f.db[id]
+	if !ok {
+		return errors.NotFound("resource by id [%s] is not found", id)
+	}
+	if err := checkVersion(existing, version); err != nil {
+		return err
+	}
+	return f.put(resource)
+}
+
+func (f *fileDB) Delete(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	if _, ok := f.db[id]; !ok {
+		return nil
+	}
+	return f.remove(id)
+}
+
+func (f *fileDB) CompareAndDelete(ctx context.Context, id string, version string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	existing, ok := f.db[id]
+	if !ok {
+		return errors.NotFound("resource by id [%s] is not found", id)
+	}
+	if err := checkVersion(existing, version); err != nil {
+		return err
+	}
+	return f.remove(id)
+}
+
+func (f *fileDB) Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) ([]*prop.Resource, error) {
+	iterator, err := f.QueryIterator(ctx, filter, sort, pagination, projection)
+	if err != nil {
+		return nil, err
+	}
+	return Collect(iterator)
+}
+
+func (f *fileDB) QueryIterator(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) (Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	candidates, err := query(ctx, f.db, nil, filter, sort, pagination)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot(ctx, candidates, projection), nil
+}
+
+func (f *fileDB) QueryCursor(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.CursorPagination, projection *crud.Projection) ([]*prop.Resource, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	candidates, next, err := queryCursor(ctx, f.db, nil, filter, sort, pagination)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, r := range candidates {
+		if candidates[i], err = project(r, projection); err != nil {
+			return nil, "", err
+		}
+	}
+	return candidates, next, nil
+}
+
+func (f *fileDB) Compact() error {
+	f.Lock()
+	defer f.Unlock()
+	return f.compact()
+}
+
+func (f *fileDB) Close() error {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	close(f.done)
+
+	if err := f.log.Sync(); err != nil {
+		_ = f.log.Close()
+		return errors.Internal("failed to sync log: %s", err.Error())
+	}
+	if err := f.log.Close(); err != nil {
+		return errors.Internal("failed to close log: %s", err.Error())
+	}
+	return nil
+}
+
+// Log and apply the resource. Caller must hold the write lock.
+func (f *fileDB) put(resource *prop.Resource) error {
+	raw, err := scimJSON.Serialize(resource, scimJSON.Options().Full())
+	if err != nil {
+		return err
+	}
+	if err := f.append(recordPut, raw); err != nil {
+		return err
+	}
+	f.db[resource.ID()] = resource.Clone()
+	f.compactIfNeeded()
+	return nil
+}
+
+// Log and apply the deletion of the resource. Caller must hold the write lock.
+func (f *fileDB) remove(id string) error {
+	if err := f.append(recordDelete, []byte(id)); err != nil {
+		return err
+	}
+	delete(f.db, id)
+	f.compactIfNeeded()
+	return nil
+}
+
+// Append a record to the end of the log, and flush it according to the sync policy. Should the write fail, the log is
+// truncated to its previous size, so that no partial record precedes later records. Caller must hold the write lock.
+func (f *fileDB) append(op byte, payload []byte) error {
+	if f.closed {
+		return errors.Internal("database is closed")
+	}
+
+	if _, err := f.log.WriteAt(encodeRecord(op, payload), f.logSize); err != nil {
+		_ = f.log.Truncate(f.logSize)
+		return errors.Internal("failed to write log: %s", err.Error())
+	}
+	if f.options.Sync == SyncAlways {
+		if err := f.log.Sync(); err != nil {
+			_ = f.log.Truncate(f.logSize)
+			return errors.Internal("failed to sync log: %s", err.Error())
+		}
+	}
+
+	f.logSize += int64(recordHeaderSize + len(payload))
+	f.logRecords++
+	f.dirty = true
+	return nil
+}
+
+// Compact when the log has reached the threshold. The write which triggered the compaction has already been logged, so
+// a failed compaction is not reported to its caller, and is retried on the next write.
+func (f *fileDB) compactIfNeeded() {
+	if f.options.CompactThreshold < 0 || f.logRecords < f.options.CompactThreshold {
+		return
+	}
+	_ = f.compact()
+}
+
+// Write all resources to a temporary snapshot which then atomically replaces the current snapshot, and truncate the
+// log. If the process crashes before the log is truncated, the log is replayed on top of the new snapshot on the next
+// open, which yields the same state since replaying records is idempotent. Caller must hold the write lock.
+func (f *fileDB) compact() error {
+	if f.closed {
+		return errors.Internal("database is closed")
+	}
+
+	temp := filepath.Join(f.dir, fileSnapshotTemp)
+	if err := f.writeSnapshot(temp); err != nil {
+		_ = os.Remove(temp)
+		return err
+	}
+	if err := os.Rename(temp, filepath.Join(f.dir, fileSnapshot)); err != nil {
+		return errors.Internal("failed to replace snapshot: %s", err.Error())
+	}
+	if err := syncDir(f.dir); err != nil {
+		return err
+	}
+
+	if err := f.log.Truncate(0); err != nil {
+		return errors.Internal("failed to truncate log: %s", err.Error())
+	}
+	if err := f.log.Sync(); err != nil {
+		return errors.Internal("failed to sync log: %s", err.Error())
+	}
+	f.logSize = 0
+	f.logRecords = 0
+	f.dirty = false
+	return nil
+}
+
+func (f *fileDB) writeSnapshot(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Internal("failed to create snapshot: %s", err.Error())
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for _, r := range f.db {
+		raw, err := scimJSON.Serialize(r, scimJSON.Options().Full())
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(encodeRecord(recordPut, raw)); err != nil {
+			return errors.Internal("failed to write snapshot: %s", err.Error())
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return errors.Internal("failed to write snapshot: %s", err.Error())
+	}
+	if err := file.Sync(); err != nil {
+		return errors.Internal("failed to sync snapshot: %s", err.Error())
+	}
+	return nil
+}
+
+// Load the snapshot, replay the log on top of it, and open the log for appending. A snapshot is always completely
+// written before it is put in place, so any damage to it is reported as an error. The log, on the other hand, may end
+// with a partially written record, which is discarded. Damage to any record before the last one cannot be explained by
+// an interrupted write, hence it is reported as an error rather than discarding all records after it.
+func (f *fileDB) recover() error {
+	snapshot, err := os.Open(filepath.Join(f.dir, fileSnapshot))
+	switch {
+	case err == nil:
+		_, _, err = f.replay(snapshot)
+		_ = snapshot.Close()
+		if err != nil {
+			return errors.Internal("snapshot is corrupted: %s", err.Error())
+		}
+	case !os.IsNotExist(err):
+		return errors.Internal("failed to open snapshot: %s", err.Error())
+	}
+
+	log, err := os.OpenFile(filepath.Join(f.dir, fileLog), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return errors.Internal("failed to open log: %s", err.Error())
+	}
+	size, records, err := f.replay(log)
+	if err != nil && err != errTornRecord {
+		_ = log.Close()
+		return errors.Internal("log is corrupted at offset %d: %s", size, err.Error())
+	}
+	if err == errTornRecord {
+		if err := log.Truncate(size); err != nil {
+			_ = log.Close()
+			return errors.Internal("failed to truncate log: %s", err.Error())
+		}
+	}
+
+	f.log = log
+	f.logSize = size
+	f.logRecords = records
+	return nil
+}
+
+// Apply all records read from the reader, and return the size and the number of the records successfully applied.
+func (f *fileDB) replay(r io.Reader) (int64, int, error) {
+	var (
+		reader  = bufio.NewReader(r)
+		size    int64
+		records int
+	)
+	for {
+		op, payload, err := decodeRecord(reader)
+		if err == io.EOF {
+			return size, records, nil
+		} else if err != nil {
+			return size, records, err
+		}
+
+		switch op {
+		case recordPut:
+			resource := prop.NewResource(f.resourceType)
+			if err := scimJSON.Deserialize(payload, resource); err != nil {
+				return size, records, err
+			}
+			f.db[resource.ID()] = resource
+		case recordDelete:
+			delete(f.db, string(payload))
+		default:
+			return size, records, errors.Internal("unknown record operation '%c'", op)
+		}
+
+		size += int64(recordHeaderSize + len(payload))
+		records++
+	}
+}
+
+func (f *fileDB) syncPeriodically() {
+	ticker := time.NewTicker(f.options.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+			f.Lock()
+			if !f.closed && f.dirty {
+				if err := f.log.Sync(); err == nil {
+					f.dirty = false
+				}
+			}
+			f.Unlock()
+		}
+	}
+}
+
+var (
+	errTornRecord    = errors.Internal("record is incomplete")
+	errCorruptRecord = errors.Internal("record does not match its checksum")
+)
+
+// Encode the record as the big endian payload length, the CRC-32 checksum of the operation and the payload, the
+// operation and the payload.
+func encodeRecord(op byte, payload []byte) []byte {
+	record := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	record[8] = op
+	copy(record[recordHeaderSize:], payload)
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(record[8:]))
+	return record
+}
+
+// Decode the next record from the reader. Returns io.EOF if there are no more records, errTornRecord if the record is
+// incomplete, or is the last record and does not match its checksum, and errCorruptRecord if any other record does
+// not match its checksum.
+func decodeRecord(reader *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, recordHeaderSize)
+	if n, err := io.ReadFull(reader, header); err != nil {
+		if err == io.EOF && n == 0 {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, errTornRecord
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if length > recordMaxSize {
+		// the length is damaged, the record is only the last one if the rest is shorter than the length
+		if n, _ := io.CopyN(ioutil.Discard, reader, int64(length)); n < int64(length) {
+			return 0, nil, errTornRecord
+		}
+		return 0, nil, errCorruptRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, nil, errTornRecord
+	}
+
+	checksum := crc32.NewIEEE()
+	_, _ = checksum.Write(header[8:])
+	_, _ = checksum.Write(payload)
+	if checksum.Sum32() != binary.BigEndian.Uint32(header[4:8]) {
+		if _, err := reader.Peek(1); err == io.EOF {
+			return 0, nil, errTornRecord
+		}
+		return 0, nil, errCorruptRecord
+	}
+
+	return header[8], payload, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Internal("failed to open database directory: %s", err.Error())
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return errors.Internal("failed to sync database directory: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,424 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileDB(t *testing.T) {
+	s := new(FileDBTestSuite)
+	s.resourceBase = "../../tests/file_db_test_suite"
+	suite.Run(t, s)
+}
+
+type FileDBTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *FileDBTestSuite) TestRecover() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	const (
+		user001 = "a5866759-32ca-4e2a-9808-a0fe74f94b18"
+		user002 = "23d22b2d-4fc4-49f9-90fb-ee10882c69ed"
+		user003 = "bf5d7cbd-396a-4460-b59c-579250e1a81a"
+	)
+
+	tests := []struct {
+		name    string
+		options *FileOptions
+		write   func(t *testing.T, db FileDB)
+		damage  func(t *testing.T, dir string)
+		expect  func(t *testing.T, db FileDB)
+	}{
+		{
+			name: "inserted resources are recovered",
+			write: func(t *testing.T, db FileDB) {
+				for _, f := range []string{"/user_001.json", "/user_002.json", "/user_003.json"} {
+					require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+				}
+			},
+			expect: func(t *testing.T, db FileDB) {
+				count, err := db.Count(context.Background(), "")
+				assert.Nil(t, err)
+				assert.Equal(t, 3, count)
+
+				r, err := db.Get(context.Background(), user001, nil)
+				require.Nil(t, err)
+				assert.Equal(t, s.mustResource("/user_001.json", resourceType).Hash(), r.Hash())
+			},
+		},
+		{
+			name: "write only attributes are recovered",
+			write: func(t *testing.T, db FileDB) {
+				require.Nil(t, db.Insert(context.Background(), s.mustResource("/user_001.json", resourceType)))
+			},
+			expect: func(t *testing.T, db FileDB) {
+				r, err := db.Get(context.Background(), user001, nil)
+				require.Nil(t, err)
+				p, err := r.NewNavigator().FocusName("password")
+				require.Nil(t, err)
+				assert.NotEmpty(t, p.Raw())
+			},
+		},
+		{
+			name: "replaced and deleted resources are recovered",
+			write: func(t *testing.T, db FileDB) {
+				for _, f := range []string{"/user_001.json", "/user_002.json", "/user_003.json"} {
+					require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+				}
+				r := s.mustResource("/user_001.json", resourceType)
+				require.Nil(t, crud.Replace(r, "userName", "foobar"))
+				require.Nil(t, db.Replace(context.Background(), r))
+				require.Nil(t, db.Delete(context.Background(), user002))
+			},
+			expect: func(t *testing.T, db FileDB) {
+				count, err := db.Count(context.Background(), "userName eq \"foobar\"")
+				assert.Nil(t, err)
+				assert.Equal(t, 1, count)
+
+				_, err = db.Get(context.Background(), user002, nil)
+				assert.NotNil(t, err)
+
+				_, err = db.Get(context.Background(), user003, nil)
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "writes after compaction are recovered",
+			write: func(t *testing.T, db FileDB) {
+				for _, f := range []string{"/user_001.json", "/user_002.json"} {
+					require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+				}
+				require.Nil(t, db.Compact())
+				require.Nil(t, db.Delete(context.Background(), user001))
+				require.Nil(t, db.Insert(context.Background(), s.mustResource("/user_003.json", resourceType)))
+			},
+			expect: func(t *testing.T, db FileDB) {
+				results, err := db.Query(context.Background(), "", &crud.Sort{By: "userName"}, nil, nil)
+				require.Nil(t, err)
+				require.Len(t, results, 2)
+				assert.Equal(t, user002, results[0].ID())
+				assert.Equal(t, user003, results[1].ID())
+			},
+		},
+		{
+			name:    "log is compacted automatically",
+			options: &FileOptions{CompactThreshold: 2},
+			write: func(t *testing.T, db FileDB) {
+				for _, f := range []string{"/user_001.json", "/user_002.json", "/user_003.json"} {
+					require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+				}
+			},
+			damage: func(t *testing.T, dir string) {
+				_, err := os.Stat(filepath.Join(dir, fileSnapshot))
+				assert.Nil(t, err)
+			},
+			expect: func(t *testing.T, db FileDB) {
+				count, err := db.Count(context.Background(), "")
+				assert.Nil(t, err)
+				assert.Equal(t, 3, count)
+			},
+		},
+		{
+			name:    "interrupted compaction is recovered",
+			options: &FileOptions{Sync: SyncNever},
+			write: func(t *testing.T, db FileDB) {
+				for _, f := range []string{"/user_001.json", "/user_002.json"} {
+					require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+				}
+				require.Nil(t, db.Delete(context.Background(), user001))
+			},
+			damage: func(t *testing.T, dir string) {
+				// snapshot written with the complete state, but log not yet truncated
+				snapshot := encodeRecord(recordPut, s.mustSerialize(s.mustResource("/user_002.json", resourceType)))
+				require.Nil(t, ioutil.WriteFile(filepath.Join(dir, fileSnapshot), snapshot, 0644))
+				require.Nil(t, ioutil.WriteFile(filepath.Join(dir, fileSnapshotTemp), []byte("garbage"), 0644))
+			},
+			expect: func(t *testing.T, db FileDB) {
+				count, err := db.Count(context.Background(), "")
+				assert.Nil(t, err)
+				assert.Equal(t, 1, count)
+
+				_, err = db.Get(context.Background(), user002, nil)
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:    "partially written record is discarded",
+			options: &FileOptions{Sync: SyncInterval, SyncInterval: 10 * time.Millisecond},
+			write: func(t *testing.T, db FileDB) {
+				for _, f := range []string{"/user_001.json", "/user_002.json"} {
+					require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+				}
+			},
+			damage: func(t *testing.T, dir string) {
+				record := encodeRecord(recordPut, s.mustSerialize(s.mustResource("/user_003.json", resourceType)))
+				f, err := os.OpenFile(filepath.Join(dir, fileLog), os.O_APPEND|os.O_WRONLY, 0644)
+				require.Nil(t, err)
+				_, err = f.Write(record[:len(record)/2])
+				require.Nil(t, err)
+				require.Nil(t, f.Close())
+			},
+			expect: func(t *testing.T, db FileDB) {
+				count, err := db.Count(context.Background(), "")
+				assert.Nil(t, err)
+				assert.Equal(t, 2, count)
+			},
+		},
+		{
+			name: "last record not matching its checksum is discarded",
+			write: func(t *testing.T, db FileDB) {
+				for _, f := range []string{"/user_001.json", "/user_002.json"} {
+					require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+				}
+			},
+			damage: func(t *testing.T, dir string) {
+				record := encodeRecord(recordPut, s.mustSerialize(s.mustResource("/user_003.json", resourceType)))
+				record[len(record)-1] = '!'
+				f, err := os.OpenFile(filepath.Join(dir, fileLog), os.O_APPEND|os.O_WRONLY, 0644)
+				require.Nil(t, err)
+				_, err = f.Write(record)
+				require.Nil(t, err)
+				require.Nil(t, f.Close())
+			},
+			expect: func(t *testing.T, db FileDB) {
+				count, err := db.Count(context.Background(), "")
+				assert.Nil(t, err)
+				assert.Equal(t, 2, count)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			dir := s.mustTempDir(t)
+			defer os.RemoveAll(dir)
+
+			db, err := File(dir, resourceType, test.options)
+			require.Nil(t, err)
+			test.write(t, db)
+			require.Nil(t, db.Close())
+
+			if test.damage != nil {
+				test.damage(t, dir)
+			}
+
+			db, err = File(dir, resourceType, test.options)
+			require.Nil(t, err)
+			test.expect(t, db)
+			require.Nil(t, db.Close())
+		})
+	}
+}
+
+func (s *FileDBTestSuite) TestWriteAfterPartialRecord() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	dir := s.mustTempDir(s.T())
+	defer os.RemoveAll(dir)
+
+	record := encodeRecord(recordPut, s.mustSerialize(s.mustResource("/user_001.json", resourceType)))
+	require.Nil(s.T(), ioutil.WriteFile(filepath.Join(dir, fileLog), record[:len(record)-1], 0644))
+
+	db, err := File(dir, resourceType, nil)
+	require.Nil(s.T(), err)
+	require.Nil(s.T(), db.Insert(context.Background(), s.mustResource("/user_002.json", resourceType)))
+	require.Nil(s.T(), db.Close())
+
+	db, err = File(dir, resourceType, nil)
+	require.Nil(s.T(), err)
+	defer db.Close()
+
+	count, err := db.Count(context.Background(), "")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, count)
+}
+
+func (s *FileDBTestSuite) TestCorruptedSnapshot() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	dir := s.mustTempDir(s.T())
+	defer os.RemoveAll(dir)
+
+	record := encodeRecord(recordPut, s.mustSerialize(s.mustResource("/user_001.json", resourceType)))
+	record[len(record)-1] = '!'
+	require.Nil(s.T(), ioutil.WriteFile(filepath.Join(dir, fileSnapshot), record, 0644))
+
+	_, err := File(dir, resourceType, nil)
+	assert.NotNil(s.T(), err)
+}
+
+func (s *FileDBTestSuite) TestCorruptedLog() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	dir := s.mustTempDir(s.T())
+	defer os.RemoveAll(dir)
+
+	first := encodeRecord(recordPut, s.mustSerialize(s.mustResource("/user_001.json", resourceType)))
+	first[len(first)-1] = '!'
+	second := encodeRecord(recordPut, s.mustSerialize(s.mustResource("/user_002.json", resourceType)))
+	require.Nil(s.T(), ioutil.WriteFile(filepath.Join(dir, fileLog), append(first, second...), 0644))
+
+	_, err := File(dir, resourceType, nil)
+	assert.NotNil(s.T(), err)
+
+	info, err := os.Stat(filepath.Join(dir, fileLog))
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), int64(len(first)+len(second)), info.Size())
+}
+
+func (s *FileDBTestSuite) TestCompareAndSwap() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	dir := s.mustTempDir(s.T())
+	defer os.RemoveAll(dir)
+
+	db, err := File(dir, resourceType, nil)
+	require.Nil(s.T(), err)
+
+	inserted := s.mustResource("/user_001.json", resourceType)
+	require.Nil(s.T(), db.Insert(context.Background(), inserted))
+
+	replaced := inserted.Clone()
+	require.Nil(s.T(), crud.Replace(replaced, "meta.version", "W/\"2\""))
+	err = db.CompareAndReplace(context.Background(), replaced, "W/\"0\"")
+	assert.Equal(s.T(), errors.TypePreCondition, err.(*errors.Error).Type)
+	assert.Nil(s.T(), db.CompareAndReplace(context.Background(), replaced, inserted.Version()))
+
+	err = db.CompareAndDelete(context.Background(), inserted.ID(), inserted.Version())
+	assert.Equal(s.T(), errors.TypePreCondition, err.(*errors.Error).Type)
+	require.Nil(s.T(), db.Close())
+
+	db, err = File(dir, resourceType, nil)
+	require.Nil(s.T(), err)
+	defer db.Close()
+
+	r, err := db.Get(context.Background(), inserted.ID(), nil)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "W/\"2\"", r.Version())
+
+	assert.Nil(s.T(), db.CompareAndDelete(context.Background(), inserted.ID(), "W/\"2\""))
+	count, err := db.Count(context.Background(), "")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, count)
+}
+
+func (s *FileDBTestSuite) TestIsolation() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	dir := s.mustTempDir(s.T())
+	defer os.RemoveAll(dir)
+
+	db, err := File(dir, resourceType, nil)
+	require.Nil(s.T(), err)
+	defer db.Close()
+
+	inserted := s.mustResource("/user_001.json", resourceType)
+	require.Nil(s.T(), db.Insert(context.Background(), inserted))
+	require.Nil(s.T(), crud.Replace(inserted, "userName", "foo"))
+
+	got, err := db.Get(context.Background(), inserted.ID(), nil)
+	require.Nil(s.T(), err)
+	require.Nil(s.T(), crud.Replace(got, "userName", "bar"))
+
+	count, err := db.Count(context.Background(), "userName eq \"user001\"")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, count)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = db.Get(ctx, inserted.ID(), nil)
+	assert.Equal(s.T(), context.Canceled, err)
+}
+
+func (s *FileDBTestSuite) TestOptionsUnchanged() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	dir := s.mustTempDir(s.T())
+	defer os.RemoveAll(dir)
+
+	options := &FileOptions{Sync: SyncAlways}
+	db, err := File(dir, resourceType, options)
+	require.Nil(s.T(), err)
+	defer db.Close()
+
+	assert.Equal(s.T(), FileOptions{Sync: SyncAlways}, *options)
+}
+
+func (s *FileDBTestSuite) mustTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "scim-file-db")
+	require.Nil(t, err)
+	return dir
+}
+
+func (s *FileDBTestSuite) mustSerialize(resource *prop.Resource) []byte {
+	raw, err := scimJSON.Serialize(resource, scimJSON.Options().Full())
+	s.Require().Nil(err)
+	return raw
+}
+
+func (s *FileDBTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	resource := prop.NewResource(resourceType)
+	err = scimJSON.Deserialize(raw, resource)
+	s.Require().Nil(err)
+
+	return resource
+}
+
+func (s *FileDBTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *FileDBTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
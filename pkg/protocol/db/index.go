@@ -0,0 +1,277 @@
+package db
+
+import (
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"strconv"
+	"strings"
+)
+
+// Hash index on a simple attribute, which maps the values of the attribute to the ids of the resources having them,
+// and keeps the ids of the resources in which the attribute is present. Values are keyed so that values considered
+// equal by the eq operator share the same key.
+type index struct {
+	// attributes on the path from the resource to the indexed attribute
+	path    []*spec.Attribute
+	values  map[string]map[string]struct{}
+	present map[string]struct{}
+}
+
+func newIndex(path []*spec.Attribute) *index {
+	return &index{
+		path:    path,
+		values:  make(map[string]map[string]struct{}),
+		present: make(map[string]struct{}),
+	}
+}
+
+func (idx *index) attribute() *spec.Attribute {
+	return idx.path[len(idx.path)-1]
+}
+
+func (idx *index) add(resource *prop.Resource) {
+	id := resource.ID()
+	idx.collect(resource, func(key string) {
+		ids, ok := idx.values[key]
+		if !ok {
+			ids = make(map[string]struct{})
+			idx.values[key] = ids
+		}
+		ids[id] = struct{}{}
+	}, func() {
+		idx.present[id] = struct{}{}
+	})
+}
+
+func (idx *index) remove(resource *prop.Resource) {
+	id := resource.ID()
+	idx.collect(resource, func(key string) {
+		if ids, ok := idx.values[key]; ok {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(idx.values, key)
+			}
+		}
+	}, func() {
+		delete(idx.present, id)
+	})
+}
+
+// Return the ids of the resources which may have the value, and true; or false if the value cannot be looked up.
+func (idx *index) lookup(value interface{}) (map[string]struct{}, bool) {
+	key, ok := indexKey(idx.attribute(), value)
+	if !ok {
+		return nil, false
+	}
+	return idx.values[key], true
+}
+
+// Invoke onValue with the key of every value of the indexed attribute in the resource, and onPresent if the attribute
+// is present in the resource, following the path the same way crud.Evaluate does.
+func (idx *index) collect(resource *prop.Resource, onValue func(key string), onPresent func()) {
+	var visit func(property prop.Property, path []*spec.Attribute)
+	visit = func(property prop.Property, path []*spec.Attribute) {
+		if len(path) == 0 {
+			if property.Present() {
+				onPresent()
+			}
+			if property.Attribute().MultiValued() {
+				_ = property.(prop.Container).ForEachChild(func(_ int, child prop.Property) error {
+					if key, ok := indexKey(child.Attribute(), child.Raw()); ok {
+						onValue(key)
+					}
+					return nil
+				})
+			} else if key, ok := indexKey(property.Attribute(), property.Raw()); ok {
+				onValue(key)
+			}
+			return
+		}
+
+		if property.Attribute().MultiValued() {
+			_ = property.(prop.Container).ForEachChild(func(_ int, child prop.Property) error {
+				visit(child, path)
+				return nil
+			})
+			return
+		}
+
+		nav := prop.NewNavigator(property)
+		if _, err := nav.FocusName(path[0].Name()); err != nil {
+			return
+		}
+		visit(nav.Current(), path[1:])
+	}
+	visit(resource.NewNavigator().Current(), idx.path)
+}
+
+// Return the index key of the value of the attribute. String values which are not case exact are keyed in lower
+// case, in accordance with their comparison rules. Returns false for nil values.
+func indexKey(attr *spec.Attribute, value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		if attr.Type() == spec.TypeString && !attr.CaseExact() {
+			return strings.ToLower(v), true
+		}
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case float64:
+		if v == 0 {
+			v = 0 // treat -0 as 0
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// Returns true if values of the attribute can be indexed. Date times and binaries are compared after parsing, which
+// an index keyed by their textual form cannot reproduce, so they are excluded along with complex attributes.
+func indexable(attr *spec.Attribute) bool {
+	switch attr.Type() {
+	case spec.TypeString, spec.TypeReference, spec.TypeBoolean, spec.TypeInteger, spec.TypeDecimal:
+		return true
+	default:
+		return false
+	}
+}
+
+// Resolve the attribute paths to indexes on the resource type. The id attribute, and every attribute whose uniqueness
+// is server, are indexed in addition to the given paths.
+func newIndexes(resourceType *spec.ResourceType, paths ...string) (map[string]*index, error) {
+	indexes := make(map[string]*index)
+
+	var walk func(container *spec.Attribute, prefix []*spec.Attribute)
+	walk = func(container *spec.Attribute, prefix []*spec.Attribute) {
+		container.ForEachSubAttribute(func(subAttribute *spec.Attribute) {
+			path := append(append(make([]*spec.Attribute, 0, len(prefix)+1), prefix...), subAttribute)
+			if subAttribute.Type() == spec.TypeComplex {
+				walk(subAttribute, path)
+			} else if indexable(subAttribute) && ((len(prefix) == 0 && subAttribute.Name() == "id") || subAttribute.Uniqueness() == spec.UniquenessServer) {
+				indexes[subAttribute.ID()] = newIndex(path)
+			}
+		})
+	}
+	walk(resourceType.SuperAttribute(true), nil)
+
+	for _, each := range paths {
+		path, err := resolveIndexPath(resourceType, each)
+		if err != nil {
+			return nil, err
+		}
+		indexes[path[len(path)-1].ID()] = newIndex(path)
+	}
+
+	return indexes, nil
+}
+
+func resolveIndexPath(resourceType *spec.ResourceType, path string) ([]*spec.Attribute, error) {
+	head, err := expr.CompilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	attrs, ok := resolvePath(resourceType, head)
+	if !ok {
+		return nil, errors.InvalidPath("'%s' is not a valid attribute path", path)
+	}
+	if !indexable(attrs[len(attrs)-1]) {
+		return nil, errors.InvalidPath("attribute '%s' cannot be indexed", path)
+	}
+	return attrs, nil
+}
+
+// Resolve the compiled path, which may be prefixed with the namespace of the main schema, against the resource type,
+// and return the attributes on the path. Returns false if the path does not lead to an attribute or contains filters.
+func resolvePath(resourceType *spec.ResourceType, head *expr.Expression) ([]*spec.Attribute, bool) {
+	if head != nil && strings.ToLower(head.Token()) == strings.ToLower(resourceType.Schema().ID()) {
+		head = head.Next()
+	}
+	if head == nil {
+		return nil, false
+	}
+
+	var (
+		attr  = resourceType.SuperAttribute(true)
+		attrs = make([]*spec.Attribute, 0)
+	)
+	for step := head; step != nil; step = step.Next() {
+		if step.IsRootOfFilter() {
+			return nil, false
+		}
+		attr = attr.SubAttributeForName(step.Token())
+		if attr == nil {
+			return nil, false
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, true
+}
+
+// Plan the evaluation of the filter with the indexes. Returns the ids of the resources which may match the filter, and
+// true; or false if the indexes cannot narrow down the candidates, in which case all resources must be scanned.
+//
+// Relational eq and pr filters on indexed attributes are looked up in the indexes, and the candidates of conjunctions
+// are intersected. Other operators, disjunctions and negations are not planned. The candidates are a superset of the
+// matching resources, so that they must still be evaluated against the filter.
+func plan(resourceType *spec.ResourceType, indexes map[string]*index, filter *expr.Expression) (map[string]struct{}, bool) {
+	if resourceType == nil || filter == nil {
+		return nil, false
+	}
+
+	switch strings.ToLower(filter.Token()) {
+	case expr.And:
+		left, leftOk := plan(resourceType, indexes, filter.Left())
+		right, rightOk := plan(resourceType, indexes, filter.Right())
+		switch {
+		case leftOk && rightOk:
+			return intersect(left, right), true
+		case leftOk:
+			return left, true
+		case rightOk:
+			return right, true
+		default:
+			return nil, false
+		}
+	case expr.Eq, expr.Pr:
+		path, ok := resolvePath(resourceType, filter.Left())
+		if !ok {
+			return nil, false
+		}
+		idx, ok := indexes[path[len(path)-1].ID()]
+		if !ok || len(idx.path) != len(path) {
+			return nil, false
+		}
+		if strings.ToLower(filter.Token()) == expr.Pr {
+			return idx.present, true
+		}
+		if filter.Right() == nil {
+			return nil, false
+		}
+		value, err := crud.Normalize(idx.attribute(), filter.Right().Token())
+		if err != nil {
+			return nil, false
+		}
+		return idx.lookup(value)
+	default:
+		return nil, false
+	}
+}
+
+func intersect(a, b map[string]struct{}) map[string]struct{} {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	result := make(map[string]struct{}, len(a))
+	for id := range a {
+		if _, ok := b[id]; ok {
+			result[id] = struct{}{}
+		}
+	}
+	return result
+}
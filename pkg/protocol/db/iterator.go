@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+)
+
+// Iterator over the results of a query. Iterators are not safe for concurrent use.
+type Iterator interface {
+	// Advance to the next resource. Returns false when there are no more resources, or an error stopped the iteration.
+	Next() bool
+	// Return the resource Next advanced to.
+	Resource() *prop.Resource
+	// Return the error which stopped the iteration, if any.
+	Err() error
+	// Release the iterator. It is safe to close an iterator more than once.
+	Close() error
+}
+
+// Return an iterator over the resources as they are.
+func Iterate(resources []*prop.Resource) Iterator {
+	return &sliceIterator{
+		ctx:       context.Background(),
+		resources: resources,
+		view: func(resource *prop.Resource) (*prop.Resource, error) {
+			return resource, nil
+		},
+	}
+}
+
+// Drain the iterator into a slice, and close it.
+func Collect(iterator Iterator) ([]*prop.Resource, error) {
+	defer iterator.Close()
+
+	resources := make([]*prop.Resource, 0)
+	for iterator.Next() {
+		resources = append(resources, iterator.Resource())
+	}
+	if err := iterator.Err(); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// Return an iterator over the query results of the in memory databases. Stored resources are never modified, but
+// replaced, so that the results can be iterated without holding the lock of the database. The projection is applied to
+// each resource as it is iterated, so that at most one clone is alive at a time, unless the caller keeps them.
+func snapshot(ctx context.Context, resources []*prop.Resource, projection *crud.Projection) Iterator {
+	return &sliceIterator{
+		ctx:       ctx,
+		resources: resources,
+		view: func(resource *prop.Resource) (*prop.Resource, error) {
+			return project(resource, projection)
+		},
+	}
+}
+
+type sliceIterator struct {
+	ctx       context.Context
+	resources []*prop.Resource
+	view      func(resource *prop.Resource) (*prop.Resource, error)
+	current   *prop.Resource
+	err       error
+}
+
+func (it *sliceIterator) Next() bool {
+	it.current = nil
+	if it.err != nil || len(it.resources) == 0 {
+		return false
+	}
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+
+	it.current, it.err = it.view(it.resources[0])
+	it.resources = it.resources[1:]
+	return it.err == nil
+}
+
+func (it *sliceIterator) Resource() *prop.Resource {
+	return it.current
+}
+
+func (it *sliceIterator) Err() error {
+	return it.err
+}
+
+func (it *sliceIterator) Close() error {
+	it.current, it.resources = nil, nil
+	return nil
+}
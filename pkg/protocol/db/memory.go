@@ -5,14 +5,19 @@ import (
 	"github.com/imulab/go-scim/pkg/core/errors"
 	"github.com/imulab/go-scim/pkg/core/expr"
 	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
 	"github.com/imulab/go-scim/pkg/protocol/crud"
 	"sync"
 )
 
-// Return a new memory implementation of Database. This implementation saves resources in memory. Although
-// it does allow for concurrent access through the use of RWMutex, it does not allow for high throughput usage.
-// It is intended for testing and showcasing purposes only. This implementation also ignores all the field projection
-// parameters that it always returned the full resource regardless of the request to include or exclude attributes.
+// Return a new memory implementation of Database. This implementation saves resources in memory, and is safe for
+// concurrent use. It stores a clone of every resource it is given, and returns clones of what it stores, so that
+// callers can never modify its state except through its methods. As every operation holds a lock on the whole
+// database, it does not allow for high throughput usage. It is intended for testing and showcasing purposes only. The
+// returned resources are trimmed according to the projection parameters with crud.Project.
+//
+// The id attribute and attributes whose uniqueness is server are indexed, once the resource type is known from the
+// first inserted resource. Use IndexedMemory to index additional attributes.
 func Memory() DB {
 	return &memoryDB{
 		RWMutex: sync.RWMutex{},
@@ -20,81 +25,296 @@ func Memory() DB {
 	}
 }
 
+// Return a new memory implementation of Database for resources of the resource type, which maintains hash indexes on
+// the attributes at the given paths, in addition to the id attribute and attributes whose uniqueness is server. Only
+// string, reference, boolean, integer and decimal attributes can be indexed.
+//
+// Count and Query look up the indexes for eq and pr filters on indexed attributes, and for conjunctions of them, and
+// only evaluate the filter against the resources found. Any other filter is evaluated against all resources.
+func IndexedMemory(resourceType *spec.ResourceType, paths ...string) (DB, error) {
+	indexes, err := newIndexes(resourceType, paths...)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryDB{
+		RWMutex:      sync.RWMutex{},
+		db:           make(map[string]*prop.Resource),
+		resourceType: resourceType,
+		indexes:      indexes,
+	}, nil
+}
+
+// Number of resources evaluated between checks for cancellation of the context
+const cancellationCheckInterval = 256
+
 type memoryDB struct {
 	sync.RWMutex
-	db map[string]*prop.Resource
+	db           map[string]*prop.Resource
+	resourceType *spec.ResourceType
+	indexes      map[string]*index
 }
 
 func (m *memoryDB) Insert(ctx context.Context, resource *prop.Resource) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	id := resource.ID()
 	if len(id) == 0 {
 		return errors.Internal("cannot save resource with empty id")
 	}
+	clone := resource.Clone()
+
+	m.Lock()
+	defer m.Unlock()
 
 	if _, ok := m.db[id]; ok {
 		return errors.Internal("resource with id '%s' already exists", id)
 	}
-
-	m.Lock()
-	defer m.Unlock()
-	m.db[id] = resource
+	if m.resourceType == nil {
+		indexes, err := newIndexes(clone.ResourceType())
+		if err != nil {
+			return err
+		}
+		m.resourceType = clone.ResourceType()
+		m.indexes = indexes
+	}
+	m.db[id] = clone
+	for _, idx := range m.indexes {
+		idx.add(clone)
+	}
 
 	return nil
 }
 
-func (m *memoryDB) Get(ctx context.Context, id string, _ *crud.Projection) (*prop.Resource, error) {
+func (m *memoryDB) Get(ctx context.Context, id string, projection *crud.Projection) (*prop.Resource, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
 	r, ok := m.db[id]
 	if !ok {
 		return nil, errors.NotFound("resource by id [%s] is not found", id)
 	}
-	return r, nil
+	return project(r, projection)
 }
 
 func (m *memoryDB) Count(ctx context.Context, filter string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
 	if len(filter) == 0 {
 		return len(m.db), nil
 	}
 
-	root, err := expr.CompileFilter(filter)
+	candidates, err := query(ctx, m.db, m.plan, filter, nil, nil)
 	if err != nil {
 		return 0, err
 	}
-	n := 0
-	for _, r := range m.db {
-		ok, _ := crud.Evaluate(r.NewNavigator().Current(), root)
-		if ok {
-			n++
-		}
-	}
-	return n, nil
+	return len(candidates), nil
 }
 
 func (m *memoryDB) Replace(ctx context.Context, resource *prop.Resource) error {
+	return m.replace(ctx, resource, nil)
+}
+
+func (m *memoryDB) CompareAndReplace(ctx context.Context, resource *prop.Resource, version string) error {
+	return m.replace(ctx, resource, &version)
+}
+
+// Replace the existing resource with the same id, provided that the existing resource is at the version, if not nil.
+func (m *memoryDB) replace(ctx context.Context, resource *prop.Resource, version *string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	id := resource.ID()
-	_, ok := m.db[id]
+	clone := resource.Clone()
+
+	m.Lock()
+	defer m.Unlock()
+
+	existing, ok := m.db[id]
 	if !ok {
 		return errors.NotFound("resource by id [%s] is not found", id)
 	}
-	m.db[id] = resource
+	if version != nil {
+		if err := checkVersion(existing, *version); err != nil {
+			return err
+		}
+	}
+	m.db[id] = clone
+	for _, idx := range m.indexes {
+		idx.remove(existing)
+		idx.add(clone)
+	}
+
 	return nil
 }
 
 func (m *memoryDB) Delete(ctx context.Context, id string) error {
-	delete(m.db, id)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	if existing, ok := m.db[id]; ok {
+		m.delete(existing)
+	}
+	return nil
+}
+
+func (m *memoryDB) CompareAndDelete(ctx context.Context, id string, version string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	existing, ok := m.db[id]
+	if !ok {
+		return errors.NotFound("resource by id [%s] is not found", id)
+	}
+	if err := checkVersion(existing, version); err != nil {
+		return err
+	}
+	m.delete(existing)
 	return nil
 }
 
-func (m *memoryDB) Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, _ *crud.Projection) ([]*prop.Resource, error) {
-	root, err := expr.CompileFilter(filter)
+// Delete the stored resource. Caller must hold the write lock.
+func (m *memoryDB) delete(existing *prop.Resource) {
+	delete(m.db, existing.ID())
+	for _, idx := range m.indexes {
+		idx.remove(existing)
+	}
+}
+
+func (m *memoryDB) Query(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) ([]*prop.Resource, error) {
+	iterator, err := m.QueryIterator(ctx, filter, sort, pagination, projection)
+	if err != nil {
+		return nil, err
+	}
+	return Collect(iterator)
+}
+
+func (m *memoryDB) QueryIterator(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.Pagination, projection *crud.Projection) (Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	candidates, err := query(ctx, m.db, m.plan, filter, sort, pagination)
 	if err != nil {
 		return nil, err
 	}
+	return snapshot(ctx, candidates, projection), nil
+}
 
+func (m *memoryDB) QueryCursor(ctx context.Context, filter string, sort *crud.Sort, pagination *crud.CursorPagination, projection *crud.Projection) ([]*prop.Resource, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	candidates, next, err := queryCursor(ctx, m.db, m.plan, filter, sort, pagination)
+	if err != nil {
+		return nil, "", err
+	}
+	for i, r := range candidates {
+		if candidates[i], err = project(r, projection); err != nil {
+			return nil, "", err
+		}
+	}
+	return candidates, next, nil
+}
+
+// Returns a preCondition error if the stored resource is not at the expected version.
+func checkVersion(existing *prop.Resource, version string) error {
+	if existing.Version() != version {
+		return errors.PreConditionFailed("resource [id=%s] has been modified: expected version %s, but found %s",
+			existing.ID(), version, existing.Version())
+	}
+	return nil
+}
+
+// Return a clone of the stored resource, with the projection applied.
+func project(resource *prop.Resource, projection *crud.Projection) (*prop.Resource, error) {
+	clone := resource.Clone()
+	if err := crud.Project(clone, projection); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+func (m *memoryDB) plan(filter *expr.Expression) (map[string]struct{}, bool) {
+	return plan(m.resourceType, m.indexes, filter)
+}
+
+// Return the resources matching the filter, sorted and paginated. An empty filter matches all resources. The optional
+// planner narrows down the resources to evaluate the filter against; when it is absent or cannot plan the filter, all
+// resources are evaluated. The context is checked for cancellation while the resources are evaluated. The returned
+// resources are the stored instances, which the caller must not modify or hand out.
+func query(ctx context.Context, resources map[string]*prop.Resource, planner func(filter *expr.Expression) (map[string]struct{}, bool),
+	filter string, sort *crud.Sort, pagination *crud.Pagination) ([]*prop.Resource, error) {
 	var candidates = make([]*prop.Resource, 0)
-	for _, r := range m.db {
-		if ok, _ := crud.Evaluate(r.NewNavigator().Current(), root); ok {
+	if len(filter) == 0 {
+		for _, r := range resources {
 			candidates = append(candidates, r)
 		}
+	} else {
+		root, err := expr.CompileFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		n := 0
+		evaluate := func(r *prop.Resource) error {
+			if n++; n%cancellationCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if ok, _ := crud.Evaluate(r.NewNavigator().Current(), root); ok {
+				candidates = append(candidates, r)
+			}
+			return nil
+		}
+		var (
+			ids     map[string]struct{}
+			planned bool
+		)
+		if planner != nil {
+			ids, planned = planner(root)
+		}
+		if planned {
+			for id := range ids {
+				if r, ok := resources[id]; ok {
+					if err := evaluate(r); err != nil {
+						return nil, err
+					}
+				}
+			}
+		} else {
+			for _, r := range resources {
+				if err := evaluate(r); err != nil {
+					return nil, err
+				}
+			}
+		}
 	}
 	if len(candidates) == 0 {
 		return []*prop.Resource{}, nil
@@ -110,10 +330,14 @@ func (m *memoryDB) Query(ctx context.Context, filter string, sort *crud.Sort, pa
 		lb := pagination.StartIndex - 1
 		if lb < 0 {
 			lb = 0
+		} else if lb > len(candidates) {
+			lb = len(candidates)
 		}
 		ub := pagination.StartIndex + pagination.Count - 1
 		if ub > len(candidates) {
 			ub = len(candidates)
+		} else if ub < lb {
+			ub = lb
 		}
 		candidates = candidates[lb:ub]
 	}
@@ -3,6 +3,9 @@ package db
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
@@ -12,6 +15,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 )
 
@@ -260,6 +264,114 @@ func (s *MemoryDBTestSuite) TestCount() {
 				assert.Equal(t, 0, count)
 			},
 		},
+		{
+			name: "find by value path filter",
+			getDB: func(t *testing.T) DB {
+				db := Memory()
+				for _, f := range []string{
+					"/user_001.json",
+					"/user_002.json",
+					"/user_003.json",
+					"/user_004.json",
+					"/user_005.json",
+					"/user_006.json",
+					"/user_007.json",
+					"/user_008.json",
+					"/user_009.json",
+					"/user_010.json",
+				} {
+					err := db.Insert(context.Background(), s.mustResource(f, resourceType))
+					require.Nil(t, err)
+				}
+				return db
+			},
+			filter: "emails[type eq \"work\" and value co \"@foo.com\"]",
+			expect: func(t *testing.T, count int, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 10, count)
+			},
+		},
+		{
+			name: "find by value path filter matching different elements",
+			getDB: func(t *testing.T) DB {
+				db := Memory()
+				for _, f := range []string{
+					"/user_001.json",
+					"/user_002.json",
+					"/user_003.json",
+					"/user_004.json",
+					"/user_005.json",
+					"/user_006.json",
+					"/user_007.json",
+					"/user_008.json",
+					"/user_009.json",
+					"/user_010.json",
+				} {
+					err := db.Insert(context.Background(), s.mustResource(f, resourceType))
+					require.Nil(t, err)
+				}
+				return db
+			},
+			filter: "emails[type eq \"home\" and value co \"@foo.com\"]",
+			expect: func(t *testing.T, count int, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 0, count)
+			},
+		},
+		{
+			name: "find by value path filter and other predicate",
+			getDB: func(t *testing.T) DB {
+				db := Memory()
+				for _, f := range []string{
+					"/user_001.json",
+					"/user_002.json",
+					"/user_003.json",
+					"/user_004.json",
+					"/user_005.json",
+					"/user_006.json",
+					"/user_007.json",
+					"/user_008.json",
+					"/user_009.json",
+					"/user_010.json",
+				} {
+					err := db.Insert(context.Background(), s.mustResource(f, resourceType))
+					require.Nil(t, err)
+				}
+				return db
+			},
+			filter: "emails[type eq \"work\"] and userName eq \"user003\"",
+			expect: func(t *testing.T, count int, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 1, count)
+			},
+		},
+		{
+			name: "find by sub attribute of value path filter",
+			getDB: func(t *testing.T) DB {
+				db := Memory()
+				for _, f := range []string{
+					"/user_001.json",
+					"/user_002.json",
+					"/user_003.json",
+					"/user_004.json",
+					"/user_005.json",
+					"/user_006.json",
+					"/user_007.json",
+					"/user_008.json",
+					"/user_009.json",
+					"/user_010.json",
+				} {
+					err := db.Insert(context.Background(), s.mustResource(f, resourceType))
+					require.Nil(t, err)
+				}
+				return db
+			},
+			filter: "emails[type eq \"work\"].value co \"@bar.com\"",
+			expect: func(t *testing.T, count int, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 0, count)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -352,6 +464,93 @@ func (s *MemoryDBTestSuite) TestDelete() {
 	}
 }
 
+func (s *MemoryDBTestSuite) TestCompareAndSwap() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	const id = "a5866759-32ca-4e2a-9808-a0fe74f94b18"
+
+	tests := []struct {
+		name   string
+		swap   func(t *testing.T, db DB, version string) error
+		expect func(t *testing.T, db DB, err error)
+	}{
+		{
+			name: "replace at expected version",
+			swap: func(t *testing.T, db DB, version string) error {
+				r := s.mustResource("/user_001.json", resourceType)
+				require.Nil(t, crud.Replace(r, "meta.version", "W/\"2\""))
+				return db.CompareAndReplace(context.Background(), r, version)
+			},
+			expect: func(t *testing.T, db DB, err error) {
+				assert.Nil(t, err)
+				r, err := db.Get(context.Background(), id, nil)
+				require.Nil(t, err)
+				assert.Equal(t, "W/\"2\"", r.Version())
+			},
+		},
+		{
+			name: "replace at stale version",
+			swap: func(t *testing.T, db DB, version string) error {
+				r := s.mustResource("/user_001.json", resourceType)
+				require.Nil(t, crud.Replace(r, "meta.version", "W/\"2\""))
+				return db.CompareAndReplace(context.Background(), r, "W/\"0\"")
+			},
+			expect: func(t *testing.T, db DB, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypePreCondition, err.(*errors.Error).Type)
+				r, err := db.Get(context.Background(), id, nil)
+				require.Nil(t, err)
+				assert.Equal(t, "W/\"1\"", r.Version())
+			},
+		},
+		{
+			name: "replace missing resource",
+			swap: func(t *testing.T, db DB, version string) error {
+				require.Nil(t, db.Delete(context.Background(), id))
+				return db.CompareAndReplace(context.Background(), s.mustResource("/user_001.json", resourceType), version)
+			},
+			expect: func(t *testing.T, db DB, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeNotFound, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name: "delete at expected version",
+			swap: func(t *testing.T, db DB, version string) error {
+				return db.CompareAndDelete(context.Background(), id, version)
+			},
+			expect: func(t *testing.T, db DB, err error) {
+				assert.Nil(t, err)
+				_, err = db.Get(context.Background(), id, nil)
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name: "delete at stale version",
+			swap: func(t *testing.T, db DB, version string) error {
+				return db.CompareAndDelete(context.Background(), id, "W/\"0\"")
+			},
+			expect: func(t *testing.T, db DB, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypePreCondition, err.(*errors.Error).Type)
+				_, err = db.Get(context.Background(), id, nil)
+				assert.Nil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			db := Memory()
+			inserted := s.mustResource("/user_001.json", resourceType)
+			require.Nil(t, db.Insert(context.Background(), inserted))
+			err := test.swap(t, db, inserted.Version())
+			test.expect(t, db, err)
+		})
+	}
+}
+
 func (s *MemoryDBTestSuite) TestQuery() {
 	_ = s.mustSchema("/user_schema.json")
 	resourceType := s.mustResourceType("/user_resource_type.json")
@@ -468,6 +667,546 @@ func (s *MemoryDBTestSuite) TestQuery() {
 	}
 }
 
+func (s *MemoryDBTestSuite) TestIsolation() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	const id = "a5866759-32ca-4e2a-9808-a0fe74f94b18"
+
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, db DB, inserted *prop.Resource)
+	}{
+		{
+			name: "modify inserted resource",
+			modify: func(t *testing.T, db DB, inserted *prop.Resource) {
+				require.Nil(t, crud.Replace(inserted, "userName", "foobar"))
+			},
+		},
+		{
+			name: "modify resource from get",
+			modify: func(t *testing.T, db DB, inserted *prop.Resource) {
+				r, err := db.Get(context.Background(), id, nil)
+				require.Nil(t, err)
+				require.Nil(t, crud.Replace(r, "userName", "foobar"))
+			},
+		},
+		{
+			name: "modify resource from query",
+			modify: func(t *testing.T, db DB, inserted *prop.Resource) {
+				results, err := db.Query(context.Background(), "userName pr", nil, nil, nil)
+				require.Nil(t, err)
+				require.Len(t, results, 1)
+				require.Nil(t, crud.Replace(results[0], "userName", "foobar"))
+			},
+		},
+		{
+			name: "modify replacement resource",
+			modify: func(t *testing.T, db DB, inserted *prop.Resource) {
+				r := s.mustResource("/user_001.json", resourceType)
+				require.Nil(t, db.Replace(context.Background(), r))
+				require.Nil(t, crud.Replace(r, "userName", "foobar"))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			db := Memory()
+			inserted := s.mustResource("/user_001.json", resourceType)
+			require.Nil(t, db.Insert(context.Background(), inserted))
+
+			test.modify(t, db, inserted)
+
+			r, err := db.Get(context.Background(), id, nil)
+			require.Nil(t, err)
+			p, err := r.NewNavigator().FocusName("userName")
+			require.Nil(t, err)
+			assert.Equal(t, "user001", p.Raw())
+		})
+	}
+}
+
+func (s *MemoryDBTestSuite) TestCancellation() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	db := Memory()
+	require.Nil(s.T(), db.Insert(context.Background(), s.mustResource("/user_001.json", resourceType)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := db.Insert(ctx, s.mustResource("/user_002.json", resourceType))
+	assert.Equal(s.T(), context.Canceled, err)
+	_, err = db.Get(ctx, "a5866759-32ca-4e2a-9808-a0fe74f94b18", nil)
+	assert.Equal(s.T(), context.Canceled, err)
+	_, err = db.Count(ctx, "")
+	assert.Equal(s.T(), context.Canceled, err)
+	_, err = db.Query(ctx, "userName pr", nil, nil, nil)
+	assert.Equal(s.T(), context.Canceled, err)
+	err = db.Replace(ctx, s.mustResource("/user_001.json", resourceType))
+	assert.Equal(s.T(), context.Canceled, err)
+	err = db.Delete(ctx, "a5866759-32ca-4e2a-9808-a0fe74f94b18")
+	assert.Equal(s.T(), context.Canceled, err)
+
+	count, err := db.Count(context.Background(), "")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 1, count)
+}
+
+// Exercise the database with concurrent creates, patches and queries. Run with the race detector, i.e.
+// go test -race, to detect unsynchronized access.
+func (s *MemoryDBTestSuite) TestConcurrency() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	const (
+		workers    = 8
+		iterations = 50
+	)
+
+	var (
+		db       = Memory()
+		template = s.mustResource("/user_001.json", resourceType)
+		wg       sync.WaitGroup
+		errs     = make(chan error, workers*iterations*3)
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(3)
+
+		// create
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				r := template.Clone()
+				if err := r.Replace(map[string]interface{}{
+					"id":       fmt.Sprintf("%d-%d", w, i),
+					"userName": fmt.Sprintf("user-%d-%d", w, i),
+				}); err != nil {
+					errs <- err
+					continue
+				}
+				if err := db.Insert(context.Background(), r); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+
+		// patch
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				r, err := db.Get(context.Background(), fmt.Sprintf("%d-%d", w, i), nil)
+				if err != nil {
+					// not created yet
+					continue
+				}
+				if err := crud.Replace(r, "displayName", fmt.Sprintf("patched-%d", i)); err != nil {
+					errs <- err
+					continue
+				}
+				if err := db.Replace(context.Background(), r); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+
+		// query
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				results, err := db.Query(context.Background(), "userName sw \"user-\"", &crud.Sort{By: "userName"}, &crud.Pagination{StartIndex: 1, Count: 10}, nil)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				for _, r := range results {
+					if err := crud.Replace(r, "displayName", "modified by query"); err != nil {
+						errs <- err
+					}
+				}
+				if _, err := db.Count(context.Background(), "displayName pr"); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Nil(s.T(), err)
+	}
+
+	count, err := db.Count(context.Background(), "userName sw \"user-\"")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), workers*iterations, count)
+
+	count, err = db.Count(context.Background(), "displayName eq \"modified by query\"")
+	assert.Nil(s.T(), err)
+	assert.Equal(s.T(), 0, count)
+}
+
+func (s *MemoryDBTestSuite) TestProjection() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	db := Memory()
+	inserted := s.mustResource("/user_001.json", resourceType)
+	require.Nil(s.T(), db.Insert(context.Background(), inserted))
+
+	projection := &crud.Projection{Attributes: []string{"userName"}}
+	got, err := db.Get(context.Background(), inserted.ID(), projection)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), "user001", got.NewFluentNavigator().FocusName("userName").Current().Raw())
+	assert.True(s.T(), got.NewFluentNavigator().FocusName("emails").Current().IsUnassigned())
+	assert.Equal(s.T(), inserted.Version(), got.Version())
+
+	results, err := db.Query(context.Background(), "userName eq \"user001\"", nil, nil, projection)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), results, 1)
+	assert.Equal(s.T(), got.Hash(), results[0].Hash())
+
+	got, err = db.Get(context.Background(), inserted.ID(), nil)
+	require.Nil(s.T(), err)
+	assert.Equal(s.T(), inserted.Hash(), got.Hash())
+}
+
+func (s *MemoryDBTestSuite) TestIndex() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	tests := []struct {
+		name        string
+		paths       []string
+		filter      string
+		expectPlan  bool
+		expectCount int
+	}{
+		{
+			name:        "eq on server unique attribute",
+			filter:      "userName eq \"user003\"",
+			expectPlan:  true,
+			expectCount: 1,
+		},
+		{
+			name:        "eq on case insensitive attribute",
+			filter:      "userName eq \"USER003\"",
+			expectPlan:  true,
+			expectCount: 1,
+		},
+		{
+			name:        "eq on replaced value",
+			filter:      "userName eq \"user001\"",
+			expectPlan:  true,
+			expectCount: 0,
+		},
+		{
+			name:        "eq on replacing value",
+			filter:      "userName eq \"foobar\"",
+			expectPlan:  true,
+			expectCount: 1,
+		},
+		{
+			name:        "eq on deleted value",
+			filter:      "userName eq \"user002\"",
+			expectPlan:  true,
+			expectCount: 0,
+		},
+		{
+			name:        "pr on server unique attribute",
+			filter:      "userName pr",
+			expectPlan:  true,
+			expectCount: 9,
+		},
+		{
+			name:        "eq on multiValued attribute",
+			paths:       []string{"urn:ietf:params:scim:schemas:core:2.0:User:emails.value"},
+			filter:      "emails.value eq \"IMULAB@bar.com\"",
+			expectPlan:  true,
+			expectCount: 9,
+		},
+		{
+			name:        "eq on attribute not indexed",
+			filter:      "emails.value eq \"imulab@bar.com\"",
+			expectPlan:  false,
+			expectCount: 9,
+		},
+		{
+			name:        "eq on boolean attribute",
+			paths:       []string{"active"},
+			filter:      "active eq true",
+			expectPlan:  true,
+			expectCount: 9,
+		},
+		{
+			name:        "conjunction of indexed attributes",
+			paths:       []string{"active"},
+			filter:      "userName eq \"user003\" and active eq true",
+			expectPlan:  true,
+			expectCount: 1,
+		},
+		{
+			name:        "conjunction with attribute not indexed",
+			filter:      "userName eq \"user003\" and displayName eq \"foo\"",
+			expectPlan:  true,
+			expectCount: 0,
+		},
+		{
+			name:        "disjunction",
+			filter:      "userName eq \"user003\" or userName eq \"user004\"",
+			expectPlan:  false,
+			expectCount: 2,
+		},
+		{
+			name:        "other operator",
+			filter:      "userName sw \"user00\"",
+			expectPlan:  false,
+			expectCount: 7,
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			db, err := IndexedMemory(resourceType, test.paths...)
+			require.Nil(t, err)
+			for _, f := range []string{
+				"/user_001.json",
+				"/user_002.json",
+				"/user_003.json",
+				"/user_004.json",
+				"/user_005.json",
+				"/user_006.json",
+				"/user_007.json",
+				"/user_008.json",
+				"/user_009.json",
+				"/user_010.json",
+			} {
+				require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+			}
+
+			replaced := s.mustResource("/user_001.json", resourceType)
+			require.Nil(t, crud.Replace(replaced, "userName", "foobar"))
+			require.Nil(t, db.Replace(context.Background(), replaced))
+			require.Nil(t, db.Delete(context.Background(), s.mustResource("/user_002.json", resourceType).ID()))
+
+			root, err := expr.CompileFilter(test.filter)
+			require.Nil(t, err)
+			_, planned := db.(*memoryDB).plan(root)
+			assert.Equal(t, test.expectPlan, planned)
+
+			count, err := db.Count(context.Background(), test.filter)
+			assert.Nil(t, err)
+			assert.Equal(t, test.expectCount, count)
+
+			results, err := db.Query(context.Background(), test.filter, nil, nil, nil)
+			assert.Nil(t, err)
+			assert.Len(t, results, test.expectCount)
+		})
+	}
+}
+
+func (s *MemoryDBTestSuite) TestInvalidIndex() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	for _, path := range []string{
+		"foo",
+		"name",
+		"meta.created",
+		"emails[type eq \"work\"].value",
+	} {
+		_, err := IndexedMemory(resourceType, path)
+		assert.NotNil(s.T(), err, path)
+	}
+}
+
+func (s *MemoryDBTestSuite) TestQueryIterator() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	db := Memory()
+	for _, f := range []string{"/user_003.json", "/user_001.json", "/user_002.json"} {
+		require.Nil(s.T(), db.Insert(context.Background(), s.mustResource(f, resourceType)))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	iterator, err := db.QueryIterator(ctx, "userName pr", &crud.Sort{By: "userName"}, nil,
+		&crud.Projection{Attributes: []string{"userName"}})
+	require.Nil(s.T(), err)
+	defer iterator.Close()
+
+	// the database is not locked while iterating, and writes do not affect the results
+	require.True(s.T(), iterator.Next())
+	first := iterator.Resource()
+	require.Nil(s.T(), db.Insert(context.Background(), s.mustResource("/user_004.json", resourceType)))
+	require.Nil(s.T(), db.Delete(context.Background(), "23d22b2d-4fc4-49f9-90fb-ee10882c69ed"))
+	assert.Equal(s.T(), "user001", first.NewFluentNavigator().FocusName("userName").Current().Raw())
+	assert.True(s.T(), first.NewFluentNavigator().FocusName("emails").Current().IsUnassigned())
+
+	require.True(s.T(), iterator.Next())
+	assert.Equal(s.T(), "user002", iterator.Resource().NewFluentNavigator().FocusName("userName").Current().Raw())
+
+	cancel()
+	assert.False(s.T(), iterator.Next())
+	assert.Equal(s.T(), context.Canceled, iterator.Err())
+}
+
+func (s *MemoryDBTestSuite) TestCursorPagination() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	all := []string{
+		"/user_003.json",
+		"/user_002.json",
+		"/user_001.json",
+		"/user_004.json",
+		"/user_005.json",
+		"/user_009.json",
+		"/user_010.json",
+		"/user_008.json",
+		"/user_007.json",
+		"/user_006.json",
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		sort  *crud.Sort
+		count int
+		// called after every page is fetched
+		between func(t *testing.T, db DB, page int)
+		expect  func(t *testing.T, userNames []string)
+	}{
+		{
+			name:  "sort ascending",
+			files: all,
+			sort:  &crud.Sort{By: "userName", Order: crud.SortAsc},
+			count: 3,
+			expect: func(t *testing.T, userNames []string) {
+				assert.Equal(t, []string{
+					"user001", "user002", "user003", "user004", "user005",
+					"user006", "user007", "user008", "user009", "user010",
+				}, userNames)
+			},
+		},
+		{
+			name:  "sort descending",
+			files: all,
+			sort:  &crud.Sort{By: "userName", Order: crud.SortDesc},
+			count: 4,
+			expect: func(t *testing.T, userNames []string) {
+				assert.Equal(t, []string{
+					"user010", "user009", "user008", "user007", "user006",
+					"user005", "user004", "user003", "user002", "user001",
+				}, userNames)
+			},
+		},
+		{
+			name:  "sort by tied values",
+			files: all,
+			sort:  &crud.Sort{By: "name.familyName"},
+			count: 3,
+			expect: func(t *testing.T, userNames []string) {
+				assert.Len(t, userNames, 10)
+				assert.ElementsMatch(t, []string{
+					"user001", "user002", "user003", "user004", "user005",
+					"user006", "user007", "user008", "user009", "user010",
+				}, userNames)
+			},
+		},
+		{
+			name:  "insert and delete between pages",
+			files: []string{"/user_002.json", "/user_004.json", "/user_005.json", "/user_006.json", "/user_008.json"},
+			sort:  &crud.Sort{By: "userName"},
+			count: 2,
+			between: func(t *testing.T, db DB, page int) {
+				if page != 1 {
+					return
+				}
+				// before the cursor, after the cursor, and the resource at the cursor
+				require.Nil(t, db.Insert(context.Background(), s.mustResource("/user_001.json", resourceType)))
+				require.Nil(t, db.Insert(context.Background(), s.mustResource("/user_007.json", resourceType)))
+				require.Nil(t, db.Delete(context.Background(), "1509dd67-2cf2-4e83-9945-02259403e889"))
+			},
+			expect: func(t *testing.T, userNames []string) {
+				assert.Equal(t, []string{"user002", "user004", "user005", "user006", "user007", "user008"}, userNames)
+			},
+		},
+		{
+			name:  "no sort",
+			files: all,
+			count: 10,
+			expect: func(t *testing.T, userNames []string) {
+				assert.Len(t, userNames, 10)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			db := Memory()
+			for _, f := range test.files {
+				require.Nil(t, db.Insert(context.Background(), s.mustResource(f, resourceType)))
+			}
+
+			userNames := make([]string, 0)
+			pagination := &crud.CursorPagination{Count: test.count}
+			for page := 1; ; page++ {
+				results, next, err := db.QueryCursor(context.Background(), "userName pr", test.sort, pagination, nil)
+				require.Nil(t, err)
+				require.True(t, len(results) <= test.count)
+				for _, r := range results {
+					userNames = append(userNames, r.NewFluentNavigator().FocusName("userName").Current().Raw().(string))
+				}
+				if len(next) == 0 {
+					break
+				}
+				if test.between != nil {
+					test.between(t, db, page)
+				}
+				pagination = &crud.CursorPagination{Cursor: next, Count: test.count}
+			}
+			test.expect(t, userNames)
+		})
+	}
+}
+
+func (s *MemoryDBTestSuite) TestInvalidCursor() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	db := Memory()
+	for _, f := range []string{"/user_001.json", "/user_002.json", "/user_003.json"} {
+		require.Nil(s.T(), db.Insert(context.Background(), s.mustResource(f, resourceType)))
+	}
+
+	sort := &crud.Sort{By: "userName"}
+	_, next, err := db.QueryCursor(context.Background(), "userName pr", sort, &crud.CursorPagination{Count: 1}, nil)
+	require.Nil(s.T(), err)
+	require.NotEmpty(s.T(), next)
+
+	for _, test := range []struct {
+		name   string
+		filter string
+		sort   *crud.Sort
+		cursor string
+	}{
+		{name: "malformed", filter: "userName pr", sort: sort, cursor: "foo"},
+		{name: "different filter", filter: "id pr", sort: sort, cursor: next},
+		{name: "different sort", filter: "userName pr", sort: &crud.Sort{By: "userName", Order: crud.SortDesc}, cursor: next},
+	} {
+		_, _, err := db.QueryCursor(context.Background(), test.filter, test.sort, &crud.CursorPagination{Cursor: test.cursor, Count: 1}, nil)
+		require.NotNil(s.T(), err, test.name)
+		assert.Equal(s.T(), errors.TypeInvalidCursor, err.(*errors.Error).Type, test.name)
+	}
+}
+
 func (s *MemoryDBTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
 	f, err := os.Open(s.resourceBase + filePath)
 	s.Require().Nil(err)
@@ -511,3 +1250,150 @@ func (s *MemoryDBTestSuite) mustSchema(filePath string) *spec.Schema {
 
 	return sch
 }
+
+const benchmarkUserCount = 100000
+
+var benchmarkUsers struct {
+	sync.Once
+	resourceType *spec.ResourceType
+	resources    map[string]*prop.Resource
+}
+
+// Return a map of 100k users, keyed by id, whose userName is "user-<n>", and the resource type of the users.
+func mustBenchmarkUsers(b *testing.B) (*spec.ResourceType, map[string]*prop.Resource) {
+	benchmarkUsers.Do(func() {
+		const resourceBase = "../../tests/memory_db_test_suite"
+
+		read := func(filePath string) []byte {
+			raw, err := ioutil.ReadFile(resourceBase + filePath)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return raw
+		}
+
+		sch := new(spec.Schema)
+		if err := json.Unmarshal(read("/user_schema.json"), sch); err != nil {
+			b.Fatal(err)
+		}
+		spec.SchemaHub.Put(sch)
+
+		resourceType := new(spec.ResourceType)
+		if err := json.Unmarshal(read("/user_resource_type.json"), resourceType); err != nil {
+			b.Fatal(err)
+		}
+
+		template := prop.NewResource(resourceType)
+		if err := scimJSON.Deserialize(read("/user_001.json"), template); err != nil {
+			b.Fatal(err)
+		}
+
+		resources := make(map[string]*prop.Resource, benchmarkUserCount)
+		for i := 0; i < benchmarkUserCount; i++ {
+			r := template.Clone()
+			if err := r.Replace(map[string]interface{}{
+				"id":       fmt.Sprintf("%d", i),
+				"userName": fmt.Sprintf("user-%d", i),
+			}); err != nil {
+				b.Fatal(err)
+			}
+			resources[r.ID()] = r
+		}
+
+		benchmarkUsers.resourceType = resourceType
+		benchmarkUsers.resources = resources
+	})
+	if benchmarkUsers.resources == nil {
+		b.Fatal("failed to set up users")
+	}
+	return benchmarkUsers.resourceType, benchmarkUsers.resources
+}
+
+// Return a database with 100k users, which is indexed unless scan is true. The users are shared among databases
+// and must not be modified.
+func mustBenchmarkDB(b *testing.B, scan bool) DB {
+	resourceType, resources := mustBenchmarkUsers(b)
+	db := &memoryDB{db: resources, resourceType: resourceType}
+	if !scan {
+		indexes, err := newIndexes(resourceType)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, idx := range indexes {
+			for _, r := range resources {
+				idx.add(r)
+			}
+		}
+		db.indexes = indexes
+	}
+	return db
+}
+
+func BenchmarkMemoryDBCount(b *testing.B) {
+	benchmarks := []struct {
+		name   string
+		scan   bool
+		filter string
+	}{
+		{name: "indexed eq", filter: "userName eq \"user-50000\""},
+		{name: "scanned eq", scan: true, filter: "userName eq \"user-50000\""},
+		{name: "indexed uniqueness", filter: "(id ne \"1\") and (userName eq \"user-50000\")"},
+		{name: "scanned uniqueness", scan: true, filter: "(id ne \"1\") and (userName eq \"user-50000\")"},
+		{name: "indexed id", filter: "id eq \"50000\""},
+		{name: "not indexed", filter: "displayName eq \"foo\""},
+	}
+
+	for _, bm := range benchmarks {
+		db := mustBenchmarkDB(b, bm.scan)
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := db.Count(context.Background(), bm.filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryDBInsert(b *testing.B) {
+	resourceType, resources := mustBenchmarkUsers(b)
+	users := make([]*prop.Resource, 0, len(resources))
+	for _, r := range resources {
+		users = append(users, r)
+	}
+
+	benchmarks := []struct {
+		name  string
+		newDB func() DB
+	}{
+		{name: "indexed", newDB: func() DB {
+			db, err := IndexedMemory(resourceType)
+			if err != nil {
+				b.Fatal(err)
+			}
+			return db
+		}},
+		{name: "not indexed", newDB: func() DB {
+			// known resource type prevents indexes from being created on first insert
+			return &memoryDB{db: make(map[string]*prop.Resource), resourceType: resourceType}
+		}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			db := bm.newDB()
+			for i := 0; i < b.N; i++ {
+				if i > 0 && i%len(users) == 0 {
+					b.StopTimer()
+					db = bm.newDB()
+					b.StartTimer()
+				}
+				if err := db.Insert(context.Background(), users[i%len(users)]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
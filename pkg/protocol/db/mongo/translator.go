@@ -0,0 +1,323 @@
+// Package mongo translates SCIM filters and projections to MongoDB query and projection documents. The documents are
+// plain maps, so they can be passed to any MongoDB driver, and translation does not require a running database.
+package mongo
+
+import (
+	"github.com/imulab/go-scim/pkg/core/annotations"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"regexp"
+	"strings"
+)
+
+// Translator of SCIM queries on resources of a resource type to MongoDB documents.
+type Translator struct {
+	ResourceType *spec.ResourceType
+	// Optional function which returns the field name of the attribute at the path, relative to the resource or to the
+	// element of a multiValued attribute. By default, the names of the attributes are joined with dots, and the dots
+	// within the namespaces of schema extensions are replaced by underscores, as MongoDB does not allow dots in field
+	// names.
+	FieldName func(path []*spec.Attribute) string
+}
+
+// Compile and translate the SCIM filter to a MongoDB query document.
+func (t *Translator) Filter(filter string) (map[string]interface{}, error) {
+	root, err := expr.CompileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	return t.Query(root)
+}
+
+// Translate the compiled SCIM filter to a MongoDB query document. The filter is validated against the resource type
+// before translation.
+func (t *Translator) Query(filter *expr.Expression) (map[string]interface{}, error) {
+	if err := crud.Validate(filter, t.ResourceType); err != nil {
+		return nil, err
+	}
+	return t.filter(filter, t.ResourceType.SuperAttribute(true), true)
+}
+
+// Translate the projection to a MongoDB projection document, or return nil if all fields shall be returned.
+// Attributes whose returned-ability is always are never excluded, and are always included when specific attributes
+// are requested.
+func (t *Translator) Projection(projection *crud.Projection) (map[string]interface{}, error) {
+	if projection == nil || (len(projection.Attributes) == 0 && len(projection.ExcludedAttributes) == 0) {
+		return nil, nil
+	}
+	if len(projection.Attributes) > 0 && len(projection.ExcludedAttributes) > 0 {
+		return nil, errors.InvalidValue("only one of attributes and excludedAttributes may be used")
+	}
+
+	doc := make(map[string]interface{})
+	if len(projection.Attributes) > 0 {
+		t.forEachAlways(t.ResourceType.SuperAttribute(true), nil, func(path []*spec.Attribute) {
+			doc[t.field(path)] = 1
+		})
+		for _, each := range projection.Attributes {
+			path, err := t.resolve(each)
+			if err != nil {
+				return nil, err
+			}
+			field := t.field(path)
+			if covered(doc, field) {
+				continue
+			}
+			for k := range doc {
+				if strings.HasPrefix(k, field+".") {
+					delete(doc, k)
+				}
+			}
+			doc[field] = 1
+		}
+	} else {
+		for _, each := range projection.ExcludedAttributes {
+			path, err := t.resolve(each)
+			if err != nil {
+				return nil, err
+			}
+			if path[len(path)-1].Returned() == spec.ReturnedAlways {
+				continue
+			}
+			doc[t.field(path)] = 0
+		}
+	}
+	return doc, nil
+}
+
+func (t *Translator) filter(filter *expr.Expression, container *spec.Attribute, root bool) (map[string]interface{}, error) {
+	switch strings.ToLower(filter.Token()) {
+	case expr.And, expr.Or:
+		left, err := t.filter(filter.Left(), container, root)
+		if err != nil {
+			return nil, err
+		}
+		right, err := t.filter(filter.Right(), container, root)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"$" + strings.ToLower(filter.Token()): []interface{}{left, right},
+		}, nil
+	case expr.Not:
+		left, err := t.filter(filter.Left(), container, root)
+		if err != nil {
+			return nil, err
+		}
+		return negate(left), nil
+	}
+
+	// value path filter, i.e. emails[type eq "work"], asserts the presence of a matching element.
+	if filter.IsPath() {
+		return t.path(t.relative(filter, root), container, func(field string, attr *spec.Attribute) (map[string]interface{}, error) {
+			return nil, nil
+		})
+	}
+
+	return t.path(t.relative(filter.Left(), root), container, func(field string, attr *spec.Attribute) (map[string]interface{}, error) {
+		condition, err := t.compare(filter, attr)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{field: condition}, nil
+	})
+}
+
+func (t *Translator) relative(path *expr.Expression, root bool) *expr.Expression {
+	if root && path != nil && path.IsPath() && strings.ToLower(path.Token()) == strings.ToLower(t.ResourceType.Schema().ID()) {
+		return path.Next()
+	}
+	return path
+}
+
+// Follow the path down the sub attributes of the container, and invoke leaf with the field name of the attribute at
+// the end of the path. A filter on a multiValued attribute within the path is translated to $elemMatch, in which the
+// filter and the rest of the path are matched against the same element. A nil document returned by leaf asserts
+// nothing more than the presence of such element.
+func (t *Translator) path(step *expr.Expression, container *spec.Attribute,
+	leaf func(field string, attr *spec.Attribute) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	var (
+		attr = container
+		path = make([]*spec.Attribute, 0)
+	)
+	for ; step != nil; step = step.Next() {
+		if step.IsRootOfFilter() {
+			conditions := make([]interface{}, 0, 2)
+			c, err := t.filter(step, attr, false)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, c)
+
+			if step.Next() != nil {
+				c, err = t.path(step.Next(), attr, leaf)
+			} else {
+				c, err = leaf("", attr)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if c != nil {
+				conditions = append(conditions, c)
+			}
+
+			var elemMatch interface{} = conditions[0]
+			if len(conditions) > 1 {
+				elemMatch = map[string]interface{}{"$and": conditions}
+			}
+			return map[string]interface{}{
+				t.field(path): map[string]interface{}{"$elemMatch": elemMatch},
+			}, nil
+		}
+
+		attr = attr.SubAttributeForName(step.Token())
+		if attr == nil {
+			return nil, errors.InvalidFilter("'%s' is not a valid attribute", step.Token())
+		}
+		path = append(path, attr)
+	}
+	return leaf(t.field(path), attr)
+}
+
+// Translate the relational operator on the attribute to the condition of its field. Comparisons of strings which are
+// not case exact are performed with case insensitive regular expressions, except for the ordering operators, which
+// MongoDB does not support case insensitively.
+func (t *Translator) compare(filter *expr.Expression, attr *spec.Attribute) (map[string]interface{}, error) {
+	op := strings.ToLower(filter.Token())
+	if op == expr.Pr {
+		if attr.MultiValued() {
+			return map[string]interface{}{"$exists": true, "$nin": []interface{}{nil, []interface{}{}}}, nil
+		}
+		return map[string]interface{}{"$exists": true, "$ne": nil}, nil
+	}
+
+	value, err := crud.Normalize(attr, filter.Right().Token())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		str, isString   = value.(string)
+		caseInsensitive = isString && attr.Type() == spec.TypeString && !attr.CaseExact()
+	)
+	switch op {
+	case expr.Eq:
+		if caseInsensitive {
+			return regex("^"+regexp.QuoteMeta(str)+"$", true), nil
+		}
+		return map[string]interface{}{"$eq": value}, nil
+	case expr.Ne:
+		if caseInsensitive {
+			return map[string]interface{}{"$not": regex("^"+regexp.QuoteMeta(str)+"$", true)}, nil
+		}
+		return map[string]interface{}{"$ne": value}, nil
+	case expr.Sw:
+		return regex("^"+regexp.QuoteMeta(str), caseInsensitive), nil
+	case expr.Ew:
+		return regex(regexp.QuoteMeta(str)+"$", caseInsensitive), nil
+	case expr.Co:
+		return regex(regexp.QuoteMeta(str), caseInsensitive), nil
+	case expr.Gt:
+		return map[string]interface{}{"$gt": value}, nil
+	case expr.Ge:
+		return map[string]interface{}{"$gte": value}, nil
+	case expr.Lt:
+		return map[string]interface{}{"$lt": value}, nil
+	case expr.Le:
+		return map[string]interface{}{"$lte": value}, nil
+	default:
+		return nil, errors.InvalidFilter("unsupported operator '%s'", filter.Token())
+	}
+}
+
+// Resolve the attribute path against the resource type, and return the attributes on the path.
+func (t *Translator) resolve(path string) ([]*spec.Attribute, error) {
+	head, err := expr.CompilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	head = t.relative(head, true)
+	if head == nil || head.ContainsFilter() {
+		return nil, errors.InvalidPath("'%s' is not a valid attribute path", path)
+	}
+
+	var (
+		attr  = t.ResourceType.SuperAttribute(true)
+		attrs = make([]*spec.Attribute, 0)
+	)
+	for step := head; step != nil; step = step.Next() {
+		attr = attr.SubAttributeForName(step.Token())
+		if attr == nil {
+			return nil, errors.InvalidPath("'%s' is not a valid attribute path", path)
+		}
+		attrs = append(attrs, attr)
+	}
+	return attrs, nil
+}
+
+// Invoke callback with the path of every attribute whose returned-ability is always. Sub attributes are not visited
+// when the containing attribute is always returned.
+func (t *Translator) forEachAlways(container *spec.Attribute, prefix []*spec.Attribute, callback func(path []*spec.Attribute)) {
+	container.ForEachSubAttribute(func(subAttribute *spec.Attribute) {
+		path := append(append(make([]*spec.Attribute, 0, len(prefix)+1), prefix...), subAttribute)
+		if subAttribute.Returned() == spec.ReturnedAlways {
+			callback(path)
+		} else if subAttribute.Type() == spec.TypeComplex {
+			t.forEachAlways(subAttribute, path, callback)
+		}
+	})
+}
+
+func (t *Translator) field(path []*spec.Attribute) string {
+	if t.FieldName != nil {
+		return t.FieldName(path)
+	}
+	names := make([]string, 0, len(path))
+	for _, attr := range path {
+		if attr.HasAnnotation(annotations.SchemaExtensionRoot) {
+			names = append(names, strings.Replace(attr.Name(), ".", "_", -1))
+		} else {
+			names = append(names, attr.Name())
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+// Negate the query document. A document with a single field condition is negated with $not on the field, since
+// MongoDB has no top level $not; any other document is negated with $nor.
+func negate(doc map[string]interface{}) map[string]interface{} {
+	if len(doc) == 1 {
+		for field, condition := range doc {
+			if strings.HasPrefix(field, "$") {
+				break
+			}
+			if c, ok := condition.(map[string]interface{}); ok {
+				return map[string]interface{}{field: map[string]interface{}{"$not": c}}
+			}
+		}
+	}
+	return map[string]interface{}{"$nor": []interface{}{doc}}
+}
+
+func regex(pattern string, caseInsensitive bool) map[string]interface{} {
+	if caseInsensitive {
+		return map[string]interface{}{"$regex": pattern, "$options": "i"}
+	}
+	return map[string]interface{}{"$regex": pattern}
+}
+
+// Returns true if the field, or any of its containing fields, is already included in the projection document.
+func covered(doc map[string]interface{}, field string) bool {
+	for {
+		if _, ok := doc[field]; ok {
+			return true
+		}
+		i := strings.LastIndexByte(field, '.')
+		if i < 0 {
+			return false
+		}
+		field = field[:i]
+	}
+}
@@ -0,0 +1,381 @@
+package mongo
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTranslator(t *testing.T) {
+	s := new(TranslatorTestSuite)
+	s.resourceBase = "../../../tests/mongo_translator_test_suite"
+	suite.Run(t, s)
+}
+
+type TranslatorTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *TranslatorTestSuite) TestFilter() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_test_extension_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	translator := &Translator{ResourceType: resourceType}
+
+	tests := []struct {
+		name   string
+		filter string
+		expect string
+	}{
+		{
+			name:   "eq on string",
+			filter: `userName eq "Imu.lab"`,
+			expect: `{"userName": {"$regex": "^Imu\\.lab$", "$options": "i"}}`,
+		},
+		{
+			name:   "eq on case exact string",
+			filter: `id eq "A5866759"`,
+			expect: `{"id": {"$eq": "A5866759"}}`,
+		},
+		{
+			name:   "eq on boolean",
+			filter: `active eq true`,
+			expect: `{"active": {"$eq": true}}`,
+		},
+		{
+			name:   "ne on string",
+			filter: `displayName ne "Weinan"`,
+			expect: `{"displayName": {"$not": {"$regex": "^Weinan$", "$options": "i"}}}`,
+		},
+		{
+			name:   "ne on integer",
+			filter: `urn:imulab:scim:schemas:extension:test:2.0:User:loginCount ne 3`,
+			expect: `{"urn:imulab:scim:schemas:extension:test:2_0:User.loginCount": {"$ne": 3}}`,
+		},
+		{
+			name:   "sw",
+			filter: `userName sw "im"`,
+			expect: `{"userName": {"$regex": "^im", "$options": "i"}}`,
+		},
+		{
+			name:   "ew on case exact string",
+			filter: `urn:imulab:scim:schemas:extension:test:2.0:User:badge ew "(B)"`,
+			expect: `{"urn:imulab:scim:schemas:extension:test:2_0:User.badge": {"$regex": "\\(B\\)$"}}`,
+		},
+		{
+			name:   "co",
+			filter: `title co "50%_off"`,
+			expect: `{"title": {"$regex": "50%_off", "$options": "i"}}`,
+		},
+		{
+			name:   "gt on integer",
+			filter: `urn:imulab:scim:schemas:extension:test:2.0:User:loginCount gt 10`,
+			expect: `{"urn:imulab:scim:schemas:extension:test:2_0:User.loginCount": {"$gt": 10}}`,
+		},
+		{
+			name:   "ge on decimal",
+			filter: `urn:imulab:scim:schemas:extension:test:2.0:User:score ge 1.5`,
+			expect: `{"urn:imulab:scim:schemas:extension:test:2_0:User.score": {"$gte": 1.5}}`,
+		},
+		{
+			name:   "lt on dateTime",
+			filter: `meta.lastModified lt "2019-11-20T13:09:00Z"`,
+			expect: `{"meta.lastModified": {"$lt": "2019-11-20T13:09:00Z"}}`,
+		},
+		{
+			name:   "pr on simple attribute",
+			filter: `title pr`,
+			expect: `{"title": {"$exists": true, "$ne": null}}`,
+		},
+		{
+			name:   "pr on multiValued attribute",
+			filter: `emails pr`,
+			expect: `{"emails": {"$exists": true, "$nin": [null, []]}}`,
+		},
+		{
+			name:   "sub attribute of multiValued attribute",
+			filter: `emails.value co "@foo.com"`,
+			expect: `{"emails.value": {"$regex": "@foo\\.com", "$options": "i"}}`,
+		},
+		{
+			name:   "main schema namespace",
+			filter: `urn:ietf:params:scim:schemas:core:2.0:User:name.familyName eq "Qiu"`,
+			expect: `{"name.familyName": {"$regex": "^Qiu$", "$options": "i"}}`,
+		},
+		{
+			name:   "and",
+			filter: `userName eq "imulab" and active eq true`,
+			expect: `{"$and": [{"userName": {"$regex": "^imulab$", "$options": "i"}}, {"active": {"$eq": true}}]}`,
+		},
+		{
+			name:   "or within parenthesis",
+			filter: `(userName sw "a" or userName sw "b") and emails.primary eq true`,
+			expect: `
+{
+	"$and": [
+		{"$or": [{"userName": {"$regex": "^a", "$options": "i"}}, {"userName": {"$regex": "^b", "$options": "i"}}]},
+		{"emails.primary": {"$eq": true}}
+	]
+}`,
+		},
+		{
+			name:   "not on single condition",
+			filter: `not (userName eq "imulab")`,
+			expect: `{"userName": {"$not": {"$regex": "^imulab$", "$options": "i"}}}`,
+		},
+		{
+			name:   "not on logical operator",
+			filter: `not (userName eq "imulab" or active eq true)`,
+			expect: `{"$nor": [{"$or": [{"userName": {"$regex": "^imulab$", "$options": "i"}}, {"active": {"$eq": true}}]}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			query, err := translator.Filter(test.filter)
+			require.Nil(t, err)
+			raw, err := json.Marshal(query)
+			require.Nil(t, err)
+			assert.JSONEq(t, test.expect, string(raw))
+		})
+	}
+}
+
+func (s *TranslatorTestSuite) TestValuePath() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_test_extension_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	translator := &Translator{ResourceType: resourceType}
+
+	tests := []struct {
+		name   string
+		filter func(t *testing.T) *expr.Expression
+		expect string
+	}{
+		{
+			name: "value path filter",
+			filter: func(t *testing.T) *expr.Expression {
+				path, err := expr.CompilePath(`emails[type eq "work"]`)
+				require.Nil(t, err)
+				return path
+			},
+			expect: `{"emails": {"$elemMatch": {"type": {"$regex": "^work$", "$options": "i"}}}}`,
+		},
+		{
+			name: "value path filter with logical operator",
+			filter: func(t *testing.T) *expr.Expression {
+				path, err := expr.CompilePath(`emails[type eq "work" and primary eq true]`)
+				require.Nil(t, err)
+				return path
+			},
+			expect: `
+{
+	"emails": {
+		"$elemMatch": {
+			"$and": [{"type": {"$regex": "^work$", "$options": "i"}}, {"primary": {"$eq": true}}]
+		}
+	}
+}`,
+		},
+		{
+			name: "value path within filter",
+			filter: func(t *testing.T) *expr.Expression {
+				filter, err := expr.CompileFilter(`emails[type eq "work" and value co "@foo.com"] and userName sw "im"`)
+				require.Nil(t, err)
+				return filter
+			},
+			expect: `
+{
+	"$and": [
+		{
+			"emails": {
+				"$elemMatch": {
+					"$and": [{"type": {"$regex": "^work$", "$options": "i"}}, {"value": {"$regex": "@foo\\.com", "$options": "i"}}]
+				}
+			}
+		},
+		{"userName": {"$regex": "^im", "$options": "i"}}
+	]
+}`,
+		},
+		{
+			name: "sub attribute of value path within filter",
+			filter: func(t *testing.T) *expr.Expression {
+				filter, err := expr.CompileFilter(`emails[type eq "work"].value co "@foo.com"`)
+				require.Nil(t, err)
+				return filter
+			},
+			expect: `
+{
+	"emails": {
+		"$elemMatch": {
+			"$and": [{"type": {"$regex": "^work$", "$options": "i"}}, {"value": {"$regex": "@foo\\.com", "$options": "i"}}]
+		}
+	}
+}`,
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			query, err := translator.Query(test.filter(t))
+			require.Nil(t, err)
+			raw, err := json.Marshal(query)
+			require.Nil(t, err)
+			assert.JSONEq(t, test.expect, string(raw))
+		})
+	}
+}
+
+func (s *TranslatorTestSuite) TestProjection() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_test_extension_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	translator := &Translator{ResourceType: resourceType}
+
+	tests := []struct {
+		name       string
+		projection *crud.Projection
+		expect     func(t *testing.T, doc map[string]interface{}, err error)
+	}{
+		{
+			name:       "no projection",
+			projection: &crud.Projection{},
+			expect: func(t *testing.T, doc map[string]interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Nil(t, doc)
+			},
+		},
+		{
+			name:       "attributes",
+			projection: &crud.Projection{Attributes: []string{"userName", "name.givenName", "urn:imulab:scim:schemas:extension:test:2.0:User:badge"}},
+			expect: func(t *testing.T, doc map[string]interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]interface{}{
+					"schemas":        1,
+					"id":             1,
+					"userName":       1,
+					"name.givenName": 1,
+					"urn:imulab:scim:schemas:extension:test:2_0:User.badge": 1,
+				}, doc)
+			},
+		},
+		{
+			name:       "attributes already returned",
+			projection: &crud.Projection{Attributes: []string{"id", "urn:ietf:params:scim:schemas:core:2.0:User:emails"}},
+			expect: func(t *testing.T, doc map[string]interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]interface{}{
+					"schemas": 1,
+					"id":      1,
+					"emails":  1,
+				}, doc)
+			},
+		},
+		{
+			name:       "attribute after its sub attribute",
+			projection: &crud.Projection{Attributes: []string{"name.givenName", "emails.value", "name"}},
+			expect: func(t *testing.T, doc map[string]interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]interface{}{
+					"schemas":      1,
+					"id":           1,
+					"name":         1,
+					"emails.value": 1,
+				}, doc)
+			},
+		},
+		{
+			name:       "excluded attributes",
+			projection: &crud.Projection{ExcludedAttributes: []string{"id", "emails.display", "phoneNumbers"}},
+			expect: func(t *testing.T, doc map[string]interface{}, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, map[string]interface{}{
+					"emails.display": 0,
+					"phoneNumbers":   0,
+				}, doc)
+			},
+		},
+		{
+			name:       "invalid attribute",
+			projection: &crud.Projection{Attributes: []string{"foo"}},
+			expect: func(t *testing.T, doc map[string]interface{}, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidPath, err.(*errors.Error).Type)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			doc, err := translator.Projection(test.projection)
+			test.expect(t, doc, err)
+		})
+	}
+}
+
+func (s *TranslatorTestSuite) TestInvalid() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_test_extension_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	translator := &Translator{ResourceType: resourceType}
+
+	for _, filter := range []string{
+		`active gt true`,
+		`foo eq "bar"`,
+		`name eq "foo"`,
+	} {
+		s.T().Run(filter, func(t *testing.T) {
+			_, err := translator.Filter(filter)
+			require.NotNil(t, err)
+			assert.Equal(t, errors.TypeInvalidFilter, err.(*errors.Error).Type)
+		})
+	}
+}
+
+func (s *TranslatorTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *TranslatorTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
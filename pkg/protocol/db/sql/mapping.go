@@ -0,0 +1,168 @@
+package sql
+
+import (
+	"fmt"
+	"github.com/imulab/go-scim/pkg/core/annotations"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"strings"
+)
+
+// Strategy to map attributes of a resource to the SQL expressions which refer to their values. The scope is the alias
+// of the element of a multiValued attribute when translating within an EXISTS subquery, or empty when referring to the
+// resource itself. The path is the list of attributes leading from the scope to the attribute.
+type Mapping interface {
+	// Returns the SQL expression which refers to the value of the singular simple attribute at the path, relative to
+	// the scope. An empty path refers to the element of a multiValued simple attribute itself.
+	Column(scope string, path []*spec.Attribute) (string, error)
+	// Returns the SQL table expression, aliased with alias, which yields the elements of the multiValued attribute at
+	// the path, relative to the scope, and an optional condition that correlates the elements to the scope.
+	Elements(scope string, path []*spec.Attribute, alias string) (from string, on string, err error)
+}
+
+// Create a Mapping for the flat column layout, where every singular simple attribute of the resource is stored in its
+// own column of the table, and the elements of every multiValued attribute are stored in their own table, with a
+// foreign key column referring to the id column of the resource table.
+//
+// By default, the column of an attribute is named by joining the names of the attributes on its path with underscores
+// (i.e. name_givenName), and the element table of a multiValued attribute is named by the resource table and the name
+// of the attribute joined by underscore (i.e. users_emails). Schema extension namespaces are left out of the names, so
+// the attributes of extensions must not share names with other top level attributes; otherwise, ColumnName and
+// ElementTableName shall be customized. The column of the element of a multiValued simple attribute is named "value".
+func Flat(table string) *FlatMapping {
+	return &FlatMapping{
+		Table:      table,
+		IDColumn:   "id",
+		ForeignKey: "resource_id",
+		ColumnName: func(path []*spec.Attribute) string {
+			return joinNames(path, "_")
+		},
+		ElementTableName: func(path []*spec.Attribute) string {
+			return table + "_" + joinNames(path, "_")
+		},
+	}
+}
+
+// Mapping for the flat column layout. See Flat.
+type FlatMapping struct {
+	// Name of the resource table.
+	Table string
+	// Name of the id column of the resource table.
+	IDColumn string
+	// Name of the column in the element tables which refers to the id of the resource.
+	ForeignKey string
+	// Returns the column name of the attribute at the path, relative to the resource or the element.
+	ColumnName func(path []*spec.Attribute) string
+	// Returns the table name of the elements of the multiValued attribute at the path.
+	ElementTableName func(path []*spec.Attribute) string
+}
+
+func (m *FlatMapping) Column(scope string, path []*spec.Attribute) (string, error) {
+	if len(path) == 0 {
+		if len(scope) == 0 {
+			return "", errors.Internal("resource itself cannot be mapped to a column")
+		}
+		return scope + "." + quoteIdentifier("value"), nil
+	}
+	if len(scope) == 0 {
+		return quoteIdentifier(m.ColumnName(path)), nil
+	}
+	return scope + "." + quoteIdentifier(m.ColumnName(path)), nil
+}
+
+func (m *FlatMapping) Elements(scope string, path []*spec.Attribute, alias string) (string, string, error) {
+	if len(scope) > 0 {
+		return "", "", errors.NotImplemented("multiValued attributes nested in multiValued attributes are not supported")
+	}
+	from := fmt.Sprintf("%s AS %s", quoteIdentifier(m.ElementTableName(path)), alias)
+	on := fmt.Sprintf("%s.%s = %s.%s", alias, quoteIdentifier(m.ForeignKey), quoteIdentifier(m.Table), quoteIdentifier(m.IDColumn))
+	return from, on, nil
+}
+
+// Create a Mapping for the JSON column layout in PostgreSQL, where the resource is stored in its SCIM JSON form in a
+// jsonb column. Attributes are extracted with the -> and ->> operators, and cast to the SQL types corresponding to
+// their types, so that they compare correctly. Elements of multiValued attributes are expanded with
+// jsonb_array_elements.
+func JSON(column string) *JSONMapping {
+	return &JSONMapping{Document: column}
+}
+
+// Mapping for the JSON column layout. See JSON.
+type JSONMapping struct {
+	// Name of the jsonb column which stores the resource.
+	Document string
+}
+
+func (m *JSONMapping) Column(scope string, path []*spec.Attribute) (string, error) {
+	if len(path) == 0 {
+		if len(scope) == 0 {
+			return "", errors.Internal("resource itself cannot be mapped to a column")
+		}
+		return fmt.Sprintf("(%s.value #>> '{}')", scope), nil
+	}
+
+	sb := strings.Builder{}
+	sb.WriteString(m.base(scope))
+	for i, attr := range path {
+		if i == len(path)-1 {
+			sb.WriteString("->>")
+		} else {
+			sb.WriteString("->")
+		}
+		sb.WriteString(quoteString(attr.Name()))
+	}
+
+	switch path[len(path)-1].Type() {
+	case spec.TypeInteger:
+		return "(" + sb.String() + ")::bigint", nil
+	case spec.TypeDecimal:
+		return "(" + sb.String() + ")::numeric", nil
+	case spec.TypeBoolean:
+		return "(" + sb.String() + ")::boolean", nil
+	case spec.TypeDateTime:
+		return "(" + sb.String() + ")::timestamptz", nil
+	default:
+		return "(" + sb.String() + ")", nil
+	}
+}
+
+func (m *JSONMapping) Elements(scope string, path []*spec.Attribute, alias string) (string, string, error) {
+	sb := strings.Builder{}
+	sb.WriteString(m.base(scope))
+	for _, attr := range path {
+		sb.WriteString("->")
+		sb.WriteString(quoteString(attr.Name()))
+	}
+	return fmt.Sprintf("jsonb_array_elements(%s) AS %s(value)", sb.String(), alias), "", nil
+}
+
+func (m *JSONMapping) base(scope string) string {
+	if len(scope) == 0 {
+		return quoteIdentifier(m.Document)
+	}
+	return scope + ".value"
+}
+
+func joinNames(path []*spec.Attribute, sep string) string {
+	names := make([]string, 0, len(path))
+	for _, attr := range path {
+		if attr.HasAnnotation(annotations.SchemaExtensionRoot) {
+			continue
+		}
+		names = append(names, attr.Name())
+	}
+	return strings.Join(names, sep)
+}
+
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.Replace(identifier, `"`, `""`, -1) + `"`
+}
+
+func quoteString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+var (
+	_ Mapping = (*FlatMapping)(nil)
+	_ Mapping = (*JSONMapping)(nil)
+)
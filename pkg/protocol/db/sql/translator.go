@@ -0,0 +1,379 @@
+// Package sql translates SCIM filters, sort and pagination to parameterized SQL clauses, for databases which persist
+// resources in relational databases such as PostgreSQL or SQLite.
+package sql
+
+import (
+	"fmt"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"strconv"
+	"strings"
+)
+
+// Placeholder of the n-th (1-based) argument in a parameterized SQL statement.
+type Placeholder func(n int) string
+
+var (
+	// Placeholder in the form of "?", as used by SQLite and MySQL.
+	Question Placeholder = func(n int) string {
+		return "?"
+	}
+	// Placeholder in the form of "$n", as used by PostgreSQL.
+	Dollar Placeholder = func(n int) string {
+		return "$" + strconv.Itoa(n)
+	}
+)
+
+// Translator of SCIM queries on resources of a resource type to SQL clauses.
+type Translator struct {
+	ResourceType *spec.ResourceType
+	Mapping      Mapping
+	// Placeholder of the arguments. Defaults to Question.
+	Placeholder Placeholder
+}
+
+// Translated SQL clauses, along with the arguments to their placeholders.
+type Clause struct {
+	// Condition of the WHERE clause, without the WHERE keyword, so that it can be combined with other conditions.
+	Where string
+	// The ORDER BY clause, or empty if there is no sort.
+	OrderBy string
+	// The LIMIT and OFFSET clause, or empty if there is no pagination.
+	Limit string
+	// Arguments to the placeholders in the Where condition, in order.
+	Args []interface{}
+}
+
+// Returns the clauses joined as they appear in a SELECT statement.
+func (c *Clause) String() string {
+	parts := []string{"WHERE " + c.Where}
+	if len(c.OrderBy) > 0 {
+		parts = append(parts, c.OrderBy)
+	}
+	if len(c.Limit) > 0 {
+		parts = append(parts, c.Limit)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Translate the SCIM filter, sort and pagination to SQL clauses. The filter is validated against the resource type
+// before translation. Sort and pagination are optional.
+func (t *Translator) Translate(filter string, sort *crud.Sort, pagination *crud.Pagination) (*Clause, error) {
+	root, err := expr.CompileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args, err := t.Where(root)
+	if err != nil {
+		return nil, err
+	}
+
+	orderBy, err := t.OrderBy(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clause{
+		Where:   where,
+		OrderBy: orderBy,
+		Limit:   t.Limit(pagination),
+		Args:    args,
+	}, nil
+}
+
+// Translate the compiled SCIM filter to the condition of a WHERE clause, and return the arguments to its placeholders.
+func (t *Translator) Where(filter *expr.Expression) (string, []interface{}, error) {
+	if err := crud.Validate(filter, t.ResourceType); err != nil {
+		return "", nil, err
+	}
+
+	tr := &translation{Translator: t, args: make([]interface{}, 0)}
+	where, err := tr.filter(filter, "", t.ResourceType.SuperAttribute(true))
+	if err != nil {
+		return "", nil, err
+	}
+	return where, tr.args, nil
+}
+
+// Translate the sort to an ORDER BY clause, or return empty string if there is no sort. Sorting by sub attributes of
+// multiValued attributes is not supported.
+func (t *Translator) OrderBy(sort *crud.Sort) (string, error) {
+	if sort == nil || len(sort.By) == 0 {
+		return "", nil
+	}
+
+	head, err := expr.CompilePath(sort.By)
+	if err != nil {
+		return "", err
+	}
+	head = t.skipNamespace(head)
+
+	var (
+		attr = t.ResourceType.SuperAttribute(true)
+		path = make([]*spec.Attribute, 0)
+	)
+	for step := head; step != nil; step = step.Next() {
+		if step.IsRootOfFilter() || attr.Type() != spec.TypeComplex {
+			return "", errors.InvalidValue("'%s' is not a valid sort attribute", sort.By)
+		}
+		attr = attr.SubAttributeForName(step.Token())
+		if attr == nil {
+			return "", errors.InvalidValue("'%s' is not a valid sort attribute", sort.By)
+		}
+		if attr.MultiValued() {
+			return "", errors.NotImplemented("sorting by multiValued attribute '%s' is not supported", attr.Path())
+		}
+		path = append(path, attr)
+	}
+	if attr.Type() == spec.TypeComplex {
+		return "", errors.InvalidValue("cannot sort by complex attribute '%s'", attr.Path())
+	}
+
+	column, err := t.Mapping.Column("", path)
+	if err != nil {
+		return "", err
+	}
+	if sort.Order == crud.SortDesc {
+		return "ORDER BY " + column + " DESC", nil
+	}
+	return "ORDER BY " + column + " ASC", nil
+}
+
+// Translate the pagination to a LIMIT and OFFSET clause, or return empty string if there is no pagination.
+func (t *Translator) Limit(pagination *crud.Pagination) string {
+	if pagination == nil {
+		return ""
+	}
+	offset := pagination.StartIndex - 1
+	if offset < 0 {
+		offset = 0
+	}
+	return fmt.Sprintf("LIMIT %d OFFSET %d", pagination.Count, offset)
+}
+
+func (t *Translator) skipNamespace(head *expr.Expression) *expr.Expression {
+	if head != nil && head.IsPath() && strings.ToLower(head.Token()) == strings.ToLower(t.ResourceType.Schema().ID()) {
+		return head.Next()
+	}
+	return head
+}
+
+// State of a single translation, which numbers the arguments and the aliases of EXISTS subqueries.
+type translation struct {
+	*Translator
+	args    []interface{}
+	aliases int
+}
+
+// Translate the filter, whose paths are relative to the container attribute, within the scope.
+func (tr *translation) filter(filter *expr.Expression, scope string, container *spec.Attribute) (string, error) {
+	switch strings.ToLower(filter.Token()) {
+	case expr.And, expr.Or:
+		left, err := tr.filter(filter.Left(), scope, container)
+		if err != nil {
+			return "", err
+		}
+		right, err := tr.filter(filter.Right(), scope, container)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(filter.Token()), right), nil
+	case expr.Not:
+		left, err := tr.filter(filter.Left(), scope, container)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("NOT (%s)", left), nil
+	}
+
+	// A value path filter (i.e. emails[type eq "work"]) asserts the presence of a matching element.
+	if filter.IsPath() {
+		return tr.path(tr.relative(filter, scope), scope, container, tr.present)
+	}
+
+	return tr.path(tr.relative(filter.Left(), scope), scope, container, func(scope string, path []*spec.Attribute, attr *spec.Attribute) (string, error) {
+		return tr.compare(filter, scope, path, attr)
+	})
+}
+
+func (tr *translation) relative(path *expr.Expression, scope string) *expr.Expression {
+	if len(scope) == 0 {
+		return tr.skipNamespace(path)
+	}
+	return path
+}
+
+// Follow the path down the sub attributes of the container, and invoke leaf on the attribute at the end of the path.
+// Each multiValued attribute on the path opens an EXISTS subquery on its elements, in which the rest of the path, and
+// the value filter on the attribute, if any, are translated.
+func (tr *translation) path(step *expr.Expression, scope string, container *spec.Attribute,
+	leaf func(scope string, path []*spec.Attribute, attr *spec.Attribute) (string, error)) (string, error) {
+	var (
+		attr = container
+		path = make([]*spec.Attribute, 0)
+	)
+	for ; step != nil; step = step.Next() {
+		if step.IsRootOfFilter() {
+			return "", errors.InvalidFilter("filter cannot be applied to singular attribute '%s'", attr.Path())
+		}
+
+		sub := attr.SubAttributeForName(step.Token())
+		if sub == nil {
+			return "", errors.InvalidFilter("'%s' is not a valid attribute", step.Token())
+		}
+		path = append(path, sub)
+
+		if sub.MultiValued() {
+			return tr.exists(step.Next(), scope, path, sub, leaf)
+		}
+		attr = sub
+	}
+	return leaf(scope, path, attr)
+}
+
+func (tr *translation) exists(next *expr.Expression, scope string, path []*spec.Attribute, attr *spec.Attribute,
+	leaf func(scope string, path []*spec.Attribute, attr *spec.Attribute) (string, error)) (string, error) {
+	tr.aliases++
+	alias := "e" + strconv.Itoa(tr.aliases)
+
+	from, on, err := tr.Mapping.Elements(scope, path, alias)
+	if err != nil {
+		return "", err
+	}
+
+	conditions := make([]string, 0)
+	if len(on) > 0 {
+		conditions = append(conditions, on)
+	}
+	if next != nil && next.IsRootOfFilter() {
+		c, err := tr.filter(next, alias, attr)
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, c)
+		next = next.Next()
+	}
+	c, err := tr.path(next, alias, attr, leaf)
+	if err != nil {
+		return "", err
+	}
+	if len(c) > 0 {
+		conditions = append(conditions, c)
+	}
+
+	if len(conditions) == 0 {
+		return fmt.Sprintf("EXISTS (SELECT 1 FROM %s)", from), nil
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s)", from, strings.Join(conditions, " AND ")), nil
+}
+
+// Translate the presence of the attribute at the path. A multiValued attribute, at the end of the path, is present
+// when the EXISTS subquery yields any element, hence no additional condition; a singular complex attribute is present
+// when any of its sub attributes is present.
+func (tr *translation) present(scope string, path []*spec.Attribute, attr *spec.Attribute) (string, error) {
+	if attr.MultiValued() && len(path) == 0 {
+		if attr.Type() == spec.TypeComplex {
+			return "", nil
+		}
+		column, err := tr.Mapping.Column(scope, path)
+		if err != nil {
+			return "", err
+		}
+		return column + " IS NOT NULL", nil
+	}
+
+	if attr.Type() == spec.TypeComplex {
+		conditions := make([]string, 0, attr.CountSubAttributes())
+		var err error
+		attr.ForEachSubAttribute(func(subAttribute *spec.Attribute) {
+			if err != nil {
+				return
+			}
+			var c string
+			subPath := append(append(make([]*spec.Attribute, 0, len(path)+1), path...), subAttribute)
+			if subAttribute.MultiValued() {
+				c, err = tr.exists(nil, scope, subPath, subAttribute, tr.present)
+			} else {
+				c, err = tr.present(scope, subPath, subAttribute)
+			}
+			if len(c) > 0 {
+				conditions = append(conditions, c)
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+		return "(" + strings.Join(conditions, " OR ") + ")", nil
+	}
+
+	column, err := tr.Mapping.Column(scope, path)
+	if err != nil {
+		return "", err
+	}
+	return column + " IS NOT NULL", nil
+}
+
+// Translate the relational operator on the attribute at the path. String comparisons on attributes which are not
+// case exact are performed on lower cased values.
+func (tr *translation) compare(filter *expr.Expression, scope string, path []*spec.Attribute, attr *spec.Attribute) (string, error) {
+	op := strings.ToLower(filter.Token())
+	if op == expr.Pr {
+		return tr.present(scope, path, attr)
+	}
+
+	raw, err := tr.Mapping.Column(scope, path)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := crud.Normalize(attr, filter.Right().Token())
+	if err != nil {
+		return "", err
+	}
+	column := raw
+	if str, ok := value.(string); ok && attr.Type() == spec.TypeString && !attr.CaseExact() {
+		column = "LOWER(" + raw + ")"
+		value = strings.ToLower(str)
+	}
+
+	switch op {
+	case expr.Eq:
+		return column + " = " + tr.arg(value), nil
+	case expr.Ne:
+		return fmt.Sprintf("(%s IS NULL OR %s <> %s)", raw, column, tr.arg(value)), nil
+	case expr.Sw:
+		return column + " LIKE " + tr.arg(escapeLike(value.(string))+"%") + ` ESCAPE '\'`, nil
+	case expr.Ew:
+		return column + " LIKE " + tr.arg("%"+escapeLike(value.(string))) + ` ESCAPE '\'`, nil
+	case expr.Co:
+		return column + " LIKE " + tr.arg("%"+escapeLike(value.(string))+"%") + ` ESCAPE '\'`, nil
+	case expr.Gt:
+		return column + " > " + tr.arg(value), nil
+	case expr.Ge:
+		return column + " >= " + tr.arg(value), nil
+	case expr.Lt:
+		return column + " < " + tr.arg(value), nil
+	case expr.Le:
+		return column + " <= " + tr.arg(value), nil
+	default:
+		return "", errors.InvalidFilter("unsupported operator '%s'", filter.Token())
+	}
+}
+
+// Append the argument and return its placeholder.
+func (tr *translation) arg(value interface{}) string {
+	tr.args = append(tr.args, value)
+	placeholder := tr.Placeholder
+	if placeholder == nil {
+		placeholder = Question
+	}
+	return placeholder(len(tr.args))
+}
+
+// Escape the wildcard characters of the LIKE operator, with backslash as the escape character.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,190 @@
+package sql
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestTranslator(t *testing.T) {
+	s := new(TranslatorTestSuite)
+	s.resourceBase = "../../../tests/sql_translator_test_suite"
+	suite.Run(t, s)
+}
+
+type TranslatorTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *TranslatorTestSuite) TestGolden() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_test_extension_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	cases := []struct {
+		name       string
+		filter     string
+		sort       *crud.Sort
+		pagination *crud.Pagination
+	}{
+		{name: "eq on string", filter: `userName eq "Imulab"`},
+		{name: "eq on case exact string", filter: `id eq "A5866759"`},
+		{name: "eq on boolean", filter: `active eq true`},
+		{name: "eq on binary", filter: `urn:imulab:scim:schemas:extension:test:2.0:User:avatar eq "aGVsbG8="`},
+		{name: "ne", filter: `displayName ne "Weinan"`},
+		{name: "sw", filter: `userName sw "im"`},
+		{name: "ew", filter: `userName ew "lab"`},
+		{name: "co with wildcards", filter: `title co "50%_off"`},
+		{name: "gt on integer", filter: `urn:imulab:scim:schemas:extension:test:2.0:User:loginCount gt 10`},
+		{name: "ge on decimal", filter: `urn:imulab:scim:schemas:extension:test:2.0:User:score ge 1.5`},
+		{name: "lt on dateTime", filter: `meta.lastModified lt "2019-11-20T13:09:00Z"`},
+		{name: "le on integer", filter: `urn:imulab:scim:schemas:extension:test:2.0:User:loginCount le 3`},
+		{name: "gt on case exact string", filter: `urn:imulab:scim:schemas:extension:test:2.0:User:badge gt "B"`},
+		{name: "pr on simple attribute", filter: `title pr`},
+		{name: "pr on complex attribute", filter: `name pr`},
+		{name: "pr on multiValued attribute", filter: `emails pr`},
+		{name: "sub attribute of multiValued attribute", filter: `emails.value co "@foo.com"`},
+		{name: "multiValued simple attribute", filter: `schemas eq "urn:ietf:params:scim:schemas:core:2.0:User"`},
+		{name: "multiValued simple attribute of extension", filter: `urn:imulab:scim:schemas:extension:test:2.0:User:tags eq "VIP"`},
+		{name: "main schema namespace", filter: `urn:ietf:params:scim:schemas:core:2.0:User:name.familyName eq "Qiu"`},
+		{name: "and", filter: `userName eq "imulab" and active eq true`},
+		{name: "or within parenthesis", filter: `(userName sw "a" or userName sw "b") and emails.primary eq true`},
+		{name: "not", filter: `not (userName eq "imulab")`},
+		{name: "value path", filter: `emails[type eq "work" and value co "@foo.com"]`},
+		{name: "sub attribute of value path", filter: `emails[type eq "work"].value co "@foo.com"`},
+		{name: "value paths with logical operators", filter: `emails[type eq "work"] and not (emails[primary eq true]) and userName sw "im"`},
+		{
+			name:       "sort and pagination",
+			filter:     `userName pr`,
+			sort:       &crud.Sort{By: "name.familyName", Order: crud.SortDesc},
+			pagination: &crud.Pagination{StartIndex: 11, Count: 10},
+		},
+		{
+			name:       "sort by extension attribute",
+			filter:     `userName pr`,
+			sort:       &crud.Sort{By: "urn:imulab:scim:schemas:extension:test:2.0:User:score"},
+			pagination: &crud.Pagination{StartIndex: 1, Count: 5},
+		},
+	}
+
+	for _, m := range []struct {
+		name       string
+		translator *Translator
+	}{
+		{name: "flat", translator: &Translator{ResourceType: resourceType, Mapping: Flat("users")}},
+		{name: "json", translator: &Translator{ResourceType: resourceType, Mapping: JSON("data"), Placeholder: Dollar}},
+	} {
+		s.T().Run(m.name, func(t *testing.T) {
+			sb := strings.Builder{}
+			for _, c := range cases {
+				clause, err := m.translator.Translate(c.filter, c.sort, c.pagination)
+				require.Nil(t, err, c.name)
+				args, err := json.Marshal(clause.Args)
+				require.Nil(t, err)
+				sb.WriteString(fmt.Sprintf("== %s\nfilter: %s\nsql: %s\nargs: %s\n\n", c.name, c.filter, clause.String(), args))
+			}
+
+			golden := s.resourceBase + "/" + m.name + ".golden"
+			if *update {
+				require.Nil(t, ioutil.WriteFile(golden, []byte(sb.String()), 0644))
+			}
+			expect, err := ioutil.ReadFile(golden)
+			require.Nil(t, err)
+			assert.Equal(t, string(expect), sb.String())
+		})
+	}
+}
+
+func (s *TranslatorTestSuite) TestInvalid() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_test_extension_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	translator := &Translator{ResourceType: resourceType, Mapping: Flat("users")}
+
+	tests := []struct {
+		name   string
+		filter string
+		sort   *crud.Sort
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name:   "invalid filter",
+			filter: `active gt true`,
+			expect: func(t *testing.T, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidFilter, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name:   "sort by multiValued attribute",
+			filter: `userName pr`,
+			sort:   &crud.Sort{By: "emails.value"},
+			expect: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "sort by nonexistent attribute",
+			filter: `userName pr`,
+			sort:   &crud.Sort{By: "foo"},
+			expect: func(t *testing.T, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidValue, err.(*errors.Error).Type)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			_, err := translator.Translate(test.filter, test.sort, nil)
+			test.expect(t, err)
+		})
+	}
+}
+
+func (s *TranslatorTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *TranslatorTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
@@ -2,6 +2,7 @@ package groupsync
 
 import (
 	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
 )
 
 const (
@@ -25,25 +26,17 @@ func Compare(before *prop.Resource, after *prop.Resource) *Diff {
 		afterIds  = map[string]struct{}{}
 	)
 	{
+		collect := func(resource *prop.Resource, ids map[string]struct{}) {
+			values, _ := crud.GetRaw(resource, fieldMembers+"."+fieldValue)
+			for _, v := range values {
+				ids[v.(string)] = struct{}{}
+			}
+		}
 		if before != nil {
-			members, _ := before.NewNavigator().FocusName(fieldMembers)
-			_ = members.(prop.Container).ForEachChild(func(_ int, child prop.Property) error {
-				value := child.(prop.Container).ChildAtIndex(fieldValue)
-				if value != nil && !value.IsUnassigned() {
-					beforeIds[value.Raw().(string)] = struct{}{}
-				}
-				return nil
-			})
+			collect(before, beforeIds)
 		}
 		if after != nil {
-			members, _ := after.NewNavigator().FocusName(fieldMembers)
-			_ = members.(prop.Container).ForEachChild(func(_ int, child prop.Property) error {
-				value := child.(prop.Container).ChildAtIndex(fieldValue)
-				if value != nil && !value.IsUnassigned() {
-					afterIds[value.Raw().(string)] = struct{}{}
-				}
-				return nil
-			})
+			collect(after, afterIds)
 		}
 	}
 
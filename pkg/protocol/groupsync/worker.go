@@ -7,10 +7,27 @@ import (
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/protocol/crud"
 	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"time"
 )
 
+// Create a worker which reads the GroupSync tasks saved by the Listener and syncs the group property of the affected
+// User resources. The lock is optional; when provided, it should be the same lock given to the services updating the
+// User resources, so that the worker does not overwrite their concurrent updates. Errors are sent to the channel
+// returned by Start.
+func Worker(userDB db.DB, groupDB db.DB, groupSyncDB db.DB, locks lock.Lock, logger log.Logger) *worker {
+	return &worker{
+		userDB:      userDB,
+		groupDB:     groupDB,
+		groupSyncDB: groupSyncDB,
+		log:         logger,
+		errChan:     make(chan error),
+		doneChan:    make(chan struct{}),
+		lock:        locks,
+	}
+}
+
 type worker struct {
 	userDB      db.DB
 	groupDB     db.DB
@@ -18,6 +35,9 @@ type worker struct {
 	log         log.Logger
 	errChan     chan error
 	doneChan    chan struct{}
+
+	// optional lock which serializes the update of a user with other updates to the same user
+	lock lock.Lock
 }
 
 func (w *worker) Start() chan error {
@@ -145,6 +165,13 @@ func (w *worker) syncDirect(sync *prop.Resource, diff prop.Container) error {
 		nav.Retract()
 	}
 
+	if w.lock != nil {
+		if err := w.lock.Lock(context.Background(), userID); err != nil {
+			return err
+		}
+		defer w.lock.Unlock(userID)
+	}
+
 	user, err := w.userDB.Get(context.Background(), userID, nil)
 	if err != nil {
 		return err
@@ -0,0 +1,106 @@
+package groupsync
+
+import (
+	"context"
+	"encoding/json"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGroupSyncWorker(t *testing.T) {
+	s := new(GroupSyncWorkerTestSuite)
+	s.resourceBase = "../../tests/group_sync_worker_test_suite"
+	suite.Run(t, s)
+}
+
+type GroupSyncWorkerTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *GroupSyncWorkerTestSuite) TestSyncDirectWithLock() {
+	_ = s.mustSchema("/user_schema.json")
+	userResourceType := s.mustResourceType("/user_resource_type.json")
+
+	const userID = "a5866759-32ca-4e2a-9808-a0fe74f94b18"
+
+	userDB := db.Memory()
+	require.Nil(s.T(), userDB.Insert(context.Background(), s.mustResource("/user_001.json", userResourceType)))
+
+	locks := lock.Default(10 * time.Millisecond)
+	w := Worker(userDB, db.Memory(), db.Memory(), locks, log.None())
+
+	sync := s.mustResource("/group_sync_001.json", ResourceType())
+	diff := sync.NewFluentNavigator().FocusName("diff").FocusIndex(0).CurrentAsContainer()
+
+	// the user is locked by a concurrent update, so it is not synced
+	require.Nil(s.T(), locks.Lock(context.Background(), userID))
+	assert.Equal(s.T(), context.DeadlineExceeded, w.syncDirect(sync, diff))
+	assert.Equal(s.T(), 1, s.countGroups(userDB, userID))
+
+	locks.Unlock(userID)
+	assert.Nil(s.T(), w.syncDirect(sync, diff))
+	assert.Equal(s.T(), 2, s.countGroups(userDB, userID))
+}
+
+func (s *GroupSyncWorkerTestSuite) countGroups(userDB db.DB, userID string) int {
+	user, err := userDB.Get(context.Background(), userID, nil)
+	s.Require().Nil(err)
+	groups := user.NewFluentNavigator().FocusName("groups").CurrentAsContainer()
+	return groups.CountChildren()
+}
+
+func (s *GroupSyncWorkerTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	resource := prop.NewResource(resourceType)
+	err = scimJSON.Deserialize(raw, resource)
+	s.Require().Nil(err)
+
+	return resource
+}
+
+func (s *GroupSyncWorkerTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *GroupSyncWorkerTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/imulab/go-scim/pkg/protocol/services"
+)
+
+type Bulk struct {
+	Log     log.Logger
+	Service *services.BulkService
+}
+
+func (h *Bulk) Handle(request http.Request, response http.Response) {
+	h.Log.Info("request to process bulk operations")
+
+	var payload *services.BulkRequest
+	{
+		// Read one byte over the limit, so that oversized payloads are rejected before they are parsed.
+		limit := h.Service.ServiceProviderConfig.Bulk.MaxPayload
+		if limit > 0 {
+			limit++
+		}
+		raw, err := http.ReadBody(request, limit)
+		if err != nil {
+			h.Log.Error("failed to read request body for bulk operations: %s", err.Error())
+			WriteError(response, errors.Internal("failed to read request body"))
+			return
+		}
+		if limit > 0 && len(raw) >= limit {
+			WriteError(response, errors.TooLarge("the size of the bulk operation exceeds the maxPayloadSize (%d)", limit-1))
+			return
+		}
+
+		payload = new(services.BulkRequest)
+		if err := json.Unmarshal(raw, payload); err != nil {
+			h.Log.Error("failed to parse request body for bulk operations: %s", err.Error())
+			WriteError(response, errors.InvalidSyntax("failed to parse bulk request: %s", err.Error()))
+			return
+		}
+		payload.PayloadSize = len(raw)
+	}
+
+	br, err := h.Service.ProcessBulk(request.Context(), payload)
+	if err != nil {
+		WriteError(response, err)
+		return
+	}
+
+	raw, err := h.serializeResponse(br)
+	if err != nil {
+		WriteError(response, err)
+		return
+	}
+
+	response.WriteSCIMContentType()
+	response.WriteStatus(200)
+	response.WriteBody(raw)
+}
+
+func (h *Bulk) serializeResponse(response *services.BulkResponse) ([]byte, error) {
+	type operation struct {
+		Method   string        `json:"method"`
+		BulkID   string        `json:"bulkId,omitempty"`
+		Version  string        `json:"version,omitempty"`
+		Location string        `json:"location,omitempty"`
+		Status   string        `json:"status"`
+		Response *errors.Error `json:"response,omitempty"`
+	}
+
+	wip := struct {
+		Schemas    []string    `json:"schemas"`
+		Operations []operation `json:"Operations"`
+	}{
+		Schemas:    []string{services.BulkResponseSchema},
+		Operations: make([]operation, 0, len(response.Operations)),
+	}
+	for _, op := range response.Operations {
+		each := operation{
+			Method:   op.Method,
+			BulkID:   op.BulkID,
+			Version:  op.Version,
+			Location: op.Location,
+			Status:   fmt.Sprintf("%d", op.Status),
+		}
+		if op.Error != nil {
+			if scimError, ok := op.Error.(*errors.Error); ok {
+				each.Response = scimError
+			} else {
+				each.Response = errors.Internal(op.Error.Error()).(*errors.Error)
+			}
+			each.Status = fmt.Sprintf("%d", each.Response.Status)
+		}
+		wip.Operations = append(wip.Operations, each)
+	}
+	return json.Marshal(wip)
+}
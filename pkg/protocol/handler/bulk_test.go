@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/imulab/go-scim/pkg/protocol/services"
+	filters "github.com/imulab/go-scim/pkg/protocol/services/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBulkHandler(t *testing.T) {
+	s := new(BulkHandlerTestSuite)
+	s.resourceBase = "../../tests/bulk_handler_test_suite"
+	suite.Run(t, s)
+}
+
+type BulkHandlerTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *BulkHandlerTestSuite) TestHandle() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	spc := s.mustServiceProviderConfig("/service_provider_config.json")
+
+	tests := []struct {
+		name   string
+		getReq func(t *testing.T) http.Request
+		expect func(t *testing.T, rr *httptest.ResponseRecorder)
+	}{
+		{
+			name: "process bulk request",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("POST", "/Bulk", strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [
+		{
+			"method": "POST",
+			"path": "/Users",
+			"bulkId": "qwerty",
+			"data": {
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+				"userName": "imulab",
+				"emails": [{"value": "imulab@foo.com", "primary": true}]
+			}
+		},
+		{
+			"method": "DELETE",
+			"path": "/Users/foo"
+		}
+	]
+}
+`)), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.Equal(t, "application/json+scim", rr.Result().Header.Get("Content-Type"))
+				body := new(struct {
+					Schemas    []string `json:"schemas"`
+					Operations []struct {
+						Method   string                 `json:"method"`
+						BulkID   string                 `json:"bulkId"`
+						Location string                 `json:"location"`
+						Version  string                 `json:"version"`
+						Status   string                 `json:"status"`
+						Response map[string]interface{} `json:"response"`
+					} `json:"Operations"`
+				})
+				assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+				assert.Equal(t, []string{"urn:ietf:params:scim:api:messages:2.0:BulkResponse"}, body.Schemas)
+				if assert.Len(t, body.Operations, 2) {
+					assert.Equal(t, "POST", body.Operations[0].Method)
+					assert.Equal(t, "qwerty", body.Operations[0].BulkID)
+					assert.Equal(t, "201", body.Operations[0].Status)
+					assert.NotEmpty(t, body.Operations[0].Location)
+					assert.NotEmpty(t, body.Operations[0].Version)
+					assert.Nil(t, body.Operations[0].Response)
+
+					assert.Equal(t, "DELETE", body.Operations[1].Method)
+					assert.Equal(t, "404", body.Operations[1].Status)
+					assert.Equal(t, "404", body.Operations[1].Response["status"])
+				}
+			},
+		},
+		{
+			name: "invalid bulk request",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("POST", "/Bulk", strings.NewReader(`{"schemas": "foo"}`)), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 400, rr.Code)
+			},
+		},
+		{
+			name: "bulk request exceeding maxPayloadSize is rejected before parsing",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("POST", "/Bulk", strings.NewReader(`{"schemas": "`+strings.Repeat("a", 4096))), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 413, rr.Code)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			database := db.Memory()
+			handler := &Bulk{
+				Log: log.None(),
+				Service: &services.BulkService{
+					Logger: log.None(),
+					Endpoints: []*services.BulkEndpoint{
+						{
+							ResourceType: resourceType,
+							Create: &services.CreateService{
+								Logger:   log.None(),
+								Filters:  []filters.ForResource{filters.ClearReadOnly(), filters.ID(), filters.Meta(), filters.Validation(database)},
+								Database: database,
+							},
+							Delete: &services.DeleteService{
+								Logger:                log.None(),
+								Database:              database,
+								ServiceProviderConfig: spc,
+							},
+						},
+					},
+					ServiceProviderConfig: spc,
+				},
+			}
+
+			rr := httptest.NewRecorder()
+			handler.Handle(test.getReq(t), http.DefaultResponse(rr))
+			test.expect(t, rr)
+		})
+	}
+}
+
+func (s *BulkHandlerTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *BulkHandlerTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
+
+func (s *BulkHandlerTestSuite) mustServiceProviderConfig(filePath string) *spec.ServiceProviderConfig {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	spc := new(spec.ServiceProviderConfig)
+	err = json.Unmarshal(raw, spc)
+	s.Require().Nil(err)
+
+	return spc
+}
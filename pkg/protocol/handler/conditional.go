@@ -7,6 +7,19 @@ import (
 )
 
 func interpretConditionalHeader(request http.Request) func(r *prop.Resource) bool {
+	if criteria := interpretIfMatch(request); criteria != nil {
+		return criteria
+	}
+	if criteria := interpretIfNoneMatch(request); criteria != nil {
+		return criteria
+	}
+	return func(r *prop.Resource) bool {
+		return true
+	}
+}
+
+// Returns the criteria which the resource must meet according to the If-Match header, or nil if the header is absent.
+func interpretIfMatch(request http.Request) func(r *prop.Resource) bool {
 	if ifMatch := request.Header("If-Match"); len(ifMatch) > 0 {
 		return func(r *prop.Resource) bool {
 			version := r.Version()
@@ -22,7 +35,12 @@ func interpretConditionalHeader(request http.Request) func(r *prop.Resource) boo
 			return false
 		}
 	}
+	return nil
+}
 
+// Returns the criteria which the resource must meet according to the If-None-Match header, or nil if the header is
+// absent.
+func interpretIfNoneMatch(request http.Request) func(r *prop.Resource) bool {
 	if ifNoneMatch := request.Header("If-None-Match"); len(ifNoneMatch) > 0 {
 		return func(r *prop.Resource) bool {
 			version := r.Version()
@@ -31,15 +49,18 @@ func interpretConditionalHeader(request http.Request) func(r *prop.Resource) boo
 				return false
 			}
 			for _, each := range strings.Split(ifNoneMatch, ",") {
-				if strings.TrimSpace(each) == version {
+				if weakTag(strings.TrimSpace(each)) == weakTag(version) {
 					return false
 				}
 			}
 			return true
 		}
 	}
+	return nil
+}
 
-	return func(r *prop.Resource) bool {
-		return true
-	}
+// Returns the entity tag without the weak indicator, so that tags can be compared with the weak comparison function
+// required by If-None-Match (RFC 7232 Section 3.2).
+func weakTag(tag string) string {
+	return strings.TrimPrefix(tag, "W/")
 }
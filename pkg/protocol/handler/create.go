@@ -5,6 +5,7 @@ import (
 	"github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/http"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/imulab/go-scim/pkg/protocol/services"
@@ -14,13 +15,26 @@ type Create struct {
 	Log          log.Logger
 	Service      *services.CreateService
 	ResourceType *spec.ResourceType
+	// Optional policy which decides the attributes of the returned resource that the principal may read.
+	Policy *auth.Policy
 }
 
 func (h *Create) Handle(request http.Request, response http.Response) {
 	h.Log.Info("request to create resource")
 
-	var payload *prop.Resource
+	var (
+		payload                 *prop.Resource
+		attributesParam         []string
+		excludedAttributesParam []string
+	)
 	{
+		var err error
+		attributesParam, excludedAttributesParam, err = parseProjection(request)
+		if err != nil {
+			WriteError(response, err)
+			return
+		}
+
 		raw, err := request.Body()
 		if err != nil {
 			h.Log.Error("failed to read request body: %s", err.Error())
@@ -45,15 +59,18 @@ func (h *Create) Handle(request http.Request, response http.Response) {
 		return
 	}
 
-	raw, err := json.Serialize(cr.Resource, json.Options())
+	raw, err := json.Serialize(cr.Resource, json.Options().
+		Include(attributesParam...).
+		Exclude(excludedAttributesParam...).
+		Allow(readable(request.Context(), h.Policy, cr.Resource)))
 	if err != nil {
 		WriteError(response, err)
 		return
 	}
 
-	response.WriteStatus(201)
 	response.WriteSCIMContentType()
 	response.WriteETag(cr.Version)
 	response.WriteLocation(cr.Location)
+	response.WriteStatus(201)
 	response.WriteBody(raw)
 }
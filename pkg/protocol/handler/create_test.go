@@ -73,6 +73,41 @@ func (s *CreateHandlerTestSuite) TestCreate() {
 				assert.NotEmpty(t, rr.Header().Get("ETag"))
 			},
 		},
+		{
+			name: 	"create new user with attributes",
+			getHandler: func(t *testing.T) *Create {
+				database := db.Memory()
+				return &Create{
+					Log:          log.None(),
+					ResourceType: resourceType,
+					Service:      &services.CreateService{
+						Logger:   log.None(),
+						Database: database,
+						Filters:  []filters.ForResource{
+							filters.ClearReadOnly(),
+							filters.ID(),
+							filters.Password(10),
+							filters.Meta(),
+							filters.Validation(database),
+						},
+					},
+				}
+			},
+			getRequest: func(t *testing.T) http.Request {
+				f, err := os.Open(s.resourceBase + "/user_001.json")
+				s.Require().Nil(err)
+				return http.DefaultRequest(httptest.NewRequest("POST", "/Users?attributes=userName", f), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 201, rr.Result().StatusCode)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "imulab", body["userName"])
+				assert.NotEmpty(t, body["id"])
+				assert.Nil(t, body["name"])
+				assert.Nil(t, body["emails"])
+			},
+		},
 		{
 			name: 	"create conflict user",
 			getHandler: func(t *testing.T) *Create {
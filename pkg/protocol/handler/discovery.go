@@ -0,0 +1,613 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	schemaSchemaURN       = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+	resourceTypeSchemaURN = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	listResponseURN       = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+)
+
+// Definitions of the discovery resources, as described in RFC 7643 section 6 and 7. The discovery endpoints (i.e.
+// /Schemas and /ResourceTypes) represent their content as resources of these types, so that filters can be evaluated
+// against them. They are only available after RegisterDiscovery is called.
+var discovery struct {
+	once                     sync.Once
+	schemaResourceType       *spec.ResourceType
+	resourceTypeResourceType *spec.ResourceType
+}
+
+// Register the discovery schemas in spec.SchemaHub, and the discovery resource types in expr, so that the Schemas and
+// ResourceTypes handlers can evaluate filters. It is intended to be called once during setup, alongside the
+// registration of the served schemas and resource types; subsequent calls have no effect. This method panics if the
+// built-in definitions are invalid.
+func RegisterDiscovery() {
+	discovery.once.Do(func() {
+		discovery.schemaResourceType = mustDiscoveryResourceType(schemaSchemaJSON, schemaResourceTypeJSON)
+		discovery.resourceTypeResourceType = mustDiscoveryResourceType(resourceTypeSchemaJSON, resourceTypeResourceTypeJSON)
+	})
+}
+
+func mustDiscoveryResourceType(schemaJSON string, resourceTypeJSON string) *spec.ResourceType {
+	sch := new(spec.Schema)
+	if err := json.Unmarshal([]byte(schemaJSON), sch); err != nil {
+		panic(err)
+	}
+	spec.SchemaHub.Put(sch)
+
+	rt := new(spec.ResourceType)
+	if err := json.Unmarshal([]byte(resourceTypeJSON), rt); err != nil {
+		panic(err)
+	}
+	expr.Register(rt)
+
+	return rt
+}
+
+// Iterate the schemas in spec.SchemaHub in the order of their ids, except the discovery schemas, which only describe
+// the discovery endpoints and are not served by them.
+func forEachSchema(callback func(schema *spec.Schema)) {
+	spec.SchemaHub.ForEachSchema(func(schema *spec.Schema) {
+		switch schema.ID() {
+		case schemaSchemaURN, resourceTypeSchemaURN:
+		default:
+			callback(schema)
+		}
+	})
+}
+
+// Convert the schema to a resource of the Schema resource type.
+func schemaToResource(sch *spec.Schema) (*prop.Resource, error) {
+	if discovery.schemaResourceType == nil {
+		return nil, errors.Internal("discovery resource types are not registered")
+	}
+
+	raw, err := json.Marshal(sch)
+	if err != nil {
+		return nil, errors.Internal("failed to serialize schema '%s': %s", sch.ID(), err.Error())
+	}
+
+	// The Schema resource type only describes two levels of attributes, which is all SCIM allows for resources. The
+	// Schema schema itself, however, goes one level deeper. Trim any deeper levels so the filter can be evaluated.
+	tmp := make(map[string]interface{})
+	if err := json.Unmarshal(raw, &tmp); err != nil {
+		return nil, errors.Internal("failed to serialize schema '%s': %s", sch.ID(), err.Error())
+	}
+	for _, attr := range asObjects(tmp["attributes"]) {
+		for _, subAttr := range asObjects(attr["subAttributes"]) {
+			delete(subAttr, "subAttributes")
+		}
+	}
+	if raw, err = json.Marshal(tmp); err != nil {
+		return nil, errors.Internal("failed to serialize schema '%s': %s", sch.ID(), err.Error())
+	}
+
+	resource := prop.NewResource(discovery.schemaResourceType)
+	if err := scimJSON.Deserialize(raw, resource); err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+// Convert the resource type to a resource of the ResourceType resource type.
+func resourceTypeToResource(rt *spec.ResourceType) (*prop.Resource, error) {
+	if discovery.resourceTypeResourceType == nil {
+		return nil, errors.Internal("discovery resource types are not registered")
+	}
+
+	raw, err := json.Marshal(rt)
+	if err != nil {
+		return nil, errors.Internal("failed to serialize resource type '%s': %s", rt.ID(), err.Error())
+	}
+
+	resource := prop.NewResource(discovery.resourceTypeResourceType)
+	if err := scimJSON.Deserialize(raw, resource); err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+func asObjects(v interface{}) []map[string]interface{} {
+	objects := make([]map[string]interface{}, 0)
+	if array, ok := v.([]interface{}); ok {
+		for _, elem := range array {
+			if object, ok := elem.(map[string]interface{}); ok {
+				objects = append(objects, object)
+			}
+		}
+	}
+	return objects
+}
+
+// Write a ListResponse of the discovery items to the response. There are n items in total, which are filtered by the
+// 'filter' query parameter, and paginated by the 'startIndex' and 'count' query parameters. The toResource function
+// converts the item at index i to a resource to evaluate the filter against; the render function renders the item at
+// index i to JSON.
+func writeDiscoveryList(request http.Request, response http.Response, n int,
+	toResource func(i int) (*prop.Resource, error), render func(i int) (json.RawMessage, error)) {
+	var filterRoot *expr.Expression
+	if v := strings.TrimSpace(request.QueryParam(filter)); len(v) > 0 {
+		root, err := expr.CompileFilter(v)
+		if err != nil {
+			WriteError(response, err)
+			return
+		}
+		filterRoot = root
+	}
+
+	pagination, err := parseDiscoveryPagination(request)
+	if err != nil {
+		WriteError(response, err)
+		return
+	}
+
+	matched := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		if filterRoot == nil {
+			matched = append(matched, i)
+			continue
+		}
+		resource, err := toResource(i)
+		if err != nil {
+			WriteError(response, err)
+			return
+		}
+		if ok, err := crud.Evaluate(resource.NewNavigator().Current(), filterRoot); err != nil {
+			WriteError(response, err)
+			return
+		} else if ok {
+			matched = append(matched, i)
+		}
+	}
+
+	page := make([]json.RawMessage, 0)
+	for _, k := range pagination.slice(len(matched)) {
+		raw, err := render(matched[k])
+		if err != nil {
+			WriteError(response, err)
+			return
+		}
+		page = append(page, raw)
+	}
+
+	raw, err := json.Marshal(listResponse(len(matched), pagination.StartIndex, page))
+	if err != nil {
+		WriteError(response, err)
+		return
+	}
+
+	response.WriteSCIMContentType()
+	response.WriteStatus(200)
+	response.WriteBody(raw)
+}
+
+// Marshal the discovery object v, and append the schemas and meta attributes to the JSON object.
+func renderDiscovery(v interface{}, schemaURN string, resourceType string, location string) (json.RawMessage, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Internal("failed to serialize %s: %s", resourceType, err.Error())
+	}
+
+	type meta struct {
+		ResourceType string `json:"resourceType"`
+		Location     string `json:"location,omitempty"`
+	}
+	extra, err := json.Marshal(struct {
+		Schemas []string `json:"schemas"`
+		Meta    meta     `json:"meta"`
+	}{
+		Schemas: []string{schemaURN},
+		Meta:    meta{ResourceType: resourceType, Location: location},
+	})
+	if err != nil {
+		return nil, errors.Internal("failed to serialize %s: %s", resourceType, err.Error())
+	}
+
+	if len(raw) < 2 || raw[0] != '{' || raw[len(raw)-1] != '}' {
+		return nil, errors.Internal("failed to serialize %s: expects json object", resourceType)
+	}
+	if len(raw) == 2 {
+		return extra, nil
+	}
+	return append(append(raw[:len(raw)-1:len(raw)-1], ','), extra[1:]...), nil
+}
+
+// Pagination parameters for discovery endpoints. Count of -1 indicates all results are requested.
+type discoveryPagination struct {
+	StartIndex int
+	Count      int
+}
+
+// Parse the startIndex and count query parameters. As per RFC 7644 section 3.4.2.4, startIndex less than 1 is
+// interpreted as 1, and negative count is interpreted as 0.
+func parseDiscoveryPagination(request http.Request) (*discoveryPagination, error) {
+	p := &discoveryPagination{StartIndex: 1, Count: -1}
+	if v := request.QueryParam(startIndex); len(v) > 0 {
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.InvalidRequest("invalid startIndex parameter")
+		}
+		if i > 1 {
+			p.StartIndex = i
+		}
+	}
+	if v := request.QueryParam(count); len(v) > 0 {
+		c, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.InvalidRequest("invalid count parameter")
+		}
+		if c < 0 {
+			c = 0
+		}
+		p.Count = c
+	}
+	return p, nil
+}
+
+// Return the indexes of the items on this page, out of total items.
+func (p *discoveryPagination) slice(total int) []int {
+	indexes := make([]int, 0)
+	for i := p.StartIndex - 1; i < total; i++ {
+		if p.Count >= 0 && len(indexes) >= p.Count {
+			break
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// Create a ListResponse message for the page of resources.
+func listResponse(total int, startIndex int, resources []json.RawMessage) interface{} {
+	return struct {
+		Schemas      []string          `json:"schemas"`
+		TotalResults int               `json:"totalResults"`
+		ItemsPerPage int               `json:"itemsPerPage"`
+		StartIndex   int               `json:"startIndex"`
+		Resources    []json.RawMessage `json:"Resources"`
+	}{
+		Schemas:      []string{listResponseURN},
+		TotalResults: total,
+		ItemsPerPage: len(resources),
+		StartIndex:   startIndex,
+		Resources:    resources,
+	}
+}
+
+var (
+	schemaResourceTypeJSON = `
+{
+	"id": "Schema",
+	"name": "Schema",
+	"description": "Schema discovery resource type",
+	"endpoint": "/Schemas",
+	"schema": "urn:ietf:params:scim:schemas:core:2.0:Schema"
+}
+`
+	schemaSchemaJSON = `
+{
+	"id": "urn:ietf:params:scim:schemas:core:2.0:Schema",
+	"name": "Schema",
+	"description": "Specifies the schema attributes",
+	"attributes": [
+		{
+			"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:name",
+			"name": "name",
+			"type": "string",
+			"mutability": "readOnly",
+			"_index": 100,
+			"_path": "name"
+		},
+		{
+			"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:description",
+			"name": "description",
+			"type": "string",
+			"mutability": "readOnly",
+			"_index": 101,
+			"_path": "description"
+		},
+		{
+			"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes",
+			"name": "attributes",
+			"type": "complex",
+			"multiValued": true,
+			"mutability": "readOnly",
+			"subAttributes": [
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.name",
+					"name": "name",
+					"type": "string",
+					"mutability": "readOnly",
+					"_index": 0,
+					"_path": "attributes.name"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.type",
+					"name": "type",
+					"type": "string",
+					"mutability": "readOnly",
+					"_index": 1,
+					"_path": "attributes.type"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.multiValued",
+					"name": "multiValued",
+					"type": "boolean",
+					"mutability": "readOnly",
+					"_index": 2,
+					"_path": "attributes.multiValued"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.description",
+					"name": "description",
+					"type": "string",
+					"mutability": "readOnly",
+					"_index": 3,
+					"_path": "attributes.description"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.required",
+					"name": "required",
+					"type": "boolean",
+					"mutability": "readOnly",
+					"_index": 4,
+					"_path": "attributes.required"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.canonicalValues",
+					"name": "canonicalValues",
+					"type": "string",
+					"multiValued": true,
+					"mutability": "readOnly",
+					"_index": 5,
+					"_path": "attributes.canonicalValues"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.caseExact",
+					"name": "caseExact",
+					"type": "boolean",
+					"mutability": "readOnly",
+					"_index": 6,
+					"_path": "attributes.caseExact"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.mutability",
+					"name": "mutability",
+					"type": "string",
+					"mutability": "readOnly",
+					"_index": 7,
+					"_path": "attributes.mutability"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.returned",
+					"name": "returned",
+					"type": "string",
+					"mutability": "readOnly",
+					"_index": 8,
+					"_path": "attributes.returned"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.uniqueness",
+					"name": "uniqueness",
+					"type": "string",
+					"mutability": "readOnly",
+					"_index": 9,
+					"_path": "attributes.uniqueness"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.referenceTypes",
+					"name": "referenceTypes",
+					"type": "string",
+					"multiValued": true,
+					"mutability": "readOnly",
+					"_index": 10,
+					"_path": "attributes.referenceTypes"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes",
+					"name": "subAttributes",
+					"type": "complex",
+					"multiValued": true,
+					"mutability": "readOnly",
+					"subAttributes": [
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.name",
+							"name": "name",
+							"type": "string",
+							"mutability": "readOnly",
+							"_index": 0,
+							"_path": "attributes.subAttributes.name"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.type",
+							"name": "type",
+							"type": "string",
+							"mutability": "readOnly",
+							"_index": 1,
+							"_path": "attributes.subAttributes.type"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.multiValued",
+							"name": "multiValued",
+							"type": "boolean",
+							"mutability": "readOnly",
+							"_index": 2,
+							"_path": "attributes.subAttributes.multiValued"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.description",
+							"name": "description",
+							"type": "string",
+							"mutability": "readOnly",
+							"_index": 3,
+							"_path": "attributes.subAttributes.description"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.required",
+							"name": "required",
+							"type": "boolean",
+							"mutability": "readOnly",
+							"_index": 4,
+							"_path": "attributes.subAttributes.required"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.canonicalValues",
+							"name": "canonicalValues",
+							"type": "string",
+							"multiValued": true,
+							"mutability": "readOnly",
+							"_index": 5,
+							"_path": "attributes.subAttributes.canonicalValues"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.caseExact",
+							"name": "caseExact",
+							"type": "boolean",
+							"mutability": "readOnly",
+							"_index": 6,
+							"_path": "attributes.subAttributes.caseExact"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.mutability",
+							"name": "mutability",
+							"type": "string",
+							"mutability": "readOnly",
+							"_index": 7,
+							"_path": "attributes.subAttributes.mutability"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.returned",
+							"name": "returned",
+							"type": "string",
+							"mutability": "readOnly",
+							"_index": 8,
+							"_path": "attributes.subAttributes.returned"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.uniqueness",
+							"name": "uniqueness",
+							"type": "string",
+							"mutability": "readOnly",
+							"_index": 9,
+							"_path": "attributes.subAttributes.uniqueness"
+						},
+						{
+							"id": "urn:ietf:params:scim:schemas:core:2.0:Schema:attributes.subAttributes.referenceTypes",
+							"name": "referenceTypes",
+							"type": "string",
+							"multiValued": true,
+							"mutability": "readOnly",
+							"_index": 10,
+							"_path": "attributes.subAttributes.referenceTypes"
+						}
+					],
+					"_index": 11,
+					"_path": "attributes.subAttributes"
+				}
+			],
+			"_index": 102,
+			"_path": "attributes"
+		}
+	]
+}
+`
+	resourceTypeResourceTypeJSON = `
+{
+	"id": "ResourceType",
+	"name": "ResourceType",
+	"description": "Resource type discovery resource type",
+	"endpoint": "/ResourceTypes",
+	"schema": "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+}
+`
+	resourceTypeSchemaJSON = `
+{
+	"id": "urn:ietf:params:scim:schemas:core:2.0:ResourceType",
+	"name": "ResourceType",
+	"description": "Specifies the schema that describes a SCIM resource type",
+	"attributes": [
+		{
+			"id": "urn:ietf:params:scim:schemas:core:2.0:ResourceType:name",
+			"name": "name",
+			"type": "string",
+			"mutability": "readOnly",
+			"_index": 100,
+			"_path": "name"
+		},
+		{
+			"id": "urn:ietf:params:scim:schemas:core:2.0:ResourceType:description",
+			"name": "description",
+			"type": "string",
+			"mutability": "readOnly",
+			"_index": 101,
+			"_path": "description"
+		},
+		{
+			"id": "urn:ietf:params:scim:schemas:core:2.0:ResourceType:endpoint",
+			"name": "endpoint",
+			"type": "reference",
+			"referenceTypes": [
+				"uri"
+			],
+			"mutability": "readOnly",
+			"_index": 102,
+			"_path": "endpoint"
+		},
+		{
+			"id": "urn:ietf:params:scim:schemas:core:2.0:ResourceType:schema",
+			"name": "schema",
+			"type": "reference",
+			"referenceTypes": [
+				"uri"
+			],
+			"caseExact": true,
+			"mutability": "readOnly",
+			"_index": 103,
+			"_path": "schema"
+		},
+		{
+			"id": "urn:ietf:params:scim:schemas:core:2.0:ResourceType:schemaExtensions",
+			"name": "schemaExtensions",
+			"type": "complex",
+			"multiValued": true,
+			"mutability": "readOnly",
+			"subAttributes": [
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:ResourceType:schemaExtensions.schema",
+					"name": "schema",
+					"type": "reference",
+					"referenceTypes": [
+						"uri"
+					],
+					"caseExact": true,
+					"mutability": "readOnly",
+					"_index": 0,
+					"_path": "schemaExtensions.schema"
+				},
+				{
+					"id": "urn:ietf:params:scim:schemas:core:2.0:ResourceType:schemaExtensions.required",
+					"name": "required",
+					"type": "boolean",
+					"mutability": "readOnly",
+					"_index": 1,
+					"_path": "schemaExtensions.required"
+				}
+			],
+			"_index": 104,
+			"_path": "schemaExtensions"
+		}
+	]
+}
+`
+)
@@ -3,17 +3,19 @@ package handler
 import (
 	"github.com/imulab/go-scim/pkg/core/errors"
 	"github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/crud"
 	"github.com/imulab/go-scim/pkg/protocol/http"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/imulab/go-scim/pkg/protocol/services"
-	"strings"
 )
 
 type Get struct {
 	Log                 log.Logger
 	Service             *services.GetService
 	ResourceIDPathParam string
+	// Optional policy which decides the attributes of the returned resource that the principal may read.
+	Policy *auth.Policy
 }
 
 func (h *Get) Handle(request http.Request, response http.Response) {
@@ -30,39 +32,57 @@ func (h *Get) Handle(request http.Request, response http.Response) {
 			return
 		}
 
-		if v := strings.TrimSpace(request.QueryParam(attributes)); len(v) > 0 {
-			attributesParam = strings.Split(v, space)
-		}
-		if v := strings.TrimSpace(request.QueryParam(excludedAttributes)); len(v) > 0 {
-			excludedAttributesParam = strings.Split(v, space)
-		}
-		if len(attributesParam) > 0 && len(excludedAttributesParam) > 0 {
-			WriteError(response, errors.InvalidRequest("only one of %s and %s parameter may be used", attributes, excludedAttributes))
+		var err error
+		attributesParam, excludedAttributesParam, err = parseProjection(request)
+		if err != nil {
+			WriteError(response, err)
 			return
 		}
 	}
 
+	projection := &crud.Projection{
+		Attributes:         attributesParam,
+		ExcludedAttributes: excludedAttributesParam,
+	}
+	if h.Policy != nil {
+		// the policy may decide by attributes which are not projected
+		projection = nil
+	}
+
 	gr, err := h.Service.GetResource(request.Context(), &services.GetRequest{
-		Projection: &crud.Projection{
-			Attributes:         attributesParam,
-			ExcludedAttributes: excludedAttributesParam,
-		},
-		ResourceID: resourceIDParam,
+		Projection:        projection,
+		ResourceID:        resourceIDParam,
+		MatchCriteria:     interpretIfMatch(request),
+		NoneMatchCriteria: interpretIfNoneMatch(request),
 	})
 	if err != nil {
+		if scimError, ok := err.(*errors.Error); ok && scimError.Type == errors.TypePreCondition {
+			response.WriteStatus(412)
+			return
+		}
 		WriteError(response, err)
 		return
 	}
 
-	raw, err := json.Serialize(gr.Resource, json.Options().Include(attributesParam...).Exclude(excludedAttributesParam...))
+	if gr.NotModified {
+		response.WriteETag(gr.Version)
+		response.WriteLocation(gr.Location)
+		response.WriteStatus(304)
+		return
+	}
+
+	raw, err := json.Serialize(gr.Resource, json.Options().
+		Include(attributesParam...).
+		Exclude(excludedAttributesParam...).
+		Allow(readable(request.Context(), h.Policy, gr.Resource)))
 	if err != nil {
 		WriteError(response, err)
 		return
 	}
 
-	response.WriteStatus(200)
 	response.WriteSCIMContentType()
 	response.WriteETag(gr.Version)
 	response.WriteLocation(gr.Location)
+	response.WriteStatus(200)
 	response.WriteBody(raw)
 }
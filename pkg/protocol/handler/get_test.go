@@ -16,6 +16,7 @@ import (
 	"io/ioutil"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -33,6 +34,28 @@ type GetHandlerTestSuite struct {
 func (s *GetHandlerTestSuite) TestHandle() {
 	_ = s.mustSchema("/user_schema.json")
 	resourceType := s.mustResourceType("/user_resource_type.json")
+	spc := s.mustServiceProviderConfig("/service_provider_config.json")
+	version := s.mustResource("/user_001.json", resourceType).Version()
+
+	conditionalHandler := func(t *testing.T) *Get {
+		database := db.Memory()
+		err := database.Insert(context.Background(), s.mustResource("/user_001.json", resourceType))
+		require.Nil(t, err)
+		return &Get{
+			Log:                 log.None(),
+			ResourceIDPathParam: "userId",
+			Service: &services.GetService{
+				Logger:                log.None(),
+				Database:              database,
+				ServiceProviderConfig: spc,
+			},
+		}
+	}
+	conditionalRequest := func(header string, value string) http.Request {
+		req := httptest.NewRequest("GET", "/Users/a5866759-32ca-4e2a-9808-a0fe74f94b18", nil)
+		req.Header.Set(header, value)
+		return http.DefaultRequest(req, []string{"/Users/(?P<userId>.*)"})
+	}
 
 	tests := []struct {
 		name       string
@@ -73,7 +96,7 @@ func (s *GetHandlerTestSuite) TestHandle() {
     "created": "2019-11-20T13:09:00",
     "lastModified": "2019-11-20T13:09:00",
     "location": "https://identity.imulab.io/Users/3cc032f5-2361-417f-9e2f-bc80adddf4a3",
-    "version": "W/\\\"1\\\""
+    "version": "W/\"1\""
   },
   "userName": "user001",
   "name": {
@@ -230,7 +253,7 @@ func (s *GetHandlerTestSuite) TestHandle() {
     "created": "2019-11-20T13:09:00",
     "lastModified": "2019-11-20T13:09:00",
     "location": "https://identity.imulab.io/Users/3cc032f5-2361-417f-9e2f-bc80adddf4a3",
-    "version": "W/\\\"1\\\""
+    "version": "W/\"1\""
   },
   "userName": "user001",
   "name": {
@@ -280,6 +303,62 @@ func (s *GetHandlerTestSuite) TestHandle() {
 				assert.Equal(t, 404, rr.Result().StatusCode)
 			},
 		},
+		{
+			name:       "get with matching If-None-Match",
+			getHandler: conditionalHandler,
+			getReq: func(t *testing.T) http.Request {
+				return conditionalRequest("If-None-Match", `W/"0", `+version)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 304, rr.Result().StatusCode)
+				assert.Equal(t, version, rr.Result().Header.Get("ETag"))
+				assert.Empty(t, rr.Body.String())
+			},
+		},
+		{
+			name:       "get with strong If-None-Match matching weak version",
+			getHandler: conditionalHandler,
+			getReq: func(t *testing.T) http.Request {
+				return conditionalRequest("If-None-Match", strings.TrimPrefix(version, "W/"))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 304, rr.Result().StatusCode)
+				assert.Empty(t, rr.Body.String())
+			},
+		},
+		{
+			name:       "get with non-matching If-None-Match",
+			getHandler: conditionalHandler,
+			getReq: func(t *testing.T) http.Request {
+				return conditionalRequest("If-None-Match", `W/"0"`)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Result().StatusCode)
+				assert.NotEmpty(t, rr.Body.String())
+			},
+		},
+		{
+			name:       "get with matching If-Match",
+			getHandler: conditionalHandler,
+			getReq: func(t *testing.T) http.Request {
+				return conditionalRequest("If-Match", version)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Result().StatusCode)
+				assert.NotEmpty(t, rr.Body.String())
+			},
+		},
+		{
+			name:       "get with non-matching If-Match",
+			getHandler: conditionalHandler,
+			getReq: func(t *testing.T) http.Request {
+				return conditionalRequest("If-Match", `W/"0"`)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 412, rr.Result().StatusCode)
+				assert.Empty(t, rr.Body.String())
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -335,3 +414,17 @@ func (s *GetHandlerTestSuite) mustSchema(filePath string) *spec.Schema {
 
 	return sch
 }
+
+func (s *GetHandlerTestSuite) mustServiceProviderConfig(filePath string) *spec.ServiceProviderConfig {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	spc := new(spec.ServiceProviderConfig)
+	err = json.Unmarshal(raw, spc)
+	s.Require().Nil(err)
+
+	return spc
+}
@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/http"
+	"strings"
 )
 
 // Handler function implemented by endpoint handlers in this package.
@@ -20,12 +25,36 @@ func WriteError(response http.Response, err error) {
 		}
 	}
 
-	response.WriteStatus(scimError.Status)
 	response.WriteSCIMContentType()
+	response.WriteStatus(scimError.Status)
 	raw, _ := json.Marshal(scimError)
 	response.WriteBody(raw)
 }
 
+// Returns the function which decides whether the attributes of the resource may be read by the principal in the
+// context, or nil if there is no policy, in which case all attributes may be read.
+func readable(ctx context.Context, policy *auth.Policy, resource *prop.Resource) func(attribute *spec.Attribute) bool {
+	if policy == nil {
+		return nil
+	}
+	return policy.Allows(ctx, auth.OperationRead, resource)
+}
+
+// Parse the space separated attributes and excludedAttributes query parameters of the request. It is an error to use
+// both parameters at the same time.
+func parseProjection(request http.Request) (attributesParam []string, excludedAttributesParam []string, err error) {
+	if v := strings.TrimSpace(request.QueryParam(attributes)); len(v) > 0 {
+		attributesParam = strings.Split(v, space)
+	}
+	if v := strings.TrimSpace(request.QueryParam(excludedAttributes)); len(v) > 0 {
+		excludedAttributesParam = strings.Split(v, space)
+	}
+	if len(attributesParam) > 0 && len(excludedAttributesParam) > 0 {
+		err = errors.InvalidRequest("only one of %s and %s parameter may be used", attributes, excludedAttributes)
+	}
+	return
+}
+
 const (
 	attributes         = "attributes"
 	excludedAttributes = "excludedAttributes"
@@ -34,5 +63,6 @@ const (
 	sortOrder          = "sortOrder"
 	startIndex         = "startIndex"
 	count              = "count"
+	cursor             = "cursor"
 	space              = " "
 )
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
+	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+)
+
+// Resolves the id of the resource which represents the authenticated principal.
+type MeResolver func(ctx context.Context, principal *auth.Principal) (string, error)
+
+// Returns a MeResolver which finds the resource whose userName equals to the subject of the principal.
+func MeByUserName(database db.DB) MeResolver {
+	return func(ctx context.Context, principal *auth.Principal) (string, error) {
+		subject, _ := json.Marshal(principal.Subject)
+		resources, err := database.Query(ctx, "userName eq "+string(subject), nil, nil, nil)
+		if err != nil {
+			return "", err
+		}
+		switch len(resources) {
+		case 0:
+			return "", errors.NotFound("no resource is found for the authenticated subject")
+		case 1:
+			return resources[0].ID(), nil
+		default:
+			return "", errors.Internal("more than one resource is found for the authenticated subject")
+		}
+	}
+}
+
+// Handler for the /Me endpoint, as described in RFC 7644 section 3.11. The authenticated principal is taken from the
+// request context (see auth.WithPrincipal), and resolved to a resource id by Resolver. The request is then delegated
+// to the Get, Replace, Patch or Delete handler, as if the resolved id was provided as their resource id path parameter.
+// A nil handler indicates the method is not supported.
+type Me struct {
+	Log      log.Logger
+	Resolver MeResolver
+	Get      *Get
+	Replace  *Replace
+	Patch    *Patch
+	Delete   *Delete
+}
+
+func (h *Me) Handle(request http.Request, response http.Response) {
+	h.Log.Info("request to /Me")
+
+	if h.Resolver == nil {
+		WriteError(response, errors.NotImplemented("/Me is not supported"))
+		return
+	}
+
+	principal := auth.PrincipalFrom(request.Context())
+	if principal == nil {
+		WriteError(response, errors.Unauthorized("request is not authenticated"))
+		return
+	}
+
+	resourceID, err := h.Resolver(request.Context(), principal)
+	if err != nil {
+		h.Log.Error("failed to resolve resource for subject [%s]: %s", principal.Subject, err.Error())
+		WriteError(response, err)
+		return
+	}
+
+	switch {
+	case request.Method() == "GET" && h.Get != nil:
+		h.Get.Handle(&meRequest{Request: request, param: h.Get.ResourceIDPathParam, id: resourceID}, response)
+	case request.Method() == "PUT" && h.Replace != nil:
+		h.Replace.Handle(&meRequest{Request: request, param: h.Replace.ResourceIDPathParam, id: resourceID}, response)
+	case request.Method() == "PATCH" && h.Patch != nil:
+		h.Patch.Handle(&meRequest{Request: request, param: h.Patch.ResourceIDPathParam, id: resourceID}, response)
+	case request.Method() == "DELETE" && h.Delete != nil:
+		h.Delete.Handle(&meRequest{Request: request, param: h.Delete.ResourceIDPathParam, id: resourceID}, response)
+	default:
+		WriteError(response, errors.MethodNotAllowed("method %s is not supported on /Me", request.Method()))
+	}
+}
+
+// Request which reports the resolved resource id as the value of the resource id path parameter.
+type meRequest struct {
+	http.Request
+	param string
+	id    string
+}
+
+func (r *meRequest) PathParam(param string) string {
+	if param == r.param {
+		return r.id
+	}
+	return r.Request.PathParam(param)
+}
+
+func (r *meRequest) Unwrap() http.Request {
+	return r.Request
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
+	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/imulab/go-scim/pkg/protocol/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMeHandler(t *testing.T) {
+	s := new(MeHandlerTestSuite)
+	s.resourceBase = "../../tests/me_handler_test_suite"
+	suite.Run(t, s)
+}
+
+type MeHandlerTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *MeHandlerTestSuite) TestHandle() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	spc := s.mustServiceProviderConfig("/service_provider_config.json")
+
+	newHandler := func(t *testing.T, database db.DB) *Me {
+		require.Nil(t, database.Insert(context.Background(), s.mustResource("/user_001.json", resourceType)))
+		return &Me{
+			Log:      log.None(),
+			Resolver: MeByUserName(database),
+			Get: &Get{
+				Log:                 log.None(),
+				ResourceIDPathParam: "id",
+				Service:             &services.GetService{Logger: log.None(), Database: database},
+			},
+			Delete: &Delete{
+				Log:                 log.None(),
+				ResourceIDPathParam: "id",
+				Service:             &services.DeleteService{Logger: log.None(), Database: database, ServiceProviderConfig: spc},
+			},
+		}
+	}
+	authenticated := func(method string, subject string) http.Request {
+		req := httptest.NewRequest(method, "/Me", nil)
+		req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: subject}))
+		return http.DefaultRequest(req, nil)
+	}
+
+	tests := []struct {
+		name       string
+		getHandler func(t *testing.T, database db.DB) *Me
+		getReq     func(t *testing.T) http.Request
+		expect     func(t *testing.T, rr *httptest.ResponseRecorder, database db.DB)
+	}{
+		{
+			name:       "get me",
+			getHandler: newHandler,
+			getReq: func(t *testing.T) http.Request {
+				return authenticated("GET", "user001")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, database db.DB) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "a5866759-32ca-4e2a-9808-a0fe74f94b18", body["id"])
+				assert.Equal(t, "user001", body["userName"])
+			},
+		},
+		{
+			name:       "delete me",
+			getHandler: newHandler,
+			getReq: func(t *testing.T) http.Request {
+				return authenticated("DELETE", "user001")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, database db.DB) {
+				assert.Equal(t, 204, rr.Code)
+				n, err := database.Count(context.Background(), "id pr")
+				assert.Nil(t, err)
+				assert.Equal(t, 0, n)
+			},
+		},
+		{
+			name:       "method not configured",
+			getHandler: newHandler,
+			getReq: func(t *testing.T) http.Request {
+				return authenticated("PUT", "user001")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, database db.DB) {
+				assert.Equal(t, 405, rr.Code)
+			},
+		},
+		{
+			name:       "unknown subject",
+			getHandler: newHandler,
+			getReq: func(t *testing.T) http.Request {
+				return authenticated("GET", "foo")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, database db.DB) {
+				assert.Equal(t, 404, rr.Code)
+			},
+		},
+		{
+			name:       "not authenticated",
+			getHandler: newHandler,
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET", "/Me", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, database db.DB) {
+				assert.Equal(t, 401, rr.Code)
+			},
+		},
+		{
+			name: "no resolver",
+			getHandler: func(t *testing.T, database db.DB) *Me {
+				return &Me{Log: log.None()}
+			},
+			getReq: func(t *testing.T) http.Request {
+				return authenticated("GET", "user001")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder, database db.DB) {
+				assert.Equal(t, 501, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "notImplemented", body["scimType"])
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			database := db.Memory()
+			rr := httptest.NewRecorder()
+			test.getHandler(t, database).Handle(test.getReq(t), http.DefaultResponse(rr))
+			test.expect(t, rr, database)
+		})
+	}
+}
+
+func (s *MeHandlerTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	resource := prop.NewResource(resourceType)
+	err = scimJSON.Deserialize(raw, resource)
+	s.Require().Nil(err)
+
+	return resource
+}
+
+func (s *MeHandlerTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *MeHandlerTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
+
+func (s *MeHandlerTestSuite) mustServiceProviderConfig(filePath string) *spec.ServiceProviderConfig {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	spc := new(spec.ServiceProviderConfig)
+	err = json.Unmarshal(raw, spc)
+	s.Require().Nil(err)
+
+	return spc
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/imulab/go-scim/pkg/core/errors"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/http"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/imulab/go-scim/pkg/protocol/services"
@@ -13,16 +14,29 @@ type Patch struct {
 	Log                 log.Logger
 	Service             *services.PatchService
 	ResourceIDPathParam string
+	// Optional policy which decides the attributes of the returned resource that the principal may read.
+	Policy *auth.Policy
 }
 
 func (h *Patch) Handle(request http.Request, response http.Response) {
-	var payload *services.PatchRequest
+	var (
+		payload                 *services.PatchRequest
+		attributesParam         []string
+		excludedAttributesParam []string
+	)
 	{
 		payload = new(services.PatchRequest)
 
 		payload.ResourceID = request.PathParam(h.ResourceIDPathParam)
 		payload.MatchCriteria = interpretConditionalHeader(request)
 
+		var err error
+		attributesParam, excludedAttributesParam, err = parseProjection(request)
+		if err != nil {
+			WriteError(response, err)
+			return
+		}
+
 		raw, err := request.Body()
 		if err != nil {
 			h.Log.Error("failed to read request body for patching resource [id=%s]: %s", payload.ResourceID, err.Error())
@@ -42,20 +56,26 @@ func (h *Patch) Handle(request http.Request, response http.Response) {
 		return
 	}
 
-	if pr.NewVersion == pr.OldVersion {
+	// An empty attributes parameter asks for no attributes to be returned.
+	noContent := http.HasQueryParam(request, attributes) && len(attributesParam) == 0
+
+	if pr.NewVersion == pr.OldVersion || noContent {
 		response.WriteLocation(pr.Location)
 		response.WriteETag(pr.NewVersion)
 		response.WriteStatus(204)
 	} else {
-		raw, err := scimJSON.Serialize(pr.Resource, scimJSON.Options())
+		raw, err := scimJSON.Serialize(pr.Resource, scimJSON.Options().
+			Include(attributesParam...).
+			Exclude(excludedAttributesParam...).
+			Allow(readable(request.Context(), h.Policy, pr.Resource)))
 		if err != nil {
 			WriteError(response, err)
 			return
 		}
-		response.WriteBody(raw)
 		response.WriteLocation(pr.Location)
 		response.WriteETag(pr.NewVersion)
 		response.WriteSCIMContentType()
 		response.WriteStatus(200)
+		response.WriteBody(raw)
 	}
 }
@@ -36,6 +36,34 @@ func (s *PatchHandlerTestSuite) TestPatch() {
 	resourceType := s.mustResourceType("/user_resource_type.json")
 	spc := s.mustServiceProviderConfig("/service_provider_config.json")
 
+	existingHandler := func(t *testing.T) *Patch {
+		database := db.Memory()
+		err := database.Insert(context.Background(), s.mustResource("/user_000.json", resourceType))
+		require.Nil(t, err)
+		return &Patch{
+			Log:                 log.None(),
+			ResourceIDPathParam: "userId",
+			Service: &services.PatchService{
+				Logger:          log.None(),
+				Database:        database,
+				PrePatchFilters: []filters.ForResource{},
+				PostPatchFilters: []filters.ForResource{
+					filters.CopyReadOnly(),
+					filters.Password(10),
+					filters.Validation(database),
+					filters.Meta(),
+				},
+				ServiceProviderConfig: spc,
+			},
+		}
+	}
+	existingRequest := func(t *testing.T, query string) http.Request {
+		f, err := os.Open(s.resourceBase + "/patch_001.json")
+		require.Nil(t, err)
+		return http.DefaultRequest(httptest.NewRequest("PATCH", "/Users/3cc032f5-2361-417f-9e2f-bc80adddf4a3"+query, f),
+			[]string{"/Users/(?P<userId>.*)"})
+	}
+
 	tests := []struct {
 		name       string
 		getHandler func(t *testing.T) *Patch
@@ -119,6 +147,57 @@ func (s *PatchHandlerTestSuite) TestPatch() {
 				}
 			},
 		},
+		{
+			name:       "patch with attributes",
+			getHandler: existingHandler,
+			getRequest: func(t *testing.T) http.Request {
+				return existingRequest(t, "?attributes=userName")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Result().StatusCode)
+				assert.JSONEq(t, `
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "3cc032f5-2361-417f-9e2f-bc80adddf4a3",
+	"userName": "davidiamyou"
+}
+`, rr.Body.String())
+			},
+		},
+		{
+			name:       "patch with excludedAttributes",
+			getHandler: existingHandler,
+			getRequest: func(t *testing.T) http.Request {
+				return existingRequest(t, "?excludedAttributes=emails")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Result().StatusCode)
+				assert.NotContains(t, rr.Body.String(), "emails")
+				assert.Contains(t, rr.Body.String(), "davidiamyou")
+			},
+		},
+		{
+			name:       "patch with no attributes",
+			getHandler: existingHandler,
+			getRequest: func(t *testing.T) http.Request {
+				return existingRequest(t, "?attributes=")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 204, rr.Result().StatusCode)
+				assert.Empty(t, rr.Body.String())
+				assert.NotEmpty(t, rr.Header().Get("ETag"))
+			},
+		},
+		{
+			name:       "patch with both attributes and excludedAttributes",
+			getHandler: existingHandler,
+			getRequest: func(t *testing.T) http.Request {
+				return existingRequest(t, "?attributes=userName&excludedAttributes=emails")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 400, rr.Result().StatusCode)
+			},
+		},
 		{
 			name: "patch non-existing resource",
 			getHandler: func(t *testing.T) *Patch {
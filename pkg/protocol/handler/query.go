@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/crud"
 	"github.com/imulab/go-scim/pkg/protocol/http"
 	"github.com/imulab/go-scim/pkg/protocol/log"
@@ -15,6 +20,16 @@ import (
 type Query struct {
 	Log     log.Logger
 	Service *services.QueryService
+	// Optional policy which decides the attributes of the returned resources that the principal may read. The filter
+	// and sort may only refer to attributes that the principal may read of every resource.
+	Policy *auth.Policy
+	// Resource types of the resources returned by a service in the multi-type mode, which the filter and sort are
+	// authorized against. Not needed when the service queries a single resource type.
+	ResourceTypes []*spec.ResourceType
+	// When true, the ListResponse is written out as the resources are iterated, rather than being serialized as a
+	// whole, so that memory use does not grow with the number of results. Errors which occur after the response has
+	// started can only be logged, and leave the response truncated.
+	Stream bool
 }
 
 func (h *Query) Handle(request http.Request, response http.Response) {
@@ -25,21 +40,126 @@ func (h *Query) Handle(request http.Request, response http.Response) {
 		return
 	}
 
+	if err := h.authorize(request.Context(), qr); err != nil {
+		WriteError(response, err)
+		return
+	}
+
+	projection := qr.Projection
+	if h.Policy != nil {
+		// the policy may decide by attributes which are not projected
+		qr.Projection = nil
+	}
+
+	if h.Stream {
+		h.stream(request, response, qr, projection)
+		return
+	}
+
 	qt, err := h.Service.QueryResource(request.Context(), qr)
 	if err != nil {
 		WriteError(response, err)
 		return
 	}
 
-	raw, err := h.serializeResponse(qr, qt)
+	qr.Projection = projection
+	raw, err := h.serializeResponse(request.Context(), qr, qt)
 	if err != nil {
 		WriteError(response, err)
 		return
 	}
 
-	response.WriteBody(raw)
 	response.WriteSCIMContentType()
 	response.WriteStatus(200)
+	response.WriteBody(raw)
+}
+
+// Reject the query if its filter or sort refers to attributes which the principal may not read, as the total results
+// and the order of the resources would otherwise disclose their values. Since no resource is known yet, rules which
+// only apply to the principal's own resource do not count. Paths which fail to compile or resolve are left to the
+// service to report.
+func (h *Query) authorize(ctx context.Context, qr *services.QueryRequest) error {
+	if h.Policy == nil {
+		return nil
+	}
+
+	paths := make([]*expr.Expression, 0)
+	if len(qr.Filter) > 0 {
+		root, err := expr.CompileFilter(qr.Filter)
+		if err != nil {
+			return nil
+		}
+		paths = append(paths, filterPaths(root)...)
+	}
+	if qr.Sort != nil && len(qr.Sort.By) > 0 {
+		head, err := expr.CompilePath(qr.Sort.By)
+		if err != nil {
+			return nil
+		}
+		paths = append(paths, head)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	resourceTypes := h.ResourceTypes
+	if h.Service.ResourceType != nil {
+		resourceTypes = []*spec.ResourceType{h.Service.ResourceType}
+	}
+	for _, resourceType := range resourceTypes {
+		allowed := h.Policy.AllowsResourceType(ctx, auth.OperationRead, resourceType)
+		for _, path := range paths {
+			if path.IsPath() && strings.ToLower(path.Token()) == strings.ToLower(resourceType.Schema().ID()) {
+				path = path.Next()
+			}
+			for _, attr := range pathAttributes(path, resourceType.SuperAttribute(true)) {
+				if !allowed(attr) {
+					return errors.Forbidden("not allowed to filter or sort by attribute '%s'", attr.Path())
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Return the heads of the attribute paths in the filter, including value path filters.
+func filterPaths(filter *expr.Expression) []*expr.Expression {
+	switch {
+	case filter == nil:
+		return nil
+	case filter.IsPath():
+		return []*expr.Expression{filter}
+	case filter.IsLogicalOperator():
+		return append(filterPaths(filter.Left()), filterPaths(filter.Right())...)
+	default:
+		return filterPaths(filter.Left())
+	}
+}
+
+// Return the attributes at the end of the path, and of the paths in its value filters, or nil if the path does not
+// resolve against the container.
+func pathAttributes(path *expr.Expression, container *spec.Attribute) []*spec.Attribute {
+	attrs := make([]*spec.Attribute, 0)
+	attr := container
+	for step := path; step != nil; step = step.Next() {
+		if step.IsRootOfFilter() {
+			for _, each := range filterPaths(step) {
+				sub := pathAttributes(each, attr)
+				if sub == nil {
+					return nil
+				}
+				attrs = append(attrs, sub...)
+			}
+			continue
+		}
+		if attr = attr.SubAttributeForName(step.Token()); attr == nil {
+			return nil
+		}
+	}
+	if attr == container {
+		return nil
+	}
+	return append(attrs, attr)
 }
 
 func (h *Query) parseRequest(request http.Request) (qr *services.QueryRequest, err error) {
@@ -47,6 +167,7 @@ func (h *Query) parseRequest(request http.Request) (qr *services.QueryRequest, e
 
 	switch request.Method() {
 	case "GET":
+		qr.Filter = request.QueryParam(filter)
 		if len(request.QueryParam(sortBy)) > 0 {
 			qr.Sort = &crud.Sort{
 				By:    request.QueryParam(sortBy),
@@ -62,7 +183,22 @@ func (h *Query) parseRequest(request http.Request) (qr *services.QueryRequest, e
 				qr.Projection.ExcludedAttributes = strings.Split(v, space)
 			}
 		}
-		if len(request.QueryParam(startIndex)) > 0 || len(request.QueryParam(count)) > 0 {
+		var c = 0
+		if len(request.QueryParam(count)) > 0 {
+			c, err = strconv.Atoi(request.QueryParam(count))
+			if err != nil {
+				err = errors.InvalidRequest("invalid count parameter")
+				return
+			}
+		}
+		// an empty cursor requests the first page
+		if http.HasQueryParam(request, cursor) {
+			qr.Cursor = &crud.CursorPagination{
+				Cursor: request.QueryParam(cursor),
+				Count:  c,
+			}
+		}
+		if len(request.QueryParam(startIndex)) > 0 || (len(request.QueryParam(count)) > 0 && qr.Cursor == nil) {
 			var i = 1
 			if len(request.QueryParam(startIndex)) > 0 {
 				i, err = strconv.Atoi(request.QueryParam(startIndex))
@@ -71,14 +207,6 @@ func (h *Query) parseRequest(request http.Request) (qr *services.QueryRequest, e
 					return
 				}
 			}
-			var c = 0
-			if len(request.QueryParam(count)) > 0 {
-				c, err = strconv.Atoi(request.QueryParam(count))
-				if err != nil {
-					err = errors.InvalidRequest("invalid count parameter")
-					return
-				}
-			}
 			qr.Pagination = &crud.Pagination{
 				StartIndex: i,
 				Count:      c,
@@ -99,6 +227,7 @@ func (h *Query) parseRequest(request http.Request) (qr *services.QueryRequest, e
 			SortOrder          string   `json:"sortOrder"`
 			StartIndex         int      `json:"startIndex"`
 			Count              int      `json:"count"`
+			Cursor             *string  `json:"cursor"`
 		})
 		if e := json.Unmarshal(raw, wip); e != nil {
 			err = errors.InvalidSyntax("failed to parse search request: %s", e.Error())
@@ -108,6 +237,7 @@ func (h *Query) parseRequest(request http.Request) (qr *services.QueryRequest, e
 			err = errors.InvalidSyntax("invalid schema for search request")
 			return
 		}
+		qr.Filter = wip.Filter
 		if len(wip.SortBy) > 0 {
 			qr.Sort = &crud.Sort{
 				By:    wip.SortBy,
@@ -120,7 +250,13 @@ func (h *Query) parseRequest(request http.Request) (qr *services.QueryRequest, e
 				ExcludedAttributes: wip.ExcludedAttributes,
 			}
 		}
-		if wip.StartIndex > 0 || wip.Count > 0 {
+		if wip.Cursor != nil {
+			qr.Cursor = &crud.CursorPagination{
+				Cursor: *wip.Cursor,
+				Count:  wip.Count,
+			}
+		}
+		if wip.StartIndex > 0 || (wip.Count > 0 && qr.Cursor == nil) {
 			if wip.StartIndex == 0 {
 				wip.StartIndex = 1
 			}
@@ -136,29 +272,91 @@ func (h *Query) parseRequest(request http.Request) (qr *services.QueryRequest, e
 	return
 }
 
-func (h *Query) serializeResponse(request *services.QueryRequest, response *services.QueryResponse) ([]byte, error) {
+func (h *Query) stream(request http.Request, response http.Response, qr *services.QueryRequest, projection *crud.Projection) {
+	qt, iterator, err := h.Service.QueryResourceIterator(request.Context(), qr)
+	if err != nil {
+		WriteError(response, err)
+		return
+	}
+	defer iterator.Close()
+
+	qr.Projection = projection
+	head := struct {
+		Schemas      []string `json:"schemas"`
+		TotalResults int      `json:"totalResults"`
+		StartIndex   *int     `json:"startIndex,omitempty"`
+		NextCursor   string   `json:"nextCursor,omitempty"`
+	}{
+		Schemas:      []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"},
+		TotalResults: qt.TotalResults,
+		NextCursor:   qt.NextCursor,
+	}
+	if qr.Cursor == nil {
+		head.StartIndex = &qt.StartIndex
+	}
+	raw, err := json.Marshal(head)
+	if err != nil {
+		WriteError(response, err)
+		return
+	}
+
+	response.WriteSCIMContentType()
+	response.WriteStatus(200)
+
+	// Resources are opened in place of the closing brace, and itemsPerPage is only known once they are written.
+	response.WriteBody(append(raw[:len(raw)-1], `,"Resources":[`...))
+	n := 0
+	for ; iterator.Next(); n++ {
+		item, err := json.Marshal(h.adapt(request.Context(), qr, iterator.Resource()))
+		if err != nil {
+			h.Log.Error("failed to serialize resource in query response: %s", err.Error())
+			return
+		}
+		if n > 0 {
+			response.WriteBody([]byte{','})
+		}
+		response.WriteBody(item)
+	}
+	if err := iterator.Err(); err != nil {
+		h.Log.Error("failed to iterate resources in query response: %s", err.Error())
+		return
+	}
+	response.WriteBody([]byte(`],"itemsPerPage":` + strconv.Itoa(n) + `}`))
+}
+
+func (h *Query) serializeResponse(ctx context.Context, request *services.QueryRequest, response *services.QueryResponse) ([]byte, error) {
 	wip := struct {
 		Schemas      []string                           `json:"schemas"`
 		TotalResults int                                `json:"totalResults"`
 		ItemsPerPage int                                `json:"itemsPerPage"`
-		StartIndex   int                                `json:"startIndex"`
+		StartIndex   *int                               `json:"startIndex,omitempty"`
+		NextCursor   string                             `json:"nextCursor,omitempty"`
 		Resources    []*scimJSON.ResourceMarshalAdapter `json:"Resources"`
 	}{
 		Schemas:      []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"},
 		TotalResults: response.TotalResults,
 		ItemsPerPage: response.ItemsPerPage,
-		StartIndex:   response.StartIndex,
+		NextCursor:   response.NextCursor,
 		Resources:    make([]*scimJSON.ResourceMarshalAdapter, 0, len(response.Resources)),
 	}
+	// cursor paginated responses have no start index
+	if request.Cursor == nil {
+		wip.StartIndex = &response.StartIndex
+	}
 	for _, r := range response.Resources {
-		adp := &scimJSON.ResourceMarshalAdapter{
-			Resource: r,
-		}
-		if request.Projection != nil {
-			adp.Include = request.Projection.Attributes
-			adp.Exclude = request.Projection.ExcludedAttributes
-		}
-		wip.Resources = append(wip.Resources, adp)
+		wip.Resources = append(wip.Resources, h.adapt(ctx, request, r))
 	}
 	return json.Marshal(wip)
 }
+
+func (h *Query) adapt(ctx context.Context, request *services.QueryRequest, resource *prop.Resource) *scimJSON.ResourceMarshalAdapter {
+	adp := &scimJSON.ResourceMarshalAdapter{
+		Resource: resource,
+		Allow:    readable(ctx, h.Policy, resource),
+	}
+	if request.Projection != nil {
+		adp.Include = request.Projection.Attributes
+		adp.Exclude = request.Projection.ExcludedAttributes
+	}
+	return adp
+}
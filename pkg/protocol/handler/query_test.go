@@ -6,6 +6,7 @@ import (
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/db"
 	"github.com/imulab/go-scim/pkg/protocol/http"
 	"github.com/imulab/go-scim/pkg/protocol/log"
@@ -15,6 +16,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"io/ioutil"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"strings"
 	"testing"
@@ -183,6 +185,47 @@ func (s *QueryHandlerTestSuite) TestQuery() {
 				assert.JSONEq(t, expect, rr.Body.String())
 			},
 		},
+		{
+			name: "query with selective filter",
+			getHandler: func(t *testing.T) *Query {
+				database := db.Memory()
+				for _, f := range []string{
+					"/user_001.json",
+					"/user_002.json",
+					"/user_003.json",
+				} {
+					err := database.Insert(context.Background(), s.mustResource(f, resourceType))
+					require.Nil(t, err)
+				}
+				return &Query{
+					Log: log.None(),
+					Service: &services.QueryService{
+						Logger:                log.None(),
+						Database:              database,
+						ServiceProviderConfig: spc,
+					},
+				}
+			},
+			getRequest: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest(
+					"GET",
+					"/Users?filter=userName+eq+%22user002%22&attributes=userName",
+					nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Result().StatusCode)
+				body := new(struct {
+					TotalResults int `json:"totalResults"`
+					Resources    []struct {
+						UserName string `json:"userName"`
+					} `json:"Resources"`
+				})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+				assert.Equal(t, 1, body.TotalResults)
+				require.Len(t, body.Resources, 1)
+				assert.Equal(t, "user002", body.Resources[0].UserName)
+			},
+		},
 		{
 			name: 	"search with no filter",
 			getHandler: func(t *testing.T) *Query {
@@ -314,16 +357,205 @@ func (s *QueryHandlerTestSuite) TestQuery() {
 				assert.JSONEq(t, expect, rr.Body.String())
 			},
 		},
+		{
+			name: "search with cursor",
+			getHandler: func(t *testing.T) *Query {
+				database := db.Memory()
+				for _, f := range []string{
+					"/user_001.json",
+					"/user_002.json",
+					"/user_003.json",
+				} {
+					err := database.Insert(context.Background(), s.mustResource(f, resourceType))
+					require.Nil(t, err)
+				}
+				return &Query{
+					Log: log.None(),
+					Service: &services.QueryService{
+						Logger:                log.None(),
+						Database:              database,
+						ServiceProviderConfig: spc,
+					},
+				}
+			},
+			getRequest: func(t *testing.T) http.Request {
+				body := strings.NewReader(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:SearchRequest"],
+	"cursor": "",
+	"count": 2,
+	"sortBy": "userName",
+	"attributes": ["userName"]
+}
+`)
+				return http.DefaultRequest(httptest.NewRequest(
+					"POST",
+					"/Users/.search",
+					body), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Result().StatusCode)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, float64(3), body["totalResults"])
+				assert.Equal(t, float64(2), body["itemsPerPage"])
+				assert.NotEmpty(t, body["nextCursor"])
+				assert.NotContains(t, body, "startIndex")
+			},
+		},
+		{
+			name: "query with invalid filter",
+			getHandler: func(t *testing.T) *Query {
+				return &Query{
+					Log: log.None(),
+					Service: &services.QueryService{
+						Logger:                log.None(),
+						Database:              db.Memory(),
+						ServiceProviderConfig: spc,
+					},
+				}
+			},
+			getRequest: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest(
+					"GET",
+					"/Users?filter=userName+foo",
+					nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 400, rr.Result().StatusCode)
+			},
+		},
+		{
+			name: "query with filter on readable attributes",
+			getHandler: func(t *testing.T) *Query {
+				return s.policyQuery(t, resourceType, spc)
+			},
+			getRequest: func(t *testing.T) http.Request {
+				return s.policyRequest("/Users?filter=" + url.QueryEscape(`userName sw "user" and emails[type eq "work"]`) + "&sortBy=name.familyName")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Result().StatusCode)
+			},
+		},
+		{
+			name: "query with filter on unreadable attribute",
+			getHandler: func(t *testing.T) *Query {
+				return s.policyQuery(t, resourceType, spc)
+			},
+			getRequest: func(t *testing.T) http.Request {
+				return s.policyRequest("/Users?filter=" + url.QueryEscape(`userName sw "user" and displayName eq "foo"`))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Result().StatusCode)
+			},
+		},
+		{
+			name: "query with value filter on unreadable attribute",
+			getHandler: func(t *testing.T) *Query {
+				return s.policyQuery(t, resourceType, spc)
+			},
+			getRequest: func(t *testing.T) http.Request {
+				return s.policyRequest("/Users?filter=" + url.QueryEscape(`emails[value ew "@foo.com"]`))
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Result().StatusCode)
+			},
+		},
+		{
+			name: "query with sort on unreadable attribute",
+			getHandler: func(t *testing.T) *Query {
+				return s.policyQuery(t, resourceType, spc)
+			},
+			getRequest: func(t *testing.T) http.Request {
+				return s.policyRequest("/Users?sortBy=displayName")
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 403, rr.Result().StatusCode)
+			},
+		},
 	}
 
 	for _, test := range tests {
-		s.T().Run(test.name, func(t *testing.T) {
-			rr := httptest.NewRecorder()
-			resp := http.DefaultResponse(rr)
-			test.getHandler(t).Handle(test.getRequest(t), resp)
-			test.expect(t, rr)
+		for _, stream := range []bool{false, true} {
+			name := test.name
+			if stream {
+				name += " (stream)"
+			}
+			s.T().Run(name, func(t *testing.T) {
+				rr := httptest.NewRecorder()
+				resp := http.DefaultResponse(rr)
+				h := test.getHandler(t)
+				h.Stream = stream
+				h.Handle(test.getRequest(t), resp)
+				test.expect(t, rr)
+			})
+		}
+	}
+}
+
+func (s *QueryHandlerTestSuite) TestQueryCursor() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	spc := s.mustServiceProviderConfig("/service_provider_config.json")
+
+	database := db.Memory()
+	for _, f := range []string{
+		"/user_001.json",
+		"/user_002.json",
+		"/user_003.json",
+		"/user_004.json",
+		"/user_005.json",
+		"/user_006.json",
+		"/user_007.json",
+		"/user_008.json",
+		"/user_009.json",
+		"/user_010.json",
+	} {
+		err := database.Insert(context.Background(), s.mustResource(f, resourceType))
+		require.Nil(s.T(), err)
+	}
+	handler := &Query{
+		Log: log.None(),
+		Service: &services.QueryService{
+			Logger:                log.None(),
+			Database:              database,
+			ServiceProviderConfig: spc,
+		},
+	}
+
+	userNames := make([]string, 0)
+	cursor := ""
+	for {
+		rr := httptest.NewRecorder()
+		handler.Handle(http.DefaultRequest(httptest.NewRequest(
+			"GET",
+			"/Users?sortBy=userName&sortOrder=descending&count=3&attributes=userName&cursor="+cursor,
+			nil), nil), http.DefaultResponse(rr))
+		require.Equal(s.T(), 200, rr.Result().StatusCode)
+
+		body := new(struct {
+			NextCursor string `json:"nextCursor"`
+			Resources  []struct {
+				UserName string `json:"userName"`
+			} `json:"Resources"`
 		})
+		require.Nil(s.T(), json.Unmarshal(rr.Body.Bytes(), body))
+		for _, r := range body.Resources {
+			userNames = append(userNames, r.UserName)
+		}
+		if len(body.NextCursor) == 0 {
+			break
+		}
+		cursor = body.NextCursor
 	}
+	assert.Equal(s.T(), []string{
+		"user010", "user009", "user008", "user007", "user006",
+		"user005", "user004", "user003", "user002", "user001",
+	}, userNames)
+
+	rr := httptest.NewRecorder()
+	handler.Handle(http.DefaultRequest(httptest.NewRequest("GET", "/Users?cursor=foo", nil), nil), http.DefaultResponse(rr))
+	assert.Equal(s.T(), 400, rr.Result().StatusCode)
 }
 
 func (s *QueryHandlerTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
@@ -383,3 +615,35 @@ func (s *QueryHandlerTestSuite) mustServiceProviderConfig(filePath string) *spec
 
 	return spc
 }
+
+// Returns a query handler whose policy allows any principal to read userName, name, emails.type, and its own resource.
+func (s *QueryHandlerTestSuite) policyQuery(t *testing.T, resourceType *spec.ResourceType, spc *spec.ServiceProviderConfig) *Query {
+	database := db.Memory()
+	for _, f := range []string{
+		"/user_001.json",
+		"/user_002.json",
+	} {
+		require.Nil(t, database.Insert(context.Background(), s.mustResource(f, resourceType)))
+	}
+	return &Query{
+		Log: log.None(),
+		Service: &services.QueryService{
+			Logger:                log.None(),
+			Database:              database,
+			ServiceProviderConfig: spc,
+			ResourceType:          resourceType,
+		},
+		Policy: &auth.Policy{
+			Rules: []*auth.Rule{
+				{Subjects: []string{"*"}, Operations: []auth.Operation{auth.OperationRead}, Paths: []string{"userName", "name", "emails.type"}},
+				{Subjects: []string{"*"}, Self: true, Operations: []auth.Operation{auth.OperationRead}, Paths: []string{"*"}},
+			},
+		},
+	}
+}
+
+func (s *QueryHandlerTestSuite) policyRequest(target string) http.Request {
+	req := httptest.NewRequest("GET", target, nil)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Subject: "user001"}))
+	return http.DefaultRequest(req, nil)
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/http"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/imulab/go-scim/pkg/protocol/services"
@@ -15,17 +16,28 @@ type Replace struct {
 	Service             *services.ReplaceService
 	ResourceIDPathParam string
 	ResourceType        *spec.ResourceType
+	// Optional policy which decides the attributes of the returned resource that the principal may read.
+	Policy *auth.Policy
 }
 
 func (h *Replace) Handle(request http.Request, response http.Response) {
 	var (
-		resourceIDParam string
-		payload         *prop.Resource
+		resourceIDParam         string
+		payload                 *prop.Resource
+		attributesParam         []string
+		excludedAttributesParam []string
 	)
 	{
 		resourceIDParam = request.PathParam(h.ResourceIDPathParam)
 		h.Log.Info("request to replace resource [id=%s]", resourceIDParam)
 
+		var err error
+		attributesParam, excludedAttributesParam, err = parseProjection(request)
+		if err != nil {
+			WriteError(response, err)
+			return
+		}
+
 		raw, err := request.Body()
 		if err != nil {
 			h.Log.Error("failed to read request body for replacing resource [id=%s]: %s", resourceIDParam, err.Error())
@@ -57,15 +69,18 @@ func (h *Replace) Handle(request http.Request, response http.Response) {
 		response.WriteETag(rr.NewVersion)
 		response.WriteStatus(204)
 	} else {
-		raw, err := json.Serialize(rr.Resource, json.Options())
+		raw, err := json.Serialize(rr.Resource, json.Options().
+			Include(attributesParam...).
+			Exclude(excludedAttributesParam...).
+			Allow(readable(request.Context(), h.Policy, rr.Resource)))
 		if err != nil {
 			WriteError(response, err)
 			return
 		}
-		response.WriteBody(raw)
 		response.WriteLocation(rr.Location)
 		response.WriteETag(rr.NewVersion)
 		response.WriteSCIMContentType()
 		response.WriteStatus(200)
+		response.WriteBody(raw)
 	}
 }
@@ -81,6 +81,43 @@ func (s *ReplaceHandlerTestSuite) TestReplace() {
 				assert.NotEmpty(t, rr.Body.String())
 			},
 		},
+		{
+			name: "replace existing resource with excludedAttributes",
+			getHandler: func(t *testing.T) *Replace {
+				database := db.Memory()
+				err := database.Insert(context.Background(), s.mustResource("/user_000.json", resourceType))
+				require.Nil(t, err)
+				return &Replace{
+					Log:                 log.None(),
+					ResourceIDPathParam: "userId",
+					ResourceType:        resourceType,
+					Service: &services.ReplaceService{
+						Logger:   log.None(),
+						Database: database,
+						Filters: []filters.ForResource{
+							filters.ClearReadOnly(),
+							filters.CopyReadOnly(),
+							filters.Password(10),
+							filters.Validation(database),
+							filters.Meta(),
+						},
+						ServiceProviderConfig: spc,
+					},
+				}
+			},
+			getRequest: func(t *testing.T) http.Request {
+				f, err := os.Open(s.resourceBase + "/user_001.json")
+				require.Nil(t, err)
+				return http.DefaultRequest(
+					httptest.NewRequest("POST", "/Users/3cc032f5-2361-417f-9e2f-bc80adddf4a3?excludedAttributes=emails", f),
+					[]string{"/Users/(?P<userId>.*)"})
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Result().StatusCode)
+				assert.Contains(t, rr.Body.String(), "davidiamyou")
+				assert.NotContains(t, rr.Body.String(), "emails")
+			},
+		},
 		{
 			name: "replace non-existing resource",
 			getHandler: func(t *testing.T) *Replace {
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"strings"
+)
+
+// Handler for the /ResourceTypes discovery endpoint. When the resource type path parameter is present, the resource
+// type whose id or name matches the parameter is returned; otherwise, all resource types are returned in a
+// ListResponse, optionally filtered by the 'filter' query parameter and paginated by the 'startIndex' and 'count'
+// query parameters.
+type ResourceTypes struct {
+	Log log.Logger
+	// Resource types served by this server.
+	ResourceTypes []*spec.ResourceType
+	// Name of the path parameter carrying the resource type id or name.
+	ResourceTypePathParam string
+	// Absolute URL of the /ResourceTypes endpoint, used to render meta.location of each resource type. Optional.
+	Location string
+}
+
+func (h *ResourceTypes) Handle(request http.Request, response http.Response) {
+	if len(h.ResourceTypePathParam) > 0 && len(request.PathParam(h.ResourceTypePathParam)) > 0 {
+		h.handleGet(request.PathParam(h.ResourceTypePathParam), response)
+	} else {
+		h.handleList(request, response)
+	}
+}
+
+func (h *ResourceTypes) handleGet(idOrName string, response http.Response) {
+	h.Log.Info("request to get resource type [id=%s]", idOrName)
+
+	var found *spec.ResourceType
+	for _, rt := range h.ResourceTypes {
+		if rt.ID() == idOrName || rt.Name() == idOrName {
+			found = rt
+			break
+		}
+	}
+	if found == nil {
+		WriteError(response, errors.NotFound("resource type by id [%s] is not found", idOrName))
+		return
+	}
+
+	raw, err := h.render(found)
+	if err != nil {
+		WriteError(response, err)
+		return
+	}
+
+	response.WriteSCIMContentType()
+	response.WriteLocation(h.locationOf(found))
+	response.WriteStatus(200)
+	response.WriteBody(raw)
+}
+
+func (h *ResourceTypes) handleList(request http.Request, response http.Response) {
+	h.Log.Info("request to list resource types")
+
+	writeDiscoveryList(request, response, len(h.ResourceTypes), func(i int) (*prop.Resource, error) {
+		return resourceTypeToResource(h.ResourceTypes[i])
+	}, func(i int) (json.RawMessage, error) {
+		return h.render(h.ResourceTypes[i])
+	})
+}
+
+// Render the resource type through its own JSON representation, which includes the schema extensions and their
+// required flags, with the addition of the schemas and meta attributes.
+func (h *ResourceTypes) render(rt *spec.ResourceType) (json.RawMessage, error) {
+	return renderDiscovery(rt, resourceTypeSchemaURN, "ResourceType", h.locationOf(rt))
+}
+
+func (h *ResourceTypes) locationOf(rt *spec.ResourceType) string {
+	if len(h.Location) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(h.Location, "/") + "/" + rt.ID()
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestResourceTypesHandler(t *testing.T) {
+	s := new(ResourceTypesHandlerTestSuite)
+	s.resourceBase = "../../tests/resource_types_handler_test_suite"
+	suite.Run(t, s)
+}
+
+type ResourceTypesHandlerTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *ResourceTypesHandlerTestSuite) TestHandle() {
+	RegisterDiscovery()
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_enterprise_extension_schema.json")
+	_ = s.mustSchema("/group_schema.json")
+
+	handler := &ResourceTypes{
+		Log: log.None(),
+		ResourceTypes: []*spec.ResourceType{
+			s.mustResourceType("/user_resource_type.json"),
+			s.mustResourceType("/group_resource_type.json"),
+		},
+		ResourceTypePathParam: "name",
+		Location:              "https://scim.imulab.io/ResourceTypes",
+	}
+
+	tests := []struct {
+		name   string
+		getReq func(t *testing.T) http.Request
+		expect func(t *testing.T, rr *httptest.ResponseRecorder)
+	}{
+		{
+			name: "get resource type",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(
+					httptest.NewRequest("GET", "/ResourceTypes/User", nil),
+					[]string{"/ResourceTypes/(?P<name>.*)"},
+				)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.Equal(t, "application/json+scim", rr.Result().Header.Get("Content-Type"))
+				assert.Equal(t, "https://scim.imulab.io/ResourceTypes/User", rr.Result().Header.Get("Location"))
+				assert.JSONEq(t, `
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:ResourceType"],
+	"id": "User",
+	"name": "User",
+	"description": "User resource type",
+	"endpoint": "https://scim.imulab.io/Users",
+	"schema": "urn:ietf:params:scim:schemas:core:2.0:User",
+	"schemaExtensions": [
+		{
+			"schema": "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+			"required": false
+		}
+	],
+	"meta": {
+		"resourceType": "ResourceType",
+		"location": "https://scim.imulab.io/ResourceTypes/User"
+	}
+}
+`, rr.Body.String())
+			},
+		},
+		{
+			name: "get unknown resource type",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(
+					httptest.NewRequest("GET", "/ResourceTypes/Foo", nil),
+					[]string{"/ResourceTypes/(?P<name>.*)"},
+				)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 404, rr.Code)
+			},
+		},
+		{
+			name: "list resource types",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET", "/ResourceTypes", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := s.parseListResponse(t, rr)
+				assert.Equal(t, 2, body.TotalResults)
+				assert.Equal(t, "User", body.Resources[0].ID)
+				assert.Equal(t, "https://scim.imulab.io/ResourceTypes/User", body.Resources[0].Meta.Location)
+				assert.Equal(t, "Group", body.Resources[1].ID)
+				assert.Equal(t, "https://scim.imulab.io/ResourceTypes/Group", body.Resources[1].Meta.Location)
+			},
+		},
+		{
+			name: "list resource types by filter on extensions",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET",
+					"/ResourceTypes?filter=schemaExtensions.schema+eq+%22urn:ietf:params:scim:schemas:extension:enterprise:2.0:User%22", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := s.parseListResponse(t, rr)
+				assert.Equal(t, 1, body.TotalResults)
+				assert.Equal(t, "User", body.Resources[0].ID)
+			},
+		},
+		{
+			name: "list resource types with pagination",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET", "/ResourceTypes?startIndex=2&count=10", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := s.parseListResponse(t, rr)
+				assert.Equal(t, 2, body.TotalResults)
+				assert.Equal(t, 2, body.StartIndex)
+				assert.Equal(t, 1, body.ItemsPerPage)
+				assert.Equal(t, "Group", body.Resources[0].ID)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.Handle(test.getReq(t), http.DefaultResponse(rr))
+			test.expect(t, rr)
+		})
+	}
+}
+
+type resourceTypesListResponse struct {
+	TotalResults int `json:"totalResults"`
+	ItemsPerPage int `json:"itemsPerPage"`
+	StartIndex   int `json:"startIndex"`
+	Resources    []struct {
+		ID   string `json:"id"`
+		Meta struct {
+			Location string `json:"location"`
+		} `json:"meta"`
+	} `json:"Resources"`
+}
+
+func (s *ResourceTypesHandlerTestSuite) parseListResponse(t *testing.T, rr *httptest.ResponseRecorder) *resourceTypesListResponse {
+	body := new(resourceTypesListResponse)
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+	return body
+}
+
+func (s *ResourceTypesHandlerTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *ResourceTypesHandlerTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"strings"
+)
+
+// Handler for the /Schemas discovery endpoint. When the schema id path parameter is present, the schema by that id is
+// returned; otherwise, all schemas registered in spec.SchemaHub, except the discovery schemas themselves, are returned in a ListResponse, optionally filtered by
+// the 'filter' query parameter and paginated by the 'startIndex' and 'count' query parameters.
+type Schemas struct {
+	Log log.Logger
+	// Name of the path parameter carrying the schema id.
+	SchemaIDPathParam string
+	// Absolute URL of the /Schemas endpoint, used to render meta.location of each schema. Optional.
+	Location string
+}
+
+func (h *Schemas) Handle(request http.Request, response http.Response) {
+	if len(h.SchemaIDPathParam) > 0 && len(request.PathParam(h.SchemaIDPathParam)) > 0 {
+		h.handleGet(request.PathParam(h.SchemaIDPathParam), response)
+	} else {
+		h.handleList(request, response)
+	}
+}
+
+func (h *Schemas) handleGet(id string, response http.Response) {
+	h.Log.Info("request to get schema [id=%s]", id)
+
+	var found *spec.Schema
+	forEachSchema(func(schema *spec.Schema) {
+		if schema.ID() == id {
+			found = schema
+		}
+	})
+	if found == nil {
+		WriteError(response, errors.NotFound("schema by id [%s] is not found", id))
+		return
+	}
+
+	raw, err := h.render(found)
+	if err != nil {
+		WriteError(response, err)
+		return
+	}
+
+	response.WriteSCIMContentType()
+	response.WriteLocation(h.locationOf(found))
+	response.WriteStatus(200)
+	response.WriteBody(raw)
+}
+
+func (h *Schemas) handleList(request http.Request, response http.Response) {
+	h.Log.Info("request to list schemas")
+
+	all := make([]*spec.Schema, 0)
+	forEachSchema(func(schema *spec.Schema) {
+		all = append(all, schema)
+	})
+
+	writeDiscoveryList(request, response, len(all), func(i int) (*prop.Resource, error) {
+		return schemaToResource(all[i])
+	}, func(i int) (json.RawMessage, error) {
+		return h.render(all[i])
+	})
+}
+
+// Render the schema through its own JSON representation, with the addition of the schemas and meta attributes.
+func (h *Schemas) render(schema *spec.Schema) (json.RawMessage, error) {
+	return renderDiscovery(schema, schemaSchemaURN, "Schema", h.locationOf(schema))
+}
+
+func (h *Schemas) locationOf(schema *spec.Schema) string {
+	if len(h.Location) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(h.Location, "/") + "/" + schema.ID()
+}
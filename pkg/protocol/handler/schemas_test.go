@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/http"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSchemasHandler(t *testing.T) {
+	s := new(SchemasHandlerTestSuite)
+	s.resourceBase = "../../tests/schemas_handler_test_suite"
+	suite.Run(t, s)
+}
+
+type SchemasHandlerTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *SchemasHandlerTestSuite) TestHandle() {
+	RegisterDiscovery()
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_enterprise_extension_schema.json")
+	_ = s.mustSchema("/group_schema.json")
+
+	handler := &Schemas{
+		Log:               log.None(),
+		SchemaIDPathParam: "id",
+		Location:          "https://scim.imulab.io/Schemas",
+	}
+
+	tests := []struct {
+		name   string
+		getReq func(t *testing.T) http.Request
+		expect func(t *testing.T, rr *httptest.ResponseRecorder)
+	}{
+		{
+			name: "get schema by id",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(
+					httptest.NewRequest("GET", "/Schemas/urn:ietf:params:scim:schemas:core:2.0:User", nil),
+					[]string{"/Schemas/(?P<id>.*)"},
+				)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.Equal(t, "application/json+scim", rr.Result().Header.Get("Content-Type"))
+				assert.Equal(t, "https://scim.imulab.io/Schemas/urn:ietf:params:scim:schemas:core:2.0:User", rr.Result().Header.Get("Location"))
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "urn:ietf:params:scim:schemas:core:2.0:User", body["id"])
+				assert.Equal(t, "User", body["name"])
+				assert.NotEmpty(t, body["attributes"])
+				assert.Equal(t, []interface{}{"urn:ietf:params:scim:schemas:core:2.0:Schema"}, body["schemas"])
+				assert.Equal(t, map[string]interface{}{
+					"resourceType": "Schema",
+					"location":     "https://scim.imulab.io/Schemas/urn:ietf:params:scim:schemas:core:2.0:User",
+				}, body["meta"])
+			},
+		},
+		{
+			name: "get unknown schema",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(
+					httptest.NewRequest("GET", "/Schemas/urn:foo", nil),
+					[]string{"/Schemas/(?P<id>.*)"},
+				)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 404, rr.Code)
+			},
+		},
+		{
+			name: "get discovery schema",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(
+					httptest.NewRequest("GET", "/Schemas/urn:ietf:params:scim:schemas:core:2.0:Schema", nil),
+					[]string{"/Schemas/(?P<id>.*)"},
+				)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 404, rr.Code)
+			},
+		},
+		{
+			name: "list schemas",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET", "/Schemas", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.Equal(t, "application/json+scim", rr.Result().Header.Get("Content-Type"))
+				assert.Equal(t, []string{
+					"urn:ietf:params:scim:schemas:core:2.0:Group",
+					"urn:ietf:params:scim:schemas:core:2.0:User",
+					"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User",
+				}, s.listedIDs(t, rr))
+			},
+		},
+		{
+			name: "list schemas by filter on id",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET",
+					"/Schemas?filter=id+eq+%22urn:ietf:params:scim:schemas:core:2.0:User%22", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.Equal(t, []string{"urn:ietf:params:scim:schemas:core:2.0:User"}, s.listedIDs(t, rr))
+			},
+		},
+		{
+			name: "list schemas by filter on attributes",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET",
+					"/Schemas?filter=attributes.name+eq+%22employeeNumber%22", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.Equal(t, []string{"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"}, s.listedIDs(t, rr))
+			},
+		},
+		{
+			name: "list schemas with pagination",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET", "/Schemas?startIndex=2&count=1", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, float64(2), body["startIndex"])
+				assert.Equal(t, float64(1), body["itemsPerPage"])
+				assert.Equal(t, float64(3), body["totalResults"])
+				require.Len(t, body["Resources"], 1)
+				assert.Equal(t, "urn:ietf:params:scim:schemas:core:2.0:User", body["Resources"].([]interface{})[0].(map[string]interface{})["id"])
+			},
+		},
+		{
+			name: "list schemas with invalid filter",
+			getReq: func(t *testing.T) http.Request {
+				return http.DefaultRequest(httptest.NewRequest("GET", "/Schemas?filter=id+eq", nil), nil)
+			},
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 400, rr.Code)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.Handle(test.getReq(t), http.DefaultResponse(rr))
+			test.expect(t, rr)
+		})
+	}
+}
+
+func (s *SchemasHandlerTestSuite) listedIDs(t *testing.T, rr *httptest.ResponseRecorder) []string {
+	body := new(struct {
+		Schemas      []string `json:"schemas"`
+		TotalResults int      `json:"totalResults"`
+		Resources    []struct {
+			ID string `json:"id"`
+		} `json:"Resources"`
+	})
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), body))
+	assert.Equal(t, []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"}, body.Schemas)
+	assert.Equal(t, body.TotalResults, len(body.Resources))
+
+	ids := make([]string, 0, len(body.Resources))
+	for _, r := range body.Resources {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func (s *SchemasHandlerTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
@@ -25,7 +25,7 @@ func (h *ServiceProviderConfig) Handle(_ http.Request, response http.Response) {
 		}
 	}
 
-	response.WriteBody(h.cache)
 	response.WriteSCIMContentType()
 	response.WriteStatus(200)
+	response.WriteBody(h.cache)
 }
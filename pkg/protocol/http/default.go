@@ -2,16 +2,19 @@ package http
 
 import (
 	"context"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"sync"
 )
 
 // Default implementation of Request using Golang's net/http package. Parameter methodAndPattern specifies URL path
 // regex patterns indexed to HTTP method, which is used to parse URL path parameters with the help of regex named
 // matches. For instance, in order to parse 'userId' out of a pattern of '/Users/:userId', one would register a
 // regex pattern of '/Users/(?P<userId>.*)'. This feature is not as easy to use as other router libraries, but serves
-// its purpose as a no-dependency default implementation.
+// its purpose as a no-dependency default implementation. Compiled patterns are cached, and invalid patterns are
+// ignored. For a precompiled alternative, see Router.
 func DefaultRequest(req *http.Request, patterns []string) Request {
 	return &defaultRequest{
 		req:      req,
@@ -45,8 +48,14 @@ func (r *defaultRequest) Header(key string) string {
 
 func (r *defaultRequest) PathParam(param string) string {
 	for _, pattern := range r.patterns {
-		expr := regexp.MustCompile(pattern)
+		expr := compilePattern(pattern)
+		if expr == nil {
+			continue
+		}
 		match := expr.FindStringSubmatch(r.req.URL.Path)
+		if match == nil {
+			continue
+		}
 		for i, n := range expr.SubexpNames() {
 			if n == param {
 				return match[i]
@@ -56,10 +65,30 @@ func (r *defaultRequest) PathParam(param string) string {
 	return ""
 }
 
+// cache of compiled path patterns, invalid patterns are cached as nil.
+var compiledPatterns sync.Map
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if v, ok := compiledPatterns.Load(pattern); ok {
+		return v.(*regexp.Regexp)
+	}
+	expr, err := regexp.Compile(pattern)
+	if err != nil {
+		expr = nil
+	}
+	compiledPatterns.Store(pattern, expr)
+	return expr
+}
+
 func (r *defaultRequest) QueryParam(param string) string {
 	return r.req.URL.Query().Get(param)
 }
 
+func (r *defaultRequest) HasQueryParam(param string) bool {
+	_, ok := r.req.URL.Query()[param]
+	return ok
+}
+
 func (r *defaultRequest) ContentType() string {
 	return r.req.Header.Get(headerContentType)
 }
@@ -71,6 +100,10 @@ func (r *defaultRequest) Body() ([]byte, error) {
 	return ioutil.ReadAll(r.req.Body)
 }
 
+func (r *defaultRequest) BodyReader() io.ReadCloser {
+	return r.req.Body
+}
+
 type defaultResponse struct {
 	rw http.ResponseWriter
 }
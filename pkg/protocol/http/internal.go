@@ -4,5 +4,6 @@ const (
 	headerContentType       = "Content-Type"
 	headerETag              = "ETag"
 	headerLocation          = "Location"
+	headerAllow             = "Allow"
 	applicationJSONPlusSCIM = "application/json+scim"
 )
@@ -1,6 +1,10 @@
 package http
 
-import "context"
+import (
+	"context"
+	"io"
+	"io/ioutil"
+)
 
 // Abstraction of HTTP request, with respect to function related to SCIM.
 type Request interface {
@@ -20,6 +24,83 @@ type Request interface {
 	Body() ([]byte, error)
 }
 
+// Optional interface of Request, implemented by requests which provide the request body as a stream, so that it can
+// be read partially. See ReadBody.
+type BodyReader interface {
+	// Return the reader of the request body. The caller is responsible to close it.
+	BodyReader() io.ReadCloser
+}
+
+// Optional interface of Request, implemented by requests which can tell whether a URL query parameter is present even
+// if its value is empty. See HasQueryParam.
+type QueryParamChecker interface {
+	// Returns true if the URL query parameter of the name is present, even if its value is empty
+	HasQueryParam(param string) bool
+}
+
+// Optional interface of Request, implemented by requests which wrap another request, so that the optional interfaces
+// of the wrapped request remain available.
+type Wrapper interface {
+	// Return the wrapped request.
+	Unwrap() Request
+}
+
+// Read at most limit bytes of the request body, or the entire body if limit is not positive. The body is read
+// partially if the request, or any request it wraps, implements BodyReader; otherwise the entire body is read by Body
+// and then truncated.
+func ReadBody(request Request, limit int) ([]byte, error) {
+	if limit <= 0 {
+		return request.Body()
+	}
+
+	if br, ok := find(request, func(r Request) bool {
+		_, ok := r.(BodyReader)
+		return ok
+	}).(BodyReader); ok {
+		body := br.BodyReader()
+		defer func() {
+			_ = body.Close()
+		}()
+		return ioutil.ReadAll(io.LimitReader(body, int64(limit)))
+	}
+
+	raw, err := request.Body()
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > limit {
+		raw = raw[:limit]
+	}
+	return raw, nil
+}
+
+// Returns true if the URL query parameter of the name is present, even if its value is empty. If neither the request
+// nor any request it wraps implements QueryParamChecker, the parameter is only considered present with a value.
+func HasQueryParam(request Request, param string) bool {
+	if qc, ok := find(request, func(r Request) bool {
+		_, ok := r.(QueryParamChecker)
+		return ok
+	}).(QueryParamChecker); ok {
+		return qc.HasQueryParam(param)
+	}
+	return len(request.QueryParam(param)) > 0
+}
+
+// Returns the request, or the first request it wraps, that matches the criteria, or nil.
+func find(request Request, criteria func(r Request) bool) Request {
+	for request != nil {
+		if criteria(request) {
+			return request
+		}
+		w, ok := request.(Wrapper)
+		if !ok {
+			return nil
+		}
+		request = w.Unwrap()
+	}
+	return nil
+}
+
 // Abstraction of HTTP response, with respect to function related to SCIM.
 type Response interface {
 	// Write the response status
@@ -34,4 +115,4 @@ type Response interface {
 	WriteHeader(k, v string)
 	// Write the given bytes to response body.
 	WriteBody(body []byte)
-}
\ No newline at end of file
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Create a new empty router.
+func NewRouter() *Router {
+	return &Router{root: newRouteNode()}
+}
+
+// Router dispatches net/http requests to SCIM handler functions by HTTP method and URL path. Routes are registered
+// with path templates like '/Users/{id}', where each '{name}' segment matches exactly one non-empty path segment and
+// is made available to the handler via Request.PathParam. Templates are compiled once at registration, so dispatching
+// does not involve any regular expression. Literal segments take precedence over parameter segments, so that
+// '/Users/.search' is preferred over '/Users/{id}' when both match.
+//
+// Requests to unknown paths are responded with a 404 SCIM error; requests to known paths with an unregistered method
+// are responded with a 405 SCIM error and an Allow header.
+//
+// The handler functions share the signature of handler.Func, hence handler.Func values and Handle methods of the
+// handlers can be registered directly.
+type Router struct {
+	root *routeNode
+}
+
+// Register the handler function for the HTTP method and the path template. An error is returned when the template
+// is malformed, or when a handler was already registered for the same method and template.
+func (r *Router) Handle(method string, template string, fn func(request Request, response Response)) error {
+	if len(method) == 0 {
+		return fmt.Errorf("route '%s' has no method", template)
+	}
+	if fn == nil {
+		return fmt.Errorf("route %s '%s' has no handler", method, template)
+	}
+
+	segments, err := compileTemplate(template)
+	if err != nil {
+		return err
+	}
+
+	node := r.root
+	for _, seg := range segments {
+		if seg.param {
+			if node.param == nil {
+				node.param = newRouteNode()
+				node.paramName = seg.value
+			} else if node.paramName != seg.value {
+				return fmt.Errorf("route '%s' declares parameter '%s' where '%s' is already declared",
+					template, seg.value, node.paramName)
+			}
+			node = node.param
+		} else {
+			next, ok := node.literal[seg.value]
+			if !ok {
+				next = newRouteNode()
+				node.literal[seg.value] = next
+			}
+			node = next
+		}
+	}
+
+	method = strings.ToUpper(method)
+	if _, ok := node.handlers[method]; ok {
+		return fmt.Errorf("route %s '%s' is already registered", method, template)
+	}
+	node.handlers[method] = fn
+	return nil
+}
+
+// Dispatch the request to the matching handler function.
+func (r *Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	params := make(map[string]string)
+	node := r.root.match(splitPath(req.URL.Path), params)
+	if node == nil {
+		writeError(rw, errors.NotFound("no endpoint is found at %s", req.URL.Path))
+		return
+	}
+
+	fn, ok := node.handlers[req.Method]
+	if !ok {
+		rw.Header().Set(headerAllow, strings.Join(node.methods(), ", "))
+		writeError(rw, errors.MethodNotAllowed("method %s is not supported at %s", req.Method, req.URL.Path))
+		return
+	}
+
+	fn(&routedRequest{defaultRequest: &defaultRequest{req: req}, params: params}, DefaultResponse(rw))
+}
+
+// Request dispatched by the Router, whose path parameters were resolved during matching.
+type routedRequest struct {
+	*defaultRequest
+	params map[string]string
+}
+
+func (r *routedRequest) PathParam(param string) string {
+	return r.params[param]
+}
+
+type routeSegment struct {
+	value string
+	param bool
+}
+
+// Compile path template into segments. A template must start with '/', and each parameter segment must be in the
+// form of '{name}' and occupy the entire segment.
+func compileTemplate(template string) ([]routeSegment, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("route '%s' must start with '/'", template)
+	}
+
+	names := make(map[string]struct{})
+	segments := make([]routeSegment, 0)
+	for _, s := range splitPath(template) {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			name := s[1 : len(s)-1]
+			if len(name) == 0 || strings.ContainsAny(name, "{}") {
+				return nil, fmt.Errorf("route '%s' has invalid parameter segment '%s'", template, s)
+			}
+			if _, ok := names[name]; ok {
+				return nil, fmt.Errorf("route '%s' declares parameter '%s' more than once", template, name)
+			}
+			names[name] = struct{}{}
+			segments = append(segments, routeSegment{value: name, param: true})
+		} else if strings.ContainsAny(s, "{}") {
+			return nil, fmt.Errorf("route '%s' has invalid segment '%s'", template, s)
+		} else {
+			segments = append(segments, routeSegment{value: s})
+		}
+	}
+	return segments, nil
+}
+
+// Split the URL path into non-empty segments. Leading and trailing slashes are ignored, so '/' results in no segments.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if len(path) == 0 {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{
+		literal:  make(map[string]*routeNode),
+		handlers: make(map[string]func(request Request, response Response)),
+	}
+}
+
+type routeNode struct {
+	literal   map[string]*routeNode
+	param     *routeNode
+	paramName string
+	handlers  map[string]func(request Request, response Response)
+}
+
+// Find the node which matches the remaining segments and has at least one handler. Literal children are attempted
+// before the parameter child. Matched parameters are collected into params.
+func (n *routeNode) match(segments []string, params map[string]string) *routeNode {
+	if len(segments) == 0 {
+		if len(n.handlers) == 0 {
+			return nil
+		}
+		return n
+	}
+
+	if next, ok := n.literal[segments[0]]; ok {
+		if found := next.match(segments[1:], params); found != nil {
+			return found
+		}
+	}
+
+	if n.param != nil {
+		if found := n.param.match(segments[1:], params); found != nil {
+			params[n.paramName] = segments[0]
+			return found
+		}
+	}
+
+	return nil
+}
+
+func (n *routeNode) methods() []string {
+	methods := make([]string, 0, len(n.handlers))
+	for m := range n.handlers {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Write the SCIM error to the response. This mirrors handler.WriteError, which cannot be used here as the handler
+// package depends on this package.
+func writeError(rw http.ResponseWriter, err error) {
+	scimError, ok := err.(*errors.Error)
+	if !ok {
+		scimError = errors.Internal(err.Error()).(*errors.Error)
+	}
+	raw, _ := json.Marshal(scimError)
+	rw.Header().Set(headerContentType, applicationJSONPlusSCIM)
+	rw.WriteHeader(scimError.Status)
+	_, _ = rw.Write(raw)
+}
@@ -0,0 +1,229 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	s := new(RouterTestSuite)
+	suite.Run(t, s)
+}
+
+type RouterTestSuite struct {
+	suite.Suite
+}
+
+func (s *RouterTestSuite) TestHandle() {
+	tests := []struct {
+		name     string
+		method   string
+		template string
+		expect   func(t *testing.T, err error)
+	}{
+		{
+			name:     "template without leading slash",
+			method:   http.MethodGet,
+			template: "Users",
+			expect: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:     "template with empty parameter",
+			method:   http.MethodGet,
+			template: "/Users/{}",
+			expect: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:     "template with partial parameter segment",
+			method:   http.MethodGet,
+			template: "/Users/id-{id}",
+			expect: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:     "template with duplicate parameter",
+			method:   http.MethodGet,
+			template: "/Users/{id}/{id}",
+			expect: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:     "template with conflicting parameter name",
+			method:   http.MethodGet,
+			template: "/Users/{userId}",
+			expect: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:     "duplicate route",
+			method:   http.MethodGet,
+			template: "/Users/{id}",
+			expect: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:     "new method on existing template",
+			method:   http.MethodDelete,
+			template: "/Users/{id}",
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			router := NewRouter()
+			require.Nil(t, router.Handle(http.MethodGet, "/Users/{id}", func(request Request, response Response) {}))
+			test.expect(t, router.Handle(test.method, test.template, func(request Request, response Response) {}))
+		})
+	}
+}
+
+func (s *RouterTestSuite) TestServeHTTP() {
+	router := NewRouter()
+	for _, route := range []struct {
+		method   string
+		template string
+		name     string
+	}{
+		{method: http.MethodGet, template: "/", name: "root"},
+		{method: http.MethodGet, template: "/Users", name: "query"},
+		{method: http.MethodPost, template: "/Users", name: "create"},
+		{method: http.MethodPost, template: "/Users/.search", name: "search"},
+		{method: http.MethodGet, template: "/Users/{id}", name: "get"},
+		{method: http.MethodDelete, template: "/Users/{id}", name: "delete"},
+		{method: http.MethodGet, template: "/Groups/{id}/members/{memberId}", name: "member"},
+	} {
+		name := route.name
+		s.Require().Nil(router.Handle(route.method, route.template, func(request Request, response Response) {
+			raw, _ := json.Marshal(map[string]string{
+				"route":    name,
+				"id":       request.PathParam("id"),
+				"memberId": request.PathParam("memberId"),
+			})
+			response.WriteStatus(200)
+			response.WriteBody(raw)
+		}))
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		expect func(t *testing.T, rr *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "root",
+			method: http.MethodGet,
+			path:   "/",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.JSONEq(t, `{"route":"root","id":"","memberId":""}`, rr.Body.String())
+			},
+		},
+		{
+			name:   "collection with method",
+			method: http.MethodPost,
+			path:   "/Users",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.JSONEq(t, `{"route":"create","id":"","memberId":""}`, rr.Body.String())
+			},
+		},
+		{
+			name:   "trailing slash",
+			method: http.MethodGet,
+			path:   "/Users/",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.JSONEq(t, `{"route":"query","id":"","memberId":""}`, rr.Body.String())
+			},
+		},
+		{
+			name:   "literal segment takes precedence",
+			method: http.MethodPost,
+			path:   "/Users/.search",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.JSONEq(t, `{"route":"search","id":"","memberId":""}`, rr.Body.String())
+			},
+		},
+		{
+			name:   "parameter segment",
+			method: http.MethodGet,
+			path:   "/Users/a5866759-32ca-4e2a-9808-a0fe74f94b18",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.JSONEq(t, `{"route":"get","id":"a5866759-32ca-4e2a-9808-a0fe74f94b18","memberId":""}`, rr.Body.String())
+			},
+		},
+		{
+			name:   "falls back to parameter segment when literal does not match",
+			method: http.MethodGet,
+			path:   "/Users/.search",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 405, rr.Code)
+				assert.Equal(t, "POST", rr.Header().Get("Allow"))
+			},
+		},
+		{
+			name:   "multiple parameter segments",
+			method: http.MethodGet,
+			path:   "/Groups/g1/members/u1",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 200, rr.Code)
+				assert.JSONEq(t, `{"route":"member","id":"g1","memberId":"u1"}`, rr.Body.String())
+			},
+		},
+		{
+			name:   "unknown path",
+			method: http.MethodGet,
+			path:   "/Users/a5866759-32ca-4e2a-9808-a0fe74f94b18/foo",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 404, rr.Code)
+				assert.Equal(t, "application/json+scim", rr.Header().Get("Content-Type"))
+				assert.JSONEq(t, `{
+					"schemas": ["urn:ietf:params:scim:api:messages:2.0:Error"],
+					"status": "404",
+					"scimType": "notFound",
+					"detail": "no endpoint is found at /Users/a5866759-32ca-4e2a-9808-a0fe74f94b18/foo"
+				}`, rr.Body.String())
+			},
+		},
+		{
+			name:   "unsupported method",
+			method: http.MethodPut,
+			path:   "/Users/foo",
+			expect: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				assert.Equal(t, 405, rr.Code)
+				assert.Equal(t, "DELETE, GET", rr.Header().Get("Allow"))
+				body := make(map[string]interface{})
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), &body))
+				assert.Equal(t, "405", body["status"])
+				assert.Equal(t, "methodNotAllowed", body["scimType"])
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(test.method, test.path, nil))
+			test.expect(t, rr)
+		})
+	}
+}
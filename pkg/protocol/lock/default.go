@@ -0,0 +1,86 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Return the in-process implementation of Lock, which only serializes operations within the same process. Lock waits
+// at most the timeout for the lock to be released, or indefinitely if the timeout is not positive, unless the context
+// is done first.
+func Default(timeout time.Duration) Lock {
+	return &defaultLock{
+		timeout: timeout,
+		entries: make(map[string]*entry),
+	}
+}
+
+type (
+	defaultLock struct {
+		mu      sync.Mutex
+		timeout time.Duration
+		entries map[string]*entry
+	}
+	// Lock on a single resource id. The channel holds a value while the lock is held. The entry is removed once no
+	// goroutine holds or waits for the lock, as counted by refs.
+	entry struct {
+		held chan struct{}
+		refs int
+	}
+)
+
+func (l *defaultLock) Lock(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, l.timeout)
+	defer cancel()
+
+	e := l.acquire(id)
+	select {
+	case e.held <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		l.release(id)
+		return ctx.Err()
+	}
+}
+
+func (l *defaultLock) Unlock(id string) {
+	l.mu.Lock()
+	e, ok := l.entries[id]
+	l.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case <-e.held:
+		l.release(id)
+	default:
+	}
+}
+
+// Return the entry of the id, creating it if necessary, and count the caller as its user.
+func (l *defaultLock) acquire(id string) *entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[id]
+	if !ok {
+		e = &entry{held: make(chan struct{}, 1)}
+		l.entries[id] = e
+	}
+	e.refs++
+	return e
+}
+
+// Stop counting the caller as user of the entry of the id, removing the entry if it has no more users.
+func (l *defaultLock) release(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.entries[id]; ok {
+		if e.refs--; e.refs == 0 {
+			delete(l.entries, id)
+		}
+	}
+}
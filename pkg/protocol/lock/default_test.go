@@ -0,0 +1,214 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDefaultLock(t *testing.T) {
+	s := new(DefaultLockTestSuite)
+	s.resourceBase = "../../tests/default_lock_test_suite"
+	s.newLock = func(t *testing.T, timeout time.Duration) Lock {
+		return Default(timeout)
+	}
+	suite.Run(t, s)
+}
+
+func TestFileLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scim-file-lock")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	s := new(DefaultLockTestSuite)
+	s.resourceBase = "../../tests/default_lock_test_suite"
+	s.newLock = func(t *testing.T, timeout time.Duration) Lock {
+		// every lock is a distinct instance, as in separate processes, sharing the same directory
+		l, err := File(dir, timeout)
+		require.Nil(t, err)
+		return l
+	}
+	suite.Run(t, s)
+}
+
+// Test suite shared by the lock implementations. Locks returned by newLock within the same test share their state.
+type DefaultLockTestSuite struct {
+	suite.Suite
+	resourceBase string
+	newLock      func(t *testing.T, timeout time.Duration) Lock
+}
+
+func (s *DefaultLockTestSuite) TestLock() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	tests := []struct {
+		name   string
+		run    func(t *testing.T, first Lock, second Lock, id string) error
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name: "lock free resource",
+			run: func(t *testing.T, first Lock, second Lock, id string) error {
+				return second.Lock(context.Background(), id)
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "lock held resource times out",
+			run: func(t *testing.T, first Lock, second Lock, id string) error {
+				require.Nil(t, first.Lock(context.Background(), id))
+				return second.Lock(context.Background(), id)
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Equal(t, context.DeadlineExceeded, err)
+			},
+		},
+		{
+			name: "lock held resource is cancelled",
+			run: func(t *testing.T, first Lock, second Lock, id string) error {
+				require.Nil(t, first.Lock(context.Background(), id))
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return second.Lock(ctx, id)
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Equal(t, context.Canceled, err)
+			},
+		},
+		{
+			name: "lock released resource",
+			run: func(t *testing.T, first Lock, second Lock, id string) error {
+				require.Nil(t, first.Lock(context.Background(), id))
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					first.Unlock(id)
+				}()
+				return second.Lock(context.Background(), id)
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "lock another resource",
+			run: func(t *testing.T, first Lock, second Lock, id string) error {
+				require.Nil(t, first.Lock(context.Background(), "another"))
+				return second.Lock(context.Background(), id)
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			resource := s.mustResource(resourceType, test.name)
+			first := s.newLock(t, 100*time.Millisecond)
+			second := s.newLock(t, 100*time.Millisecond)
+			if _, ok := first.(*defaultLock); ok {
+				second = first
+			}
+
+			err := test.run(t, first, second, resource.ID())
+			test.expect(t, err)
+
+			first.Unlock(resource.ID())
+			first.Unlock("another")
+			second.Unlock(resource.ID())
+		})
+	}
+}
+
+// Exercise the lock with concurrent read-modify-write cycles. Run with the race detector, i.e. go test -race, to
+// detect unsynchronized access.
+func (s *DefaultLockTestSuite) TestConcurrency() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	const (
+		workers    = 8
+		iterations = 20
+	)
+
+	var (
+		l        = s.newLock(s.T(), 0)
+		resource = s.mustResource(resourceType, "concurrency")
+		counter  = 0
+		wg       sync.WaitGroup
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				if err := l.Lock(context.Background(), resource.ID()); err != nil {
+					assert.Nil(s.T(), err)
+					return
+				}
+				n := counter
+				time.Sleep(time.Microsecond)
+				counter = n + 1
+				l.Unlock(resource.ID())
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(s.T(), workers*iterations, counter)
+	if d, ok := l.(*defaultLock); ok {
+		assert.Empty(s.T(), d.entries)
+	}
+}
+
+func (s *DefaultLockTestSuite) mustResource(resourceType *spec.ResourceType, id string) *prop.Resource {
+	resource := prop.NewResource(resourceType)
+	s.Require().Nil(resource.Replace(map[string]interface{}{
+		"id":       id,
+		"userName": id,
+	}))
+	return resource
+}
+
+func (s *DefaultLockTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *DefaultLockTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
@@ -0,0 +1,92 @@
+//go:build !windows
+// +build !windows
+
+package lock
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Interval between attempts to acquire a file lock held by another holder.
+const filePollInterval = 10 * time.Millisecond
+
+// Return the implementation of Lock based on advisory file locks in the directory, which is created if it does not
+// exist, so that operations are serialized across processes sharing the directory. Every resource id is locked with
+// flock(2) on its own file, which is released by the operating system should the holding process exit. Lock waits at
+// most the timeout for the lock to be released, or indefinitely if the timeout is not positive, unless the context is
+// done first.
+func File(dir string, timeout time.Duration) (Lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileLock{
+		dir:     dir,
+		timeout: timeout,
+		held:    make(map[string]*os.File),
+	}, nil
+}
+
+type fileLock struct {
+	mu      sync.Mutex
+	dir     string
+	timeout time.Duration
+	// files whose locks are held by this instance, by resource id
+	held map[string]*os.File
+}
+
+func (l *fileLock) Lock(ctx context.Context, id string) error {
+	ctx, cancel := withTimeout(ctx, l.timeout)
+	defer cancel()
+
+	// Resource ids are not necessarily valid file names.
+	name := filepath.Join(l.dir, base64.RawURLEncoding.EncodeToString([]byte(id))+".lock")
+
+	ticker := time.NewTicker(filePollInterval)
+	defer ticker.Stop()
+
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return err
+		}
+
+		// Locks are owned by open files, so that the lock is exclusive among goroutines of this process as well.
+		err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			l.mu.Lock()
+			l.held[id] = f
+			l.mu.Unlock()
+			return nil
+		}
+		_ = f.Close()
+		if err != syscall.EWOULDBLOCK {
+			return err
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *fileLock) Unlock(id string) {
+	l.mu.Lock()
+	f, ok := l.held[id]
+	delete(l.held, id)
+	l.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	// Closing the file releases the lock, even if unlocking failed.
+	_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	_ = f.Close()
+}
@@ -0,0 +1,11 @@
+package lock
+
+import (
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"time"
+)
+
+// File locks are not supported on Windows.
+func File(dir string, timeout time.Duration) (Lock, error) {
+	return nil, errors.NotImplemented("file lock is not supported on windows")
+}
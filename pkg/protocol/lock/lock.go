@@ -0,0 +1,25 @@
+package lock
+
+import (
+	"context"
+	"time"
+)
+
+// Lock on resources, identified by their ids, which serializes the read-modify-write cycles of concurrent operations
+// on the same resource.
+type Lock interface {
+	// Acquire the lock on the resource id, waiting for it to be released by its current holder. Returns
+	// context.DeadlineExceeded if the lock was not acquired within the timeout of the implementation, or the error of
+	// the context if it is done before the lock is acquired.
+	Lock(ctx context.Context, id string) error
+	// Release the lock on the resource id, which must have been acquired by Lock.
+	Unlock(id string)
+}
+
+// Derive a context which is done after the timeout, if the timeout is positive.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,439 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"net/url"
+	"strings"
+)
+
+const (
+	BulkRequestSchema  = "urn:ietf:params:scim:api:messages:2.0:BulkRequest"
+	BulkResponseSchema = "urn:ietf:params:scim:api:messages:2.0:BulkResponse"
+
+	// Prefix of a bulkId reference, as in 'bulkId:qwerty'.
+	bulkIdPrefix = "bulkId:"
+)
+
+type (
+	BulkRequest struct {
+		Schemas      []string        `json:"schemas"`
+		FailOnErrors int             `json:"failOnErrors"`
+		Operations   []BulkOperation `json:"Operations"`
+		// Size of the request payload in bytes, to be checked against the maxPayloadSize limit. Set by the caller.
+		PayloadSize int `json:"-"`
+	}
+	BulkOperation struct {
+		Method  string          `json:"method"`
+		BulkID  string          `json:"bulkId"`
+		Version string          `json:"version"`
+		Path    string          `json:"path"`
+		Data    json.RawMessage `json:"data"`
+	}
+	BulkResponse struct {
+		Operations []*BulkOperationResponse
+	}
+	BulkOperationResponse struct {
+		Method   string
+		BulkID   string
+		Version  string
+		Location string
+		Status   int
+		// Error that failed the operation, or nil if the operation succeeded.
+		Error error
+	}
+	// Services of a resource type which bulk operations are dispatched to. The path of the resource type endpoint is
+	// matched against the path of bulk operations. Services for unsupported methods may be left as nil.
+	BulkEndpoint struct {
+		ResourceType *spec.ResourceType
+		Create       *CreateService
+		Replace      *ReplaceService
+		Patch        *PatchService
+		Delete       *DeleteService
+	}
+	BulkService struct {
+		Logger                log.Logger
+		Endpoints             []*BulkEndpoint
+		ServiceProviderConfig *spec.ServiceProviderConfig
+	}
+)
+
+func (s *BulkService) checkSupport(request *BulkRequest) error {
+	if !s.ServiceProviderConfig.Bulk.Supported {
+		return errors.InvalidRequest("bulk is not supported")
+	}
+	if s.ServiceProviderConfig.Bulk.MaxOp > 0 && len(request.Operations) > s.ServiceProviderConfig.Bulk.MaxOp {
+		return errors.TooLarge("the number of operations exceeds the maxOperations (%d)", s.ServiceProviderConfig.Bulk.MaxOp)
+	}
+	if s.ServiceProviderConfig.Bulk.MaxPayload > 0 && request.PayloadSize > s.ServiceProviderConfig.Bulk.MaxPayload {
+		return errors.TooLarge("the size of the bulk operation exceeds the maxPayloadSize (%d)", s.ServiceProviderConfig.Bulk.MaxPayload)
+	}
+	return nil
+}
+
+// Process the bulk operations in the order they appear in the request. Operations may refer to resources created by
+// earlier operations in the same request with 'bulkId:<bulkId>', both in their path and in their data. Once the number
+// of failed operations reaches failOnErrors, the remaining operations are not processed. Errors returned by this
+// method concern the bulk request as a whole, errors of individual operations are reported in the response.
+func (s *BulkService) ProcessBulk(ctx context.Context, request *BulkRequest) (*BulkResponse, error) {
+	s.Logger.Debug("received bulk request with %d operations", len(request.Operations))
+
+	if err := s.checkSupport(request); err != nil {
+		return nil, err
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		resp     = &BulkResponse{Operations: make([]*BulkOperationResponse, 0, len(request.Operations))}
+		resolved = make(map[string]string)
+		failures = 0
+	)
+	for _, op := range request.Operations {
+		if request.FailOnErrors > 0 && failures >= request.FailOnErrors {
+			s.Logger.Info("bulk request stopped after %d errors", failures)
+			break
+		}
+
+		opResp := s.processOperation(ctx, op, resolved)
+		if opResp.Error != nil {
+			s.Logger.Error("bulk operation [method=%s, path=%s] failed: %s", op.Method, op.Path, opResp.Error.Error())
+			failures++
+		}
+		resp.Operations = append(resp.Operations, opResp)
+	}
+
+	return resp, nil
+}
+
+func (s *BulkService) processOperation(ctx context.Context, op BulkOperation, resolved map[string]string) *BulkOperationResponse {
+	opResp := &BulkOperationResponse{
+		Method: strings.ToUpper(op.Method),
+		BulkID: op.BulkID,
+	}
+
+	endpoint, resourceID, err := s.resolvePath(op.Path, resolved)
+	if err != nil {
+		opResp.Error = err
+		return opResp
+	}
+
+	matchCriteria := func(resource *prop.Resource) bool {
+		return len(op.Version) == 0 || resource.Version() == op.Version
+	}
+
+	switch opResp.Method {
+	case "POST":
+		if endpoint.Create == nil || len(resourceID) > 0 {
+			opResp.Error = errors.InvalidRequest("POST is not supported at path '%s'", op.Path)
+			return opResp
+		}
+		data, err := resolveBulkIdReferences(op.Data, endpoint.ResourceType.SuperAttribute(true), false, resolved)
+		if err != nil {
+			opResp.Error = err
+			return opResp
+		}
+		payload := prop.NewResource(endpoint.ResourceType)
+		if err := scimJSON.Deserialize(data, payload); err != nil {
+			opResp.Error = err
+			return opResp
+		}
+		cr, err := endpoint.Create.CreateResource(ctx, &CreateRequest{Payload: payload})
+		if err != nil {
+			opResp.Error = err
+			return opResp
+		}
+		resolved[op.BulkID] = cr.Resource.ID()
+		opResp.Status = 201
+		opResp.Location = cr.Location
+		opResp.Version = cr.Version
+
+	case "PUT":
+		if endpoint.Replace == nil || len(resourceID) == 0 {
+			opResp.Error = errors.InvalidRequest("PUT is not supported at path '%s'", op.Path)
+			return opResp
+		}
+		data, err := resolveBulkIdReferences(op.Data, endpoint.ResourceType.SuperAttribute(true), false, resolved)
+		if err != nil {
+			opResp.Error = err
+			return opResp
+		}
+		payload := prop.NewResource(endpoint.ResourceType)
+		if err := scimJSON.Deserialize(data, payload); err != nil {
+			opResp.Error = err
+			return opResp
+		}
+		rr, err := endpoint.Replace.ReplaceResource(ctx, &ReplaceRequest{
+			ResourceID:    resourceID,
+			Payload:       payload,
+			MatchCriteria: matchCriteria,
+		})
+		if err != nil {
+			opResp.Error = err
+			return opResp
+		}
+		opResp.Status = 200
+		opResp.Location = rr.Location
+		opResp.Version = rr.NewVersion
+
+	case "PATCH":
+		if endpoint.Patch == nil || len(resourceID) == 0 {
+			opResp.Error = errors.InvalidRequest("PATCH is not supported at path '%s'", op.Path)
+			return opResp
+		}
+		payload := new(PatchRequest)
+		if err := json.Unmarshal(op.Data, payload); err != nil {
+			opResp.Error = errors.InvalidSyntax("failed to parse patch operation data: %s", err.Error())
+			return opResp
+		}
+		for i, patchOp := range payload.Operations {
+			attr, reference := patchPathAttribute(endpoint.ResourceType, patchOp.Path)
+			if attr == nil {
+				continue
+			}
+			if payload.Operations[i].Value, err = resolveBulkIdReferences(patchOp.Value, attr, reference, resolved); err != nil {
+				opResp.Error = err
+				return opResp
+			}
+		}
+		if len(payload.Schemas) == 0 {
+			payload.Schemas = []string{PatchOpSchema}
+		}
+		payload.ResourceID = resourceID
+		payload.MatchCriteria = matchCriteria
+		pr, err := endpoint.Patch.PatchResource(ctx, payload)
+		if err != nil {
+			opResp.Error = err
+			return opResp
+		}
+		opResp.Status = 200
+		if pr.NewVersion == pr.OldVersion {
+			opResp.Status = 204
+		}
+		opResp.Location = pr.Location
+		opResp.Version = pr.NewVersion
+
+	case "DELETE":
+		if endpoint.Delete == nil || len(resourceID) == 0 {
+			opResp.Error = errors.InvalidRequest("DELETE is not supported at path '%s'", op.Path)
+			return opResp
+		}
+		if err := endpoint.Delete.DeleteResource(ctx, &DeleteRequest{
+			ResourceID:    resourceID,
+			MatchCriteria: matchCriteria,
+		}); err != nil {
+			opResp.Error = err
+			return opResp
+		}
+		opResp.Status = 204
+		opResp.Location = strings.TrimSuffix(endpoint.ResourceType.Endpoint(), "/") + "/" + resourceID
+	}
+
+	return opResp
+}
+
+// Resolve the bulk operation path, in the form of '/<endpoint>' or '/<endpoint>/<id>', to the endpoint it targets and
+// the optional resource id. A resource id in the form of 'bulkId:<bulkId>' is resolved to the id of the resource created
+// by the operation with that bulkId.
+func (s *BulkService) resolvePath(path string, resolved map[string]string) (*BulkEndpoint, string, error) {
+	path = strings.TrimSuffix(path, "/")
+	for _, endpoint := range s.Endpoints {
+		base := endpointPath(endpoint.ResourceType)
+		if len(base) == 0 {
+			continue
+		}
+		if path == base {
+			return endpoint, "", nil
+		}
+		if !strings.HasPrefix(path, base+"/") {
+			continue
+		}
+		resourceID := strings.TrimPrefix(path, base+"/")
+		if len(resourceID) == 0 || strings.Contains(resourceID, "/") {
+			break
+		}
+		if strings.HasPrefix(resourceID, bulkIdPrefix) {
+			id, ok := resolved[strings.TrimPrefix(resourceID, bulkIdPrefix)]
+			if !ok {
+				return nil, "", errors.InvalidValue("'%s' in path does not refer to a resource created earlier in the request", resourceID)
+			}
+			resourceID = id
+		}
+		return endpoint, resourceID, nil
+	}
+	return nil, "", errors.InvalidPath("path '%s' does not match any endpoint", path)
+}
+
+// Returns the path of the resource type endpoint, which may be defined as either an absolute URL or a path.
+func endpointPath(resourceType *spec.ResourceType) string {
+	u, err := url.Parse(resourceType.Endpoint())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(u.Path, "/")
+}
+
+// Replace the "bulkId:<bulkId>" values in the data, which is the JSON value of the attribute, with the id of the
+// resource created by the operation with that bulkId. The data is walked along the attribute, so only the values of
+// reference attributes are replaced, and other strings, such as a displayName, are left as is. The reference argument
+// tells whether the attribute itself is a reference. It is an error to refer to an unknown bulkId.
+func resolveBulkIdReferences(data json.RawMessage, attr *spec.Attribute, reference bool, resolved map[string]string) (json.RawMessage, error) {
+	if !bytes.Contains(data, []byte(bulkIdPrefix)) {
+		return data, nil
+	}
+
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		// leave it to the service to report the syntax error
+		return data, nil
+	}
+
+	replaced := false
+	v, err := resolveBulkIdValue(v, attr, reference, resolved, &replaced)
+	if err != nil {
+		return nil, err
+	} else if !replaced {
+		return data, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Internal("failed to serialize bulk operation data: %s", err.Error())
+	}
+	return raw, nil
+}
+
+func resolveBulkIdValue(v interface{}, attr *spec.Attribute, reference bool, resolved map[string]string, replaced *bool) (interface{}, error) {
+	switch value := v.(type) {
+	case string:
+		if !reference || !strings.HasPrefix(value, bulkIdPrefix) {
+			return value, nil
+		}
+		bulkId := strings.TrimPrefix(value, bulkIdPrefix)
+		id, ok := resolved[bulkId]
+		if !ok {
+			return nil, errors.InvalidValue("'%s%s' does not refer to a resource created earlier in the request", bulkIdPrefix, bulkId)
+		}
+		*replaced = true
+		return id, nil
+	case []interface{}:
+		if !attr.MultiValued() {
+			return value, nil
+		}
+		for i, elem := range value {
+			var err error
+			if value[i], err = resolveBulkIdValue(elem, attr, reference, resolved, replaced); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	case map[string]interface{}:
+		if attr.Type() != spec.TypeComplex {
+			return value, nil
+		}
+		for k, elem := range value {
+			subAttr := attr.SubAttributeForName(k)
+			if subAttr == nil {
+				continue
+			}
+			var err error
+			if value[k], err = resolveBulkIdValue(elem, subAttr, isBulkIdReference(subAttr, attr), resolved, replaced); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	default:
+		return value, nil
+	}
+}
+
+// Returns true if values of the attribute may be bulkId references: reference attributes, and the 'value' sub
+// attribute of complex attributes which refer to another resource by '$ref' (i.e. members.value).
+func isBulkIdReference(attr *spec.Attribute, container *spec.Attribute) bool {
+	if attr.Type() == spec.TypeReference {
+		return true
+	}
+	return attr.Type() == spec.TypeString &&
+		strings.ToLower(attr.Name()) == "value" &&
+		container.SubAttributeForName("$ref") != nil
+}
+
+// Returns the attribute targeted by the patch operation path, and whether its values may be bulkId references. The
+// value filters in the path do not change the target attribute. Returns nil if the path does not resolve.
+func patchPathAttribute(resourceType *spec.ResourceType, path string) (*spec.Attribute, bool) {
+	attr := resourceType.SuperAttribute(true)
+	if len(path) == 0 {
+		return attr, false
+	}
+
+	head, err := expr.CompilePath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	if strings.ToLower(head.Token()) == strings.ToLower(resourceType.Schema().ID()) {
+		head = head.Next()
+	}
+
+	reference := false
+	for step := head; step != nil; step = step.Next() {
+		if step.IsRootOfFilter() {
+			continue
+		}
+		subAttr := attr.SubAttributeForName(step.Token())
+		if subAttr == nil {
+			return nil, false
+		}
+		reference = isBulkIdReference(subAttr, attr)
+		attr = subAttr
+	}
+	return attr, reference
+}
+
+func (br *BulkRequest) Validate() error {
+	if len(br.Schemas) != 1 || br.Schemas[0] != BulkRequestSchema {
+		return errors.InvalidSyntax("bulk request must describe payload with schema '%s'", BulkRequestSchema)
+	}
+	if br.FailOnErrors < 0 {
+		return errors.InvalidValue("failOnErrors must not be negative")
+	}
+
+	bulkIds := make(map[string]struct{})
+	for _, each := range br.Operations {
+		switch strings.ToUpper(each.Method) {
+		case "POST":
+			if len(each.BulkID) == 0 {
+				return errors.InvalidSyntax("bulkId is required for POST operation")
+			}
+			if _, ok := bulkIds[each.BulkID]; ok {
+				return errors.InvalidSyntax("bulkId '%s' is not unique", each.BulkID)
+			}
+			bulkIds[each.BulkID] = struct{}{}
+			if len(each.Data) == 0 {
+				return errors.InvalidSyntax("data is required for POST operation")
+			}
+		case "PUT", "PATCH":
+			if len(each.Data) == 0 {
+				return errors.InvalidSyntax("data is required for %s operation", strings.ToUpper(each.Method))
+			}
+		case "DELETE":
+		default:
+			return errors.InvalidSyntax("'%s' is not a valid bulk operation method", each.Method)
+		}
+		if len(each.Path) == 0 {
+			return errors.InvalidSyntax("path is required for bulk operation")
+		}
+	}
+
+	return nil
+}
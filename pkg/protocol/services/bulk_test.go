@@ -0,0 +1,462 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/log"
+	"github.com/imulab/go-scim/pkg/protocol/services/filter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBulkService(t *testing.T) {
+	s := new(BulkServiceTestSuite)
+	s.resourceBase = "../../tests/bulk_service_test_suite"
+	suite.Run(t, s)
+}
+
+type BulkServiceTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *BulkServiceTestSuite) TestProcessBulk() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/group_schema.json")
+	userResourceType := s.mustResourceType("/user_resource_type.json")
+	groupResourceType := s.mustResourceType("/group_resource_type.json")
+
+	const createUser = `
+{
+	"method": "POST",
+	"path": "/Users",
+	"bulkId": "qwerty",
+	"data": {
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "imulab",
+		"emails": [{"value": "imulab@foo.com", "primary": true}]
+	}
+}`
+
+	tests := []struct {
+		name       string
+		getSPC     func(t *testing.T) *spec.ServiceProviderConfig
+		getRequest func(t *testing.T) string
+		expect     func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB)
+	}{
+		{
+			name: "create user and group referring to the user by bulkId",
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [` + createUser + `,
+		{
+			"method": "POST",
+			"path": "/Groups",
+			"bulkId": "ytrewq",
+			"data": {
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+				"displayName": "Tour Guides",
+				"members": [{"value": "bulkId:qwerty"}]
+			}
+		}
+	]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				require.Nil(t, err)
+				require.Len(t, resp.Operations, 2)
+				for _, op := range resp.Operations {
+					assert.Nil(t, op.Error)
+					assert.Equal(t, 201, op.Status)
+					assert.Equal(t, "POST", op.Method)
+					assert.NotEmpty(t, op.Location)
+					assert.NotEmpty(t, op.Version)
+				}
+				assert.Equal(t, "qwerty", resp.Operations[0].BulkID)
+				assert.Equal(t, "ytrewq", resp.Operations[1].BulkID)
+
+				users, err := userDB.Query(context.Background(), "userName eq \"imulab\"", nil, nil, nil)
+				require.Nil(t, err)
+				require.Len(t, users, 1)
+
+				groups, err := groupDB.Query(context.Background(), "members.value eq \""+users[0].ID()+"\"", nil, nil, nil)
+				require.Nil(t, err)
+				assert.Len(t, groups, 1)
+			},
+		},
+		{
+			name: "bulkId references outside of reference attributes are not resolved",
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [` + createUser + `,
+		{
+			"method": "POST",
+			"path": "/Groups",
+			"bulkId": "ytrewq",
+			"data": {
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+				"displayName": "bulkId:qwerty"
+			}
+		},
+		{
+			"method": "PATCH",
+			"path": "/Groups/bulkId:ytrewq",
+			"data": {
+				"Operations": [
+					{"op": "add", "path": "members", "value": [{"value": "bulkId:qwerty"}]},
+					{"op": "add", "value": {"displayName": "bulkId:ytrewq"}}
+				]
+			}
+		}
+	]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				require.Nil(t, err)
+				require.Len(t, resp.Operations, 3)
+				for _, op := range resp.Operations {
+					assert.Nil(t, op.Error)
+				}
+
+				users, err := userDB.Query(context.Background(), "userName eq \"imulab\"", nil, nil, nil)
+				require.Nil(t, err)
+				require.Len(t, users, 1)
+
+				groups, err := groupDB.Query(context.Background(), "members.value eq \""+users[0].ID()+"\"", nil, nil, nil)
+				require.Nil(t, err)
+				require.Len(t, groups, 1)
+				nav := groups[0].NewNavigator()
+				_, err = nav.FocusName("displayName")
+				require.Nil(t, err)
+				assert.Equal(t, "bulkId:ytrewq", nav.Current().Raw())
+			},
+		},
+		{
+			name: "modify and delete resource referred by bulkId in path",
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [` + createUser + `,
+		{
+			"method": "PATCH",
+			"path": "/Users/bulkId:qwerty",
+			"data": {
+				"Operations": [{"op": "replace", "path": "userName", "value": "david"}]
+			}
+		},
+		{
+			"method": "PUT",
+			"path": "/Users/bulkId:qwerty",
+			"data": {
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+				"userName": "imulab2",
+				"emails": [{"value": "imulab@foo.com", "primary": true}]
+			}
+		},
+		{
+			"method": "DELETE",
+			"path": "/Users/bulkId:qwerty"
+		}
+	]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				require.Nil(t, err)
+				require.Len(t, resp.Operations, 4)
+				assert.Equal(t, []int{201, 200, 200, 204}, []int{
+					resp.Operations[0].Status,
+					resp.Operations[1].Status,
+					resp.Operations[2].Status,
+					resp.Operations[3].Status,
+				})
+				for _, op := range resp.Operations {
+					assert.Nil(t, op.Error)
+					assert.Equal(t, resp.Operations[0].Location, op.Location)
+				}
+				assert.NotEqual(t, resp.Operations[0].Version, resp.Operations[1].Version)
+
+				n, err := userDB.Count(context.Background(), "id pr")
+				require.Nil(t, err)
+				assert.Equal(t, 0, n)
+			},
+		},
+		{
+			name: "operation version does not match",
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [` + createUser + `,
+		{
+			"method": "DELETE",
+			"path": "/Users/bulkId:qwerty",
+			"version": "W/\"foo\""
+		}
+	]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				require.Nil(t, err)
+				require.Len(t, resp.Operations, 2)
+				assert.Nil(t, resp.Operations[0].Error)
+				assert.Equal(t, 412, resp.Operations[1].Error.(*errors.Error).Status)
+			},
+		},
+		{
+			name: "unresolved bulkId reference fails the operation only",
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [
+		{
+			"method": "POST",
+			"path": "/Groups",
+			"bulkId": "ytrewq",
+			"data": {
+				"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+				"displayName": "Tour Guides",
+				"members": [{"value": "bulkId:qwerty"}]
+			}
+		},` + createUser + `
+	]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				require.Nil(t, err)
+				require.Len(t, resp.Operations, 2)
+				assert.Equal(t, errors.TypeInvalidValue, resp.Operations[0].Error.(*errors.Error).Type)
+				assert.Nil(t, resp.Operations[1].Error)
+				assert.Equal(t, 201, resp.Operations[1].Status)
+			},
+		},
+		{
+			name: "stop processing after failOnErrors",
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"failOnErrors": 1,
+	"Operations": [
+		{
+			"method": "DELETE",
+			"path": "/Users/foo"
+		},` + createUser + `
+	]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				require.Nil(t, err)
+				require.Len(t, resp.Operations, 1)
+				assert.Equal(t, 404, resp.Operations[0].Error.(*errors.Error).Status)
+
+				n, err := userDB.Count(context.Background(), "id pr")
+				require.Nil(t, err)
+				assert.Equal(t, 0, n)
+			},
+		},
+		{
+			name: "unknown path",
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [
+		{
+			"method": "DELETE",
+			"path": "/Foo/bar"
+		}
+	]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				require.Nil(t, err)
+				require.Len(t, resp.Operations, 1)
+				assert.Equal(t, errors.TypeInvalidPath, resp.Operations[0].Error.(*errors.Error).Type)
+			},
+		},
+		{
+			name: "too many operations",
+			getRequest: func(t *testing.T) string {
+				ops := `{"method": "DELETE", "path": "/Users/foo"}`
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [` + ops + `,` + ops + `,` + ops + `,` + ops + `,` + ops + `,` + ops + `]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				assert.NotNil(t, err)
+				assert.Equal(t, 413, err.(*errors.Error).Status)
+			},
+		},
+		{
+			name: "payload too large",
+			getRequest: func(t *testing.T) string {
+				ops := `{"method": "DELETE", "path": "/Users/` + strings.Repeat("a", 4096) + `"}`
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [` + ops + `]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				assert.NotNil(t, err)
+				assert.Equal(t, 413, err.(*errors.Error).Status)
+			},
+		},
+		{
+			name: "bulk not supported",
+			getSPC: func(t *testing.T) *spec.ServiceProviderConfig {
+				spc := s.mustServiceProviderConfig("/service_provider_config.json")
+				spc.Bulk.Supported = false
+				return spc
+			},
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [` + createUser + `]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name: "POST without bulkId",
+			getRequest: func(t *testing.T) string {
+				return `
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:BulkRequest"],
+	"Operations": [
+		{
+			"method": "POST",
+			"path": "/Users",
+			"data": {"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "imulab"}
+		}
+	]
+}`
+			},
+			expect: func(t *testing.T, resp *BulkResponse, err error, userDB db.DB, groupDB db.DB) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidSyntax, err.(*errors.Error).Type)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			var spc *spec.ServiceProviderConfig
+			if test.getSPC != nil {
+				spc = test.getSPC(t)
+			} else {
+				spc = s.mustServiceProviderConfig("/service_provider_config.json")
+			}
+
+			userDB, groupDB := db.Memory(), db.Memory()
+			service := &BulkService{
+				Logger: log.None(),
+				Endpoints: []*BulkEndpoint{
+					s.newEndpoint(userResourceType, userDB, spc),
+					s.newEndpoint(groupResourceType, groupDB, spc),
+				},
+				ServiceProviderConfig: spc,
+			}
+
+			raw := test.getRequest(t)
+			request := new(BulkRequest)
+			require.Nil(t, json.Unmarshal([]byte(raw), request))
+			request.PayloadSize = len(raw)
+
+			resp, err := service.ProcessBulk(context.Background(), request)
+			test.expect(t, resp, err, userDB, groupDB)
+		})
+	}
+}
+
+func (s *BulkServiceTestSuite) newEndpoint(resourceType *spec.ResourceType, database db.DB, spc *spec.ServiceProviderConfig) *BulkEndpoint {
+	return &BulkEndpoint{
+		ResourceType: resourceType,
+		Create: &CreateService{
+			Logger:   log.None(),
+			Filters:  []filter.ForResource{filter.ClearReadOnly(), filter.ID(), filter.Meta(), filter.Validation(database)},
+			Database: database,
+		},
+		Replace: &ReplaceService{
+			Logger:                log.None(),
+			Filters:               []filter.ForResource{filter.ClearReadOnly(), filter.CopyReadOnly(), filter.Validation(database), filter.Meta()},
+			Database:              database,
+			ServiceProviderConfig: spc,
+		},
+		Patch: &PatchService{
+			Logger:                log.None(),
+			PrePatchFilters:       []filter.ForResource{},
+			PostPatchFilters:      []filter.ForResource{filter.CopyReadOnly(), filter.Validation(database), filter.Meta()},
+			Database:              database,
+			ServiceProviderConfig: spc,
+		},
+		Delete: &DeleteService{
+			Logger:                log.None(),
+			Database:              database,
+			ServiceProviderConfig: spc,
+		},
+	}
+}
+
+func (s *BulkServiceTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *BulkServiceTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
+
+func (s *BulkServiceTestSuite) mustServiceProviderConfig(filePath string) *spec.ServiceProviderConfig {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	spc := new(spec.ServiceProviderConfig)
+	err = json.Unmarshal(raw, spc)
+	s.Require().Nil(err)
+
+	return spc
+}
@@ -5,8 +5,10 @@ import (
 	"github.com/imulab/go-scim/pkg/core/errors"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/db"
 	"github.com/imulab/go-scim/pkg/protocol/event"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 )
 
@@ -20,23 +22,37 @@ type (
 		Database              db.DB
 		Event                 event.Publisher
 		ServiceProviderConfig *spec.ServiceProviderConfig
+		// Optional lock which serializes the deletion with other updates to the same resource.
+		Lock lock.Lock
+		// Optional policy which must allow the principal to delete the resource.
+		Policy *auth.Policy
 	}
 )
 
 func (s *DeleteService) DeleteResource(ctx context.Context, request *DeleteRequest) error {
 	s.Logger.Debug("received delete request [id=%s]", request.ResourceID)
 
+	if s.Lock != nil {
+		if err := lockResource(ctx, s.Lock, request.ResourceID); err != nil {
+			return err
+		}
+		defer s.Lock.Unlock(request.ResourceID)
+	}
+
 	resource, err := s.Database.Get(ctx, request.ResourceID, nil)
 	if err != nil {
 		return err
 	}
+	if s.Policy != nil && !s.Policy.AllowedAll(ctx, auth.OperationDelete, resource) {
+		return errors.Forbidden("not allowed to delete resource [id=%s]", request.ResourceID)
+	}
 	if s.ServiceProviderConfig.ETag.Supported && request.MatchCriteria != nil {
 		if !request.MatchCriteria(resource) {
 			return errors.PreConditionFailed("resource [id=%s] does not meet pre condition", request.ResourceID)
 		}
 	}
 
-	err = s.Database.Delete(ctx, request.ResourceID)
+	err = s.Database.CompareAndDelete(ctx, request.ResourceID, resource.Version())
 	if err != nil {
 		s.Logger.Error("resource [id=%s] failed to delete from persistence: %s", request.ResourceID, err.Error())
 		return err
@@ -3,10 +3,13 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
 	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -14,6 +17,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestDeleteService(t *testing.T) {
@@ -35,6 +39,7 @@ func (s *DeleteServiceTestSuite) TestDelete() {
 	tests := []struct {
 		name       string
 		getService func(t *testing.T) *DeleteService
+		principal  *auth.Principal
 		request    *DeleteRequest
 		expect     func(t *testing.T, err error)
 	}{
@@ -76,12 +81,86 @@ func (s *DeleteServiceTestSuite) TestDelete() {
 				assert.NotNil(t, err)
 			},
 		},
+		{
+			name: 	"delete locked",
+			getService: func(t *testing.T) *DeleteService {
+				database := db.Memory()
+				err := database.Insert(context.Background(), s.mustResource("/user_001.json", resourceType))
+				require.Nil(t, err)
+				locks := lock.Default(10 * time.Millisecond)
+				require.Nil(t, locks.Lock(context.Background(), "a5866759-32ca-4e2a-9808-a0fe74f94b18"))
+				return &DeleteService{
+					Logger:   log.None(),
+					Database: database,
+					ServiceProviderConfig: spc,
+					Lock: locks,
+				}
+			},
+			request: &DeleteRequest{
+				ResourceID:    "a5866759-32ca-4e2a-9808-a0fe74f94b18",
+			},
+			expect: func(t *testing.T, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, errors.TypeUnavailable, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name: "delete allowed by policy",
+			getService: func(t *testing.T) *DeleteService {
+				database := db.Memory()
+				err := database.Insert(context.Background(), s.mustResource("/user_001.json", resourceType))
+				require.Nil(t, err)
+				return &DeleteService{
+					Logger:                log.None(),
+					Database:              database,
+					ServiceProviderConfig: spc,
+					Policy: &auth.Policy{Rules: []*auth.Rule{
+						{Subjects: []string{"admin"}, Operations: []auth.Operation{auth.OperationDelete}, Paths: []string{"*"}},
+					}},
+				}
+			},
+			principal: &auth.Principal{Subject: "admin"},
+			request: &DeleteRequest{
+				ResourceID: "a5866759-32ca-4e2a-9808-a0fe74f94b18",
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "delete not allowed by policy",
+			getService: func(t *testing.T) *DeleteService {
+				database := db.Memory()
+				err := database.Insert(context.Background(), s.mustResource("/user_001.json", resourceType))
+				require.Nil(t, err)
+				return &DeleteService{
+					Logger:                log.None(),
+					Database:              database,
+					ServiceProviderConfig: spc,
+					Policy: &auth.Policy{Rules: []*auth.Rule{
+						{Subjects: []string{"*"}, Operations: []auth.Operation{auth.OperationUpdate, auth.OperationDelete}, Paths: []string{"phoneNumbers"}},
+					}},
+				}
+			},
+			principal: &auth.Principal{Subject: "imulab"},
+			request: &DeleteRequest{
+				ResourceID: "a5866759-32ca-4e2a-9808-a0fe74f94b18",
+			},
+			expect: func(t *testing.T, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, errors.TypeForbidden, err.(*errors.Error).Type)
+			},
+		},
 	}
 
 	for _, test := range tests {
 		s.T().Run(test.name, func(t *testing.T) {
 			service := test.getService(t)
-			err := service.DeleteResource(context.Background(), test.request)
+			ctx := context.Background()
+			if test.principal != nil {
+				ctx = auth.WithPrincipal(ctx, test.principal)
+			}
+			err := service.DeleteResource(ctx, test.request)
 			test.expect(t, err)
 		})
 	}
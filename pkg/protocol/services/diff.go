@@ -0,0 +1,254 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/annotations"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"sort"
+	"strings"
+)
+
+// Compute the patch operations which turn the from resource into the to resource, which must be of the same resource
+// type. Read only attributes are not compared, as they cannot be patched.
+//
+// Operations follow the order of the attributes in the schema. Changes to single-valued complex attributes and schema
+// extensions are broken down to their sub attributes. Elements of multi-valued complex attributes with @Identity sub attributes are matched
+// by their identity: removed elements are removed, and changed elements are patched, by value filter paths on their
+// identity, and added elements are added by a single operation. Other multi-valued attributes are replaced as a whole.
+func Diff(from *prop.Resource, to *prop.Resource) ([]PatchOperation, error) {
+	if from.ResourceType().ID() != to.ResourceType().ID() {
+		return nil, errors.InvalidValue("cannot diff resources of different resource types")
+	}
+
+	d := &differ{operations: make([]PatchOperation, 0)}
+	err := d.diffChildren("", from.NewNavigator().Current().(prop.Container), to.NewNavigator().Current().(prop.Container))
+	if err != nil {
+		return nil, err
+	}
+	return d.operations, nil
+}
+
+type differ struct {
+	operations []PatchOperation
+}
+
+func (d *differ) emit(op string, path string, value interface{}) error {
+	operation := PatchOperation{Op: op, Path: path}
+	if op != OpRemove {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		operation.Value = raw
+	}
+	d.operations = append(d.operations, operation)
+	return nil
+}
+
+// Compare the sub properties of two complex properties of the same attribute.
+func (d *differ) diffChildren(path string, from prop.Container, to prop.Container) error {
+	return from.ForEachChild(func(_ int, child prop.Property) error {
+		attr := child.Attribute()
+		if attr.Mutability() == spec.MutabilityReadOnly {
+			return nil
+		}
+		return d.diff(joinPath(path, from.Attribute(), attr), child, to.ChildAtIndex(attr.Name()))
+	})
+}
+
+func (d *differ) diff(path string, from prop.Property, to prop.Property) error {
+	// schema extensions cannot be addressed as a whole
+	if to.Attribute().HasAnnotation(annotations.SchemaExtensionRoot) {
+		return d.diffChildren(path, from.(prop.Container), to.(prop.Container))
+	}
+
+	switch {
+	case from.IsUnassigned() && to.IsUnassigned():
+		return nil
+	case to.IsUnassigned():
+		return d.emit(OpRemove, path, nil)
+	case from.IsUnassigned():
+		return d.emit(OpAdd, path, valueOf(to))
+	}
+
+	attr := to.Attribute()
+	switch {
+	case attr.MultiValued() && attr.Type() == spec.TypeComplex && attr.HasIdentitySubAttributes():
+		return d.diffElements(path, from.(prop.Container), to.(prop.Container))
+	case attr.MultiValued():
+		if !sameValues(from, to) {
+			return d.emit(OpReplace, path, valueOf(to))
+		}
+		return nil
+	case attr.Type() == spec.TypeComplex:
+		return d.diffChildren(path, from.(prop.Container), to.(prop.Container))
+	default:
+		if !sameValues(from, to) {
+			return d.emit(OpReplace, path, valueOf(to))
+		}
+		return nil
+	}
+}
+
+// Compare the elements of two multi-valued complex properties, matching them by the hash of their identity sub
+// attributes. The attribute is replaced as a whole if elements cannot be told apart by their identity.
+func (d *differ) diffElements(path string, from prop.Container, to prop.Container) error {
+	var (
+		fromElements = make(map[uint64]prop.Container)
+		toElements   = make(map[uint64]prop.Container)
+		filters      = make(map[uint64]string)
+		distinct     = true
+	)
+	_ = from.ForEachChild(func(_ int, child prop.Property) error {
+		if child.IsUnassigned() {
+			return nil
+		}
+		h := child.Hash()
+		filter, ok := identityFilter(child.(prop.Container))
+		if _, dup := fromElements[h]; dup || !ok {
+			distinct = false
+		}
+		fromElements[h], filters[h] = child.(prop.Container), filter
+		return nil
+	})
+	_ = to.ForEachChild(func(_ int, child prop.Property) error {
+		if child.IsUnassigned() {
+			return nil
+		}
+		h := child.Hash()
+		if _, dup := toElements[h]; dup {
+			distinct = false
+		}
+		toElements[h] = child.(prop.Container)
+		return nil
+	})
+	if !distinct {
+		if !sameValues(from, to) {
+			return d.emit(OpReplace, path, valueOf(to))
+		}
+		return nil
+	}
+
+	// remove, in the order of the from elements
+	err := from.ForEachChild(func(_ int, child prop.Property) error {
+		if child.IsUnassigned() {
+			return nil
+		}
+		if _, ok := toElements[child.Hash()]; !ok {
+			return d.emit(OpRemove, path+"["+filters[child.Hash()]+"]", nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// patch and then add, in the order of the to elements
+	added := make([]interface{}, 0)
+	err = to.ForEachChild(func(_ int, child prop.Property) error {
+		if child.IsUnassigned() {
+			return nil
+		}
+		if element, ok := fromElements[child.Hash()]; ok {
+			return d.diffChildren(path+"["+filters[child.Hash()]+"]", element, child.(prop.Container))
+		}
+		added = append(added, valueOf(child))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(added) > 0 {
+		return d.emit(OpAdd, path, added)
+	}
+	return nil
+}
+
+// Return the filter which matches the element by its identity sub attributes, including those which are unassigned,
+// so that it does not match elements which only agree on the assigned ones. Returns false if no identity sub attribute
+// is assigned.
+func identityFilter(element prop.Container) (string, bool) {
+	var (
+		terms    = make([]string, 0)
+		assigned = false
+	)
+	_ = element.ForEachChild(func(_ int, child prop.Property) error {
+		attr := child.Attribute()
+		if !attr.IsIdentity() {
+			return nil
+		}
+		if child.IsUnassigned() {
+			terms = append(terms, "not ("+attr.Name()+" pr)")
+			return nil
+		}
+		literal, err := json.Marshal(child.Raw())
+		if err != nil {
+			return err
+		}
+		terms = append(terms, attr.Name()+" eq "+string(literal))
+		assigned = true
+		return nil
+	})
+	return strings.Join(terms, " and "), assigned
+}
+
+// Join the name of the attribute to the path of its parent. Attributes of schema extensions are prefixed by the
+// extension schema id.
+func joinPath(path string, parent *spec.Attribute, attr *spec.Attribute) string {
+	switch {
+	case len(path) == 0:
+		return attr.Name()
+	case parent.HasAnnotation(annotations.SchemaExtensionRoot):
+		return path + ":" + attr.Name()
+	default:
+		return path + "." + attr.Name()
+	}
+}
+
+// Return the value of the property for patch operations, leaving out unassigned and read only sub attributes.
+func valueOf(property prop.Property) interface{} {
+	attr := property.Attribute()
+	switch {
+	case attr.MultiValued():
+		values := make([]interface{}, 0)
+		_ = property.(prop.Container).ForEachChild(func(_ int, child prop.Property) error {
+			if !child.IsUnassigned() {
+				values = append(values, valueOf(child))
+			}
+			return nil
+		})
+		return values
+	case attr.Type() == spec.TypeComplex:
+		values := make(map[string]interface{})
+		_ = property.(prop.Container).ForEachChild(func(_ int, child prop.Property) error {
+			if !child.IsUnassigned() && child.Attribute().Mutability() != spec.MutabilityReadOnly {
+				values[child.Attribute().Name()] = valueOf(child)
+			}
+			return nil
+		})
+		return values
+	default:
+		return property.Raw()
+	}
+}
+
+// Returns true if the two properties have the same value. Elements of multi-valued properties are compared regardless
+// of their order, and strings are compared case sensitively, so that changes of case are not lost.
+func sameValues(a prop.Property, b prop.Property) bool {
+	canonical := func(property prop.Property) string {
+		if !property.Attribute().MultiValued() {
+			raw, _ := json.Marshal(valueOf(property))
+			return string(raw)
+		}
+		elements := make([]string, 0)
+		for _, v := range valueOf(property).([]interface{}) {
+			raw, _ := json.Marshal(v)
+			elements = append(elements, string(raw))
+		}
+		sort.Strings(elements)
+		return strings.Join(elements, ",")
+	}
+	return canonical(a) == canonical(b)
+}
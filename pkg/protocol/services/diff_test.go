@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	s := new(DiffTestSuite)
+	s.resourceBase = "../../tests/diff_test_suite"
+	suite.Run(t, s)
+}
+
+type DiffTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *DiffTestSuite) TestDiff() {
+	_ = s.mustSchema("/user_schema.json")
+	_ = s.mustSchema("/user_enterprise_extension_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	tests := []struct {
+		name   string
+		from   string
+		to     string
+		expect []PatchOperation
+	}{
+		{
+			name:   "identical resources",
+			from:   "/user_000.json",
+			to:     "/user_000.json",
+			expect: []PatchOperation{},
+		},
+		{
+			name: "simple and complex attributes",
+			from: "/user_000.json",
+			to:   "/user_001.json",
+			expect: []PatchOperation{
+				{Op: OpReplace, Path: "name.givenName", Value: json.RawMessage(`"David"`)},
+				{Op: OpReplace, Path: "displayName", Value: json.RawMessage(`"David"`)},
+				{Op: OpAdd, Path: "nickName", Value: json.RawMessage(`"imulab"`)},
+				{Op: OpRemove, Path: "timezone"},
+			},
+		},
+		{
+			name: "multiValued attribute with identity",
+			from: "/user_000.json",
+			to:   "/user_002.json",
+			expect: []PatchOperation{
+				{Op: OpRemove, Path: `emails[value eq "imulab@bar.com" and type eq "home"]`},
+				{Op: OpReplace, Path: `emails[value eq "imulab@foo.com" and type eq "work"].display`, Value: json.RawMessage(`"Work"`)},
+				{Op: OpAdd, Path: "emails", Value: json.RawMessage(`[{"display":"imulab@baz.com","type":"other","value":"imulab@baz.com"}]`)},
+			},
+		},
+		{
+			name: "multiValued attribute with unassigned identity",
+			from: "/user_003.json",
+			to:   "/user_000.json",
+			expect: []PatchOperation{
+				{Op: OpRemove, Path: `ims[value eq "imulab" and not (type pr)]`},
+			},
+		},
+		{
+			name: "add schema extension",
+			from: "/user_000.json",
+			to:   "/user_004.json",
+		},
+		{
+			name: "schema extension attributes",
+			from: "/user_004.json",
+			to:   "/user_005.json",
+			expect: []PatchOperation{
+				{Op: OpReplace, Path: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber", Value: json.RawMessage(`"11251"`)},
+				{Op: OpRemove, Path: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			from := s.mustResource(test.from, resourceType)
+			to := s.mustResource(test.to, resourceType)
+
+			operations, err := Diff(from, to)
+			require.Nil(t, err)
+			if test.expect != nil {
+				assert.Equal(t, test.expect, operations)
+			}
+
+			// applying the operations must reproduce the target
+			require.Nil(t, applyPatch(from, operations))
+			expect, err := scimJSON.Serialize(to, scimJSON.Options())
+			require.Nil(t, err)
+			actual, err := scimJSON.Serialize(from, scimJSON.Options())
+			require.Nil(t, err)
+			assert.JSONEq(t, string(expect), string(actual))
+		})
+	}
+}
+
+func (s *DiffTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	resource := prop.NewResource(resourceType)
+	err = scimJSON.Deserialize(raw, resource)
+	s.Require().Nil(err)
+
+	return resource
+}
+
+func (s *DiffTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *DiffTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
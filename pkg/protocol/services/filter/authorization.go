@@ -0,0 +1,202 @@
+package filter
+
+import (
+	"context"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
+	"sort"
+	"strings"
+)
+
+// Create a ForResource filter that rejects writes to attributes which the principal in the context is not allowed to
+// make by the policy. See AuthorizationForProperty.
+func Authorization(policy *auth.Policy) ForResource {
+	return FromForProperty(AuthorizationForProperty(policy))
+}
+
+// Create a ForProperty filter that rejects writes to attributes which the principal in the context is not allowed to
+// make by the policy, with a forbidden error. Without a reference, the resource is being created, and any assigned
+// attribute requires auth.OperationCreate; with a reference, the resource is being updated, and any attribute that
+// differs from the reference requires auth.OperationUpdate on the reference resource. Read only attributes are managed
+// by the server and hence not checked. This filter should be placed before any other filters that modify the resource.
+func AuthorizationForProperty(policy *auth.Policy) ForProperty {
+	return &authorizationFieldFilter{policy: policy}
+}
+
+type authorizationFieldFilter struct {
+	policy *auth.Policy
+}
+
+func (f *authorizationFieldFilter) Supports(attribute *spec.Attribute) bool {
+	// Elements of multiValued attributes are covered by the multiValued attribute; singular complex attributes are
+	// covered by their sub attributes.
+	return attribute.Mutability() != spec.MutabilityReadOnly &&
+		attribute.ID() != "schemas" &&
+		!strings.HasSuffix(attribute.ID(), "$elem") &&
+		(attribute.MultiValued() || attribute.Type() != spec.TypeComplex)
+}
+
+func (f *authorizationFieldFilter) Filter(ctx context.Context, resource *prop.Resource, property prop.Property) error {
+	switch {
+	case property.IsUnassigned() || isElementMember(property):
+		return nil
+	case isMultiValuedComplex(property.Attribute()):
+		return f.checkElements(ctx, auth.OperationCreate, resource, property, nil)
+	}
+	if !f.policy.Allowed(ctx, auth.OperationCreate, resource, property.Attribute()) {
+		return errors.Forbidden("not allowed to create attribute '%s'", property.Attribute().Path())
+	}
+	return nil
+}
+
+func (f *authorizationFieldFilter) FieldRef(ctx context.Context, resource *prop.Resource, property prop.Property,
+	refResource *prop.Resource, refProperty prop.Property) error {
+	switch {
+	case isElementMember(property):
+		return nil
+	case isMultiValuedComplex(property.Attribute()):
+		return f.checkElements(ctx, auth.OperationUpdate, refResource, property, refProperty)
+	}
+	if refProperty == nil {
+		if property.IsUnassigned() {
+			return nil
+		}
+	} else if property.IsUnassigned() == refProperty.IsUnassigned() && property.Hash() == refProperty.Hash() {
+		return nil
+	}
+	if !f.policy.Allowed(ctx, auth.OperationUpdate, refResource, property.Attribute()) {
+		return errors.Forbidden("not allowed to update attribute '%s'", property.Attribute().Path())
+	}
+	return nil
+}
+
+// Check the sub attributes written by changing the elements of the multiValued complex property from those of the
+// reference property, which may be nil. Since an edited element may no longer match its reference element by identity,
+// elements are first matched to identical reference elements, and then to the remaining reference elements with the
+// fewest differing sub attributes, whose differences are then checked. An element without a reference element is
+// added, and a reference element without an element is removed, which writes all of its assigned sub attributes.
+func (f *authorizationFieldFilter) checkElements(ctx context.Context, op auth.Operation, resource *prop.Resource,
+	property prop.Property, refProperty prop.Property) error {
+	var (
+		elements    = assignedElements(property)
+		refElements = assignedElements(refProperty)
+		matched     = make([]bool, len(refElements))
+		written     = make(map[*spec.Attribute]struct{})
+		write       = func(attrs []*spec.Attribute) {
+			for _, attr := range attrs {
+				written[attr] = struct{}{}
+			}
+		}
+	)
+
+	unmatched := make([]prop.Container, 0)
+	for _, elem := range elements {
+		k := -1
+		for i, ref := range refElements {
+			if !matched[i] && len(differingSubAttributes(elem, ref)) == 0 {
+				k = i
+				break
+			}
+		}
+		if k < 0 {
+			unmatched = append(unmatched, elem)
+		} else {
+			matched[k] = true
+		}
+	}
+	for _, elem := range unmatched {
+		k := -1
+		for i, ref := range refElements {
+			if !matched[i] && (k < 0 || len(differingSubAttributes(elem, ref)) < len(differingSubAttributes(elem, refElements[k]))) {
+				k = i
+			}
+		}
+		if k < 0 {
+			write(differingSubAttributes(elem, nil))
+		} else {
+			matched[k] = true
+			write(differingSubAttributes(elem, refElements[k]))
+		}
+	}
+	for i, ref := range refElements {
+		if !matched[i] {
+			write(differingSubAttributes(ref, nil))
+		}
+	}
+
+	allowed := f.policy.Allows(ctx, op, resource)
+	for _, attr := range sortedAttributes(written) {
+		if !allowed(attr) {
+			return errors.Forbidden("not allowed to %s attribute '%s'", op, attr.Path())
+		}
+	}
+	return nil
+}
+
+// Returns true if the property is a sub property of an element of a multiValued complex property.
+func isElementMember(property prop.Property) bool {
+	elem := property.Parent()
+	if elem == nil || elem.Parent() == nil {
+		return false
+	}
+	return isMultiValuedComplex(elem.Parent().Attribute())
+}
+
+func isMultiValuedComplex(attribute *spec.Attribute) bool {
+	return attribute.MultiValued() && attribute.Type() == spec.TypeComplex
+}
+
+func assignedElements(property prop.Property) []prop.Container {
+	elements := make([]prop.Container, 0)
+	if property == nil {
+		return elements
+	}
+	_ = property.(prop.Container).ForEachChild(func(_ int, child prop.Property) error {
+		if !child.IsUnassigned() {
+			elements = append(elements, child.(prop.Container))
+		}
+		return nil
+	})
+	return elements
+}
+
+// Returns the writable sub attributes whose values differ between the element and the reference element. When the
+// reference element is nil, all assigned writable sub attributes differ.
+func differingSubAttributes(elem prop.Container, ref prop.Container) []*spec.Attribute {
+	attrs := make([]*spec.Attribute, 0)
+	_ = elem.ForEachChild(func(_ int, child prop.Property) error {
+		if child.Attribute().Mutability() == spec.MutabilityReadOnly {
+			return nil
+		}
+		if ref == nil {
+			if !child.IsUnassigned() {
+				attrs = append(attrs, child.Attribute())
+			}
+			return nil
+		}
+		refChild := ref.ChildAtIndex(child.Attribute().Name())
+		if child.IsUnassigned() != refChild.IsUnassigned() || child.Hash() != refChild.Hash() {
+			attrs = append(attrs, child.Attribute())
+		}
+		return nil
+	})
+	return attrs
+}
+
+// Returns the attributes in the order of their paths, so that the reported error is deterministic.
+func sortedAttributes(set map[*spec.Attribute]struct{}) []*spec.Attribute {
+	attrs := make([]*spec.Attribute, 0, len(set))
+	for attr := range set {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].Path() < attrs[j].Path()
+	})
+	return attrs
+}
+
+var (
+	_ ForProperty = (*authorizationFieldFilter)(nil)
+)
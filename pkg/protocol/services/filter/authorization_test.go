@@ -0,0 +1,304 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/core/expr"
+	scimJSON "github.com/imulab/go-scim/pkg/core/json"
+	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/auth"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestAuthorizationFilter(t *testing.T) {
+	s := new(AuthorizationFilterTestSuite)
+	s.resourceBase = "../../../tests/authorization_filter_test_suite"
+	suite.Run(t, s)
+}
+
+type AuthorizationFilterTestSuite struct {
+	suite.Suite
+	resourceBase string
+}
+
+func (s *AuthorizationFilterTestSuite) TestFilter() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+	expr.Register(resourceType)
+
+	policy := &auth.Policy{
+		Rules: []*auth.Rule{
+			{Subjects: []string{"admin"}, Operations: []auth.Operation{auth.OperationRead}, Paths: []string{"*"}},
+			{Subjects: []string{"admin"}, ResourceType: "User", Operations: []auth.Operation{auth.OperationUpdate}, Paths: []string{"active"}},
+			{Subjects: []string{"*"}, Self: true, Operations: []auth.Operation{auth.OperationRead, auth.OperationUpdate}, Paths: []string{"phoneNumbers"}},
+			{Subjects: []string{"provisioner"}, Operations: []auth.Operation{auth.OperationCreate}, Paths: []string{"*"}},
+			{Subjects: []string{"typist"}, Operations: []auth.Operation{auth.OperationUpdate}, Paths: []string{"emails.type"}},
+		},
+	}
+	as := func(subject string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{Subject: subject})
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		create bool
+		modify func(t *testing.T, resource *prop.Resource, ref *prop.Resource)
+		expect func(t *testing.T, err error)
+	}{
+		{
+			name: "admin updates active",
+			ctx:  as("admin"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Replace(resource, "active", false))
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "admin updates displayName",
+			ctx:  as("admin"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Replace(resource, "displayName", "foo"))
+			},
+			expect: func(t *testing.T, err error) {
+				s.assertForbidden(t, err)
+			},
+		},
+		{
+			name: "admin updates nothing",
+			ctx:  as("admin"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "self adds phone number",
+			ctx:  as("user001"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Add(resource, "phoneNumbers", []interface{}{
+					map[string]interface{}{"value": "123-00000", "type": "home"},
+				}))
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "self removes phone numbers",
+			ctx:  as("user001"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Delete(resource, "phoneNumbers"))
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "self updates active",
+			ctx:  as("user001"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Replace(resource, "active", false))
+			},
+			expect: func(t *testing.T, err error) {
+				s.assertForbidden(t, err)
+			},
+		},
+		{
+			name: "other user updates phone numbers",
+			ctx:  as("user002"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Delete(resource, "phoneNumbers"))
+			},
+			expect: func(t *testing.T, err error) {
+				s.assertForbidden(t, err)
+			},
+		},
+		{
+			name: "typist updates type of email",
+			ctx:  as("typist"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Replace(resource, `emails[value eq "imulab@bar.com"].type`, "other"))
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "typist updates value of email",
+			ctx:  as("typist"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Replace(resource, `emails[type eq "home"].value`, "imulab@baz.com"))
+			},
+			expect: func(t *testing.T, err error) {
+				s.assertForbidden(t, err)
+			},
+		},
+		{
+			name: "typist removes email",
+			ctx:  as("typist"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Delete(resource, `emails[value eq "imulab@bar.com"]`))
+			},
+			expect: func(t *testing.T, err error) {
+				s.assertForbidden(t, err)
+			},
+		},
+		{
+			name: "typist adds email",
+			ctx:  as("typist"),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Add(resource, "emails", []interface{}{
+					map[string]interface{}{"type": "other"},
+				}))
+			},
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "anonymous updates active",
+			ctx:  context.Background(),
+			modify: func(t *testing.T, resource *prop.Resource, ref *prop.Resource) {
+				require.Nil(t, crud.Replace(resource, "active", false))
+			},
+			expect: func(t *testing.T, err error) {
+				s.assertForbidden(t, err)
+			},
+		},
+		{
+			name:   "provisioner creates",
+			ctx:    as("provisioner"),
+			create: true,
+			expect: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name:   "admin creates",
+			ctx:    as("admin"),
+			create: true,
+			expect: func(t *testing.T, err error) {
+				s.assertForbidden(t, err)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		s.T().Run(test.name, func(t *testing.T) {
+			ref := s.mustResource("/user_001.json", resourceType)
+			var err error
+			if test.create {
+				err = Authorization(policy).Filter(test.ctx, ref)
+			} else {
+				resource := ref.Clone()
+				test.modify(t, resource, ref)
+				err = Authorization(policy).FilterRef(test.ctx, resource, ref)
+			}
+			test.expect(t, err)
+		})
+	}
+}
+
+func (s *AuthorizationFilterTestSuite) TestReadable() {
+	_ = s.mustSchema("/user_schema.json")
+	resourceType := s.mustResourceType("/user_resource_type.json")
+
+	policy := &auth.Policy{
+		Rules: []*auth.Rule{
+			{Subjects: []string{"*"}, Self: true, Operations: []auth.Operation{auth.OperationRead}, Paths: []string{"name.givenName"}},
+		},
+	}
+	resource := s.mustResource("/user_001.json", resourceType)
+
+	raw, err := scimJSON.Serialize(resource, scimJSON.Options().Allow(policy.Allows(
+		auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "user001"}),
+		auth.OperationRead,
+		resource,
+	)))
+	assert.Nil(s.T(), err)
+	assert.JSONEq(s.T(), `
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "a5866759-32ca-4e2a-9808-a0fe74f94b18",
+	"name": {
+		"givenName": "Weinan"
+	}
+}
+`, string(raw))
+
+	raw, err = scimJSON.Serialize(resource, scimJSON.Options().Allow(policy.Allows(
+		auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "user002"}),
+		auth.OperationRead,
+		resource,
+	)))
+	assert.Nil(s.T(), err)
+	assert.JSONEq(s.T(), `
+{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "a5866759-32ca-4e2a-9808-a0fe74f94b18"
+}
+`, string(raw))
+}
+
+func (s *AuthorizationFilterTestSuite) assertForbidden(t *testing.T, err error) {
+	require.NotNil(t, err)
+	scimError, ok := err.(*errors.Error)
+	require.True(t, ok)
+	assert.Equal(t, 403, scimError.Status)
+}
+
+func (s *AuthorizationFilterTestSuite) mustResource(filePath string, resourceType *spec.ResourceType) *prop.Resource {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	resource := prop.NewResource(resourceType)
+	err = scimJSON.Deserialize(raw, resource)
+	s.Require().Nil(err)
+
+	return resource
+}
+
+func (s *AuthorizationFilterTestSuite) mustResourceType(filePath string) *spec.ResourceType {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	rt := new(spec.ResourceType)
+	err = json.Unmarshal(raw, rt)
+	s.Require().Nil(err)
+
+	return rt
+}
+
+func (s *AuthorizationFilterTestSuite) mustSchema(filePath string) *spec.Schema {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	sch := new(spec.Schema)
+	err = json.Unmarshal(raw, sch)
+	s.Require().Nil(err)
+
+	spec.SchemaHub.Put(sch)
+
+	return sch
+}
@@ -2,7 +2,9 @@ package services
 
 import (
 	"context"
+	"github.com/imulab/go-scim/pkg/core/errors"
 	"github.com/imulab/go-scim/pkg/core/prop"
+	"github.com/imulab/go-scim/pkg/core/spec"
 	"github.com/imulab/go-scim/pkg/protocol/crud"
 	"github.com/imulab/go-scim/pkg/protocol/db"
 	"github.com/imulab/go-scim/pkg/protocol/log"
@@ -11,16 +13,27 @@ import (
 type (
 	GetRequest struct {
 		*crud.Projection
-		ResourceID         string
+		ResourceID string
+		// Optional criteria of the If-Match header. The request fails with pre condition failure if the resource
+		// does not meet the criteria.
+		MatchCriteria func(resource *prop.Resource) bool
+		// Optional criteria of the If-None-Match header. The response is marked as not modified if the resource does
+		// not meet the criteria.
+		NoneMatchCriteria func(resource *prop.Resource) bool
 	}
 	GetResponse struct {
 		Resource *prop.Resource
 		Location string
 		Version  string
+		// true if the resource was not modified with respect to the versions in the If-None-Match header, in which
+		// case the resource shall not be returned to the client.
+		NotModified bool
 	}
 	GetService struct {
 		Logger   log.Logger
 		Database db.DB
+		// Optional. Conditional requests are evaluated only when it is present and etag is supported.
+		ServiceProviderConfig *spec.ServiceProviderConfig
 	}
 )
 
@@ -33,9 +46,21 @@ func (s *GetService) GetResource(ctx context.Context, request *GetRequest) (*Get
 		return nil, err
 	}
 
-	return &GetResponse{
+	resp := &GetResponse{
 		Resource: resource,
 		Location: resource.Location(),
 		Version:  resource.Version(),
-	}, nil
+	}
+
+	if s.ServiceProviderConfig != nil && s.ServiceProviderConfig.ETag.Supported {
+		if request.MatchCriteria != nil && !request.MatchCriteria(resource) {
+			return nil, errors.PreConditionFailed("resource [id=%s] does not meet pre condition", request.ResourceID)
+		}
+		if request.NoneMatchCriteria != nil && !request.NoneMatchCriteria(resource) {
+			s.Logger.Debug("resource [id=%s] is not modified", request.ResourceID)
+			resp.NotModified = true
+		}
+	}
+
+	return resp, nil
 }
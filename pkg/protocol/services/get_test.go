@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
@@ -30,6 +31,19 @@ type GetServiceTestSuite struct {
 func (s *GetServiceTestSuite) TestGet() {
 	_ = s.mustSchema("/user_schema.json")
 	resourceType := s.mustResourceType("/user_resource_type.json")
+	spc := s.mustServiceProviderConfig("/service_provider_config.json")
+	version := s.mustResource("/user_001.json", resourceType).Version()
+
+	conditionalService := func(t *testing.T) *GetService {
+		database := db.Memory()
+		err := database.Insert(context.Background(), s.mustResource("/user_001.json", resourceType))
+		require.Nil(t, err)
+		return &GetService{
+			Logger:                log.None(),
+			Database:              database,
+			ServiceProviderConfig: spc,
+		}
+	}
 
 	tests := []struct {
 		name       string
@@ -76,6 +90,49 @@ func (s *GetServiceTestSuite) TestGet() {
 				assert.NotNil(t, err)
 			},
 		},
+		{
+			name:       "get not modified",
+			getService: conditionalService,
+			request: &GetRequest{
+				ResourceID: "a5866759-32ca-4e2a-9808-a0fe74f94b18",
+				NoneMatchCriteria: func(resource *prop.Resource) bool {
+					return resource.Version() != version
+				},
+			},
+			expect: func(t *testing.T, response *GetResponse, err error) {
+				assert.Nil(t, err)
+				assert.True(t, response.NotModified)
+				assert.Equal(t, version, response.Version)
+			},
+		},
+		{
+			name:       "get modified",
+			getService: conditionalService,
+			request: &GetRequest{
+				ResourceID: "a5866759-32ca-4e2a-9808-a0fe74f94b18",
+				NoneMatchCriteria: func(resource *prop.Resource) bool {
+					return resource.Version() != "W/\"0\""
+				},
+			},
+			expect: func(t *testing.T, response *GetResponse, err error) {
+				assert.Nil(t, err)
+				assert.False(t, response.NotModified)
+			},
+		},
+		{
+			name:       "get with failed pre condition",
+			getService: conditionalService,
+			request: &GetRequest{
+				ResourceID: "a5866759-32ca-4e2a-9808-a0fe74f94b18",
+				MatchCriteria: func(resource *prop.Resource) bool {
+					return resource.Version() == "W/\"0\""
+				},
+			},
+			expect: func(t *testing.T, response *GetResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, 412, err.(*errors.Error).Status)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -131,3 +188,16 @@ func (s *GetServiceTestSuite) mustSchema(filePath string) *spec.Schema {
 	return sch
 }
 
+func (s *GetServiceTestSuite) mustServiceProviderConfig(filePath string) *spec.ServiceProviderConfig {
+	f, err := os.Open(s.resourceBase + filePath)
+	s.Require().Nil(err)
+
+	raw, err := ioutil.ReadAll(f)
+	s.Require().Nil(err)
+
+	spc := new(spec.ServiceProviderConfig)
+	err = json.Unmarshal(raw, spc)
+	s.Require().Nil(err)
+
+	return spc
+}
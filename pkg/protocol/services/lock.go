@@ -0,0 +1,17 @@
+package services
+
+import (
+	"context"
+	"github.com/imulab/go-scim/pkg/core/errors"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
+)
+
+// Acquire the lock on the resource id. Failing to acquire the lock before the context is done, i.e. because a
+// concurrent operation holds it beyond the timeout, is reported as an error that the client may retry.
+func lockResource(ctx context.Context, locks lock.Lock, id string) error {
+	err := locks.Lock(ctx, id)
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		return errors.Unavailable("resource [id=%s] is locked by a concurrent operation", id)
+	}
+	return err
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/imulab/go-scim/pkg/protocol/crud"
 	"github.com/imulab/go-scim/pkg/protocol/db"
 	"github.com/imulab/go-scim/pkg/protocol/event"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/imulab/go-scim/pkg/protocol/services/filter"
 )
@@ -49,6 +50,8 @@ type (
 		Database              db.DB
 		ServiceProviderConfig *spec.ServiceProviderConfig
 		Event                 event.Publisher
+		// Optional lock which serializes the patch with other updates to the same resource.
+		Lock lock.Lock
 	}
 )
 
@@ -71,6 +74,13 @@ func (s *PatchService) PatchResource(ctx context.Context, request *PatchRequest)
 		return nil, err
 	}
 
+	if s.Lock != nil {
+		if err := lockResource(ctx, s.Lock, request.ResourceID); err != nil {
+			return nil, err
+		}
+		defer s.Lock.Unlock(request.ResourceID)
+	}
+
 	ref, err := s.Database.Get(ctx, request.ResourceID, nil)
 	if err != nil {
 		return nil, err
@@ -89,25 +99,8 @@ func (s *PatchService) PatchResource(ctx context.Context, request *PatchRequest)
 		}
 	}
 
-	for _, patchOp := range request.Operations {
-		switch patchOp.Op {
-		case OpAdd:
-			if valueToAdd, err := patchOp.ParseValue(resource); err != nil {
-				return nil, err
-			} else if err := crud.Add(resource, patchOp.Path, valueToAdd); err != nil {
-				return nil, err
-			}
-		case OpReplace:
-			if valueToReplace, err := patchOp.ParseValue(resource); err != nil {
-				return nil, err
-			} else if err := crud.Replace(resource, patchOp.Path, valueToReplace); err != nil {
-				return nil, err
-			}
-		case OpRemove:
-			if err := crud.Delete(resource, patchOp.Path); err != nil {
-				return nil, err
-			}
-		}
+	if err := applyPatch(resource, request.Operations); err != nil {
+		return nil, err
 	}
 
 	for _, f := range s.PostPatchFilters {
@@ -119,7 +112,7 @@ func (s *PatchService) PatchResource(ctx context.Context, request *PatchRequest)
 
 	// Only replace when version is bumped
 	if resource.Version() != ref.Version() {
-		err = s.Database.Replace(ctx, resource)
+		err = s.Database.CompareAndReplace(ctx, resource, ref.Version())
 		if err != nil {
 			s.Logger.Error("resource [id=%s] failed to save into persistence: %s", request.ResourceID, err.Error())
 			return nil, err
@@ -139,8 +132,33 @@ func (s *PatchService) PatchResource(ctx context.Context, request *PatchRequest)
 	}, nil
 }
 
+// Apply the patch operations to the resource in order.
+func applyPatch(resource *prop.Resource, operations []PatchOperation) error {
+	for _, patchOp := range operations {
+		switch patchOp.Op {
+		case OpAdd:
+			if valueToAdd, err := patchOp.ParseValue(resource); err != nil {
+				return err
+			} else if err := crud.Add(resource, patchOp.Path, valueToAdd); err != nil {
+				return err
+			}
+		case OpReplace:
+			if valueToReplace, err := patchOp.ParseValue(resource); err != nil {
+				return err
+			} else if err := crud.Replace(resource, patchOp.Path, valueToReplace); err != nil {
+				return err
+			}
+		case OpRemove:
+			if err := crud.Delete(resource, patchOp.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (pr *PatchRequest) Validate() error {
-	if len(pr.Schemas) != 1 && pr.Schemas[0] != PatchOpSchema {
+	if len(pr.Schemas) != 1 || pr.Schemas[0] != PatchOpSchema {
 		return errors.InvalidSyntax("patch request must describe payload with schema '%s'", PatchOpSchema)
 	}
 
@@ -3,11 +3,14 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
 	"github.com/imulab/go-scim/pkg/core/expr"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
+	"github.com/imulab/go-scim/pkg/protocol/crud"
 	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/imulab/go-scim/pkg/protocol/services/filter"
 	"github.com/stretchr/testify/assert"
@@ -16,6 +19,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestPatchService(t *testing.T) {
@@ -161,6 +165,93 @@ func (s *PatchServiceTestSuite) TestPatchService() {
 				assert.Equal(t, resp.OldVersion, resp.NewVersion)
 			},
 		},
+		{
+			name: 	"patch a resource modified concurrently",
+			setup: func(t *testing.T) *PatchService {
+				memoryDB := db.Memory()
+				require.Nil(t, memoryDB.Insert(
+					context.Background(),
+					s.mustResource("/user_000.json", resourceType)),
+				)
+				return &PatchService{
+					Logger:           log.None(),
+					PrePatchFilters: []filter.ForResource{
+						&concurrentUpdate{database: memoryDB},
+					},
+					PostPatchFilters: []filter.ForResource{
+						filter.CopyReadOnly(),
+						filter.Password(10),
+						filter.Validation(memoryDB),
+						filter.Meta(),
+					},
+					Database: memoryDB,
+					ServiceProviderConfig: spc,
+				}
+			},
+			getRequest: func(t *testing.T) *PatchRequest {
+				req := new(PatchRequest)
+				err := json.Unmarshal([]byte(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	"Operations": [
+		{
+			"op": "add",
+			"path": "userName",
+			"value": "foobar"
+		}
+	]
+}
+`), req)
+				require.Nil(t, err)
+				req.ResourceID = "3cc032f5-2361-417f-9e2f-bc80adddf4a3"
+				return req
+			},
+			expect: func(t *testing.T, resp *PatchResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypePreCondition, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name: "patch a resource locked by a concurrent operation",
+			setup: func(t *testing.T) *PatchService {
+				memoryDB := db.Memory()
+				require.Nil(t, memoryDB.Insert(
+					context.Background(),
+					s.mustResource("/user_000.json", resourceType)),
+				)
+				locks := lock.Default(10 * time.Millisecond)
+				require.Nil(t, locks.Lock(context.Background(), "3cc032f5-2361-417f-9e2f-bc80adddf4a3"))
+				return &PatchService{
+					Logger:                log.None(),
+					Database:              memoryDB,
+					ServiceProviderConfig: spc,
+					Lock:                  locks,
+				}
+			},
+			getRequest: func(t *testing.T) *PatchRequest {
+				req := new(PatchRequest)
+				err := json.Unmarshal([]byte(`
+{
+	"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+	"Operations": [
+		{
+			"op": "add",
+			"path": "userName",
+			"value": "foobar"
+		}
+	]
+}
+`), req)
+				require.Nil(t, err)
+				req.ResourceID = "3cc032f5-2361-417f-9e2f-bc80adddf4a3"
+				return req
+			},
+			expect: func(t *testing.T, resp *PatchResponse, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, 503, err.(*errors.Error).Status)
+				assert.Equal(t, errors.TypeUnavailable, err.(*errors.Error).Type)
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -366,6 +457,24 @@ func (s *PatchServiceTestSuite) TestParsePayload() {
 	}
 }
 
+// Filter which replaces the stored resource with a new version, as a concurrent update would do after the service
+// has read the resource.
+type concurrentUpdate struct {
+	database db.DB
+}
+
+func (f *concurrentUpdate) Filter(ctx context.Context, resource *prop.Resource) error {
+	return nil
+}
+
+func (f *concurrentUpdate) FilterRef(ctx context.Context, resource *prop.Resource, ref *prop.Resource) error {
+	updated := ref.Clone()
+	if err := crud.Replace(updated, "meta.version", "W/\"concurrent\""); err != nil {
+		return err
+	}
+	return f.database.Replace(ctx, updated)
+}
+
 func (s *PatchServiceTestSuite) mustServiceProviderConfig(filePath string) *spec.ServiceProviderConfig {
 	f, err := os.Open(s.resourceBase + filePath)
 	s.Require().Nil(err)
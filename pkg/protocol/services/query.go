@@ -9,6 +9,7 @@ import (
 	"github.com/imulab/go-scim/pkg/protocol/crud"
 	"github.com/imulab/go-scim/pkg/protocol/db"
 	"github.com/imulab/go-scim/pkg/protocol/log"
+	"strings"
 )
 
 type (
@@ -16,18 +17,29 @@ type (
 		Filter     string
 		Sort       *crud.Sort
 		Pagination *crud.Pagination
+		// Cursor based pagination, which may not be used along with Pagination.
+		Cursor     *crud.CursorPagination
 		Projection *crud.Projection
 	}
 	QueryResponse struct {
 		TotalResults int
 		StartIndex   int
 		ItemsPerPage int
-		Resources    []*prop.Resource
+		// Cursor to the next page when the request is cursor paginated, or empty if there are no more resources.
+		NextCursor string
+		Resources  []*prop.Resource
 	}
 	QueryService struct {
-		Logger                log.Logger
-		Database              db.DB
+		Logger   log.Logger
+		Database db.DB
+		// Databases of multiple resource types. When not empty, the service works in the multi-type mode, in which the
+		// query is fanned out to every database, and the results are merged with a consistent sort and pagination.
+		// Database is ignored in this mode.
+		Databases             []db.DB
 		ServiceProviderConfig *spec.ServiceProviderConfig
+		// Optional resource type which the filter is validated against before being executed. Filters are only
+		// checked for syntax when absent, which is the case in the multi-type mode.
+		ResourceType *spec.ResourceType
 	}
 )
 
@@ -42,16 +54,45 @@ func (s *QueryService) checkSupport(request *QueryRequest) error {
 			return errors.InvalidRequest("sort is not supported")
 		}
 	}
+	if request.Cursor != nil {
+		if !s.ServiceProviderConfig.Pagination.Cursor {
+			return errors.InvalidRequest("cursor pagination is not supported")
+		}
+		if len(s.Databases) > 0 {
+			return errors.InvalidRequest("cursor pagination is not supported across resource types")
+		}
+	}
 	return nil
 }
 
 func (s *QueryService) QueryResource(ctx context.Context, request *QueryRequest) (resp *QueryResponse, err error) {
+	var iterator db.Iterator
+	resp, iterator, err = s.QueryResourceIterator(ctx, request)
+	if err != nil {
+		return
+	}
+
+	resp.Resources, err = db.Collect(iterator)
+	if err != nil {
+		s.Logger.Error("failed to query resource: %s", err.Error())
+		return
+	}
+	resp.ItemsPerPage = len(resp.Resources)
+
+	return
+}
+
+// Query resources like QueryResource, but return an iterator over the resources instead of setting the Resources and
+// ItemsPerPage of the response, so that the resources can be written out as they are iterated. The iterator must be
+// closed after use. Resources are only iterated from the database when the request is paginated by startIndex on a
+// single resource type; they are collected ahead otherwise.
+func (s *QueryService) QueryResourceIterator(ctx context.Context, request *QueryRequest) (resp *QueryResponse, iterator db.Iterator, err error) {
 	err = s.checkSupport(request)
 	if err != nil {
 		return
 	}
 
-	err = request.ValidateAndDefault()
+	err = s.ValidateAndDefault(request)
 	if err != nil {
 		return
 	}
@@ -61,10 +102,42 @@ func (s *QueryService) QueryResource(ctx context.Context, request *QueryRequest)
 		resp.StartIndex = request.Pagination.StartIndex
 	}
 
-	resp.TotalResults, err = s.Database.Count(ctx, request.Filter)
-	if err != nil {
+	for _, database := range s.databases() {
+		var n int
+		n, err = database.Count(ctx, request.Filter)
+		if err != nil {
+			return
+		}
+		resp.TotalResults += n
+	}
+	if request.Pagination != nil && request.Pagination.Count == 0 {
+		iterator = db.Iterate(nil)
 		return
-	} else if request.Pagination != nil && request.Pagination.Count == 0 {
+	}
+
+	if request.Cursor != nil {
+		pagination := s.ServiceProviderConfig.Pagination
+		if request.Cursor.Count == 0 {
+			request.Cursor.Count = pagination.DefaultPageSize
+			if request.Cursor.Count <= 0 {
+				request.Cursor.Count = s.ServiceProviderConfig.Filter.MaxResults
+			}
+		}
+		// pages are cut to the maximum page size, rather than rejected
+		if pagination.MaxPageSize > 0 && request.Cursor.Count > pagination.MaxPageSize {
+			request.Cursor.Count = pagination.MaxPageSize
+		}
+		if request.Cursor.Count > s.ServiceProviderConfig.Filter.MaxResults {
+			err = errors.TooMany("request would return too many results")
+			return
+		}
+		var resources []*prop.Resource
+		resources, resp.NextCursor, err = s.Database.QueryCursor(ctx, request.Filter, request.Sort, request.Cursor, request.Projection)
+		if err != nil {
+			s.Logger.Error("failed to query resource: %s", err.Error())
+			return
+		}
+		iterator = db.Iterate(resources)
 		return
 	}
 
@@ -74,16 +147,109 @@ func (s *QueryService) QueryResource(ctx context.Context, request *QueryRequest)
 		return
 	}
 
-	resp.Resources, err = s.Database.Query(ctx, request.Filter, request.Sort, request.Pagination, request.Projection)
+	if len(s.Databases) > 0 {
+		var resources []*prop.Resource
+		if resources, err = s.queryAll(ctx, request); err == nil {
+			iterator = db.Iterate(resources)
+		}
+	} else {
+		iterator, err = s.Database.QueryIterator(ctx, request.Filter, request.Sort, request.Pagination, request.Projection)
+	}
 	if err != nil {
 		s.Logger.Error("failed to query resource: %s", err.Error())
 		return
 	}
-	resp.ItemsPerPage = len(resp.Resources)
 
 	return
 }
 
+func (s *QueryService) databases() []db.DB {
+	if len(s.Databases) > 0 {
+		return s.Databases
+	}
+	return []db.DB{s.Database}
+}
+
+// Query all databases in the multi-type mode. Each database is asked for the first startIndex+count-1 results in the
+// sort order, so that the requested page can be cut from the merged and re-sorted results. When no sort is requested,
+// results are sorted by id, so that pages are consistent across requests.
+func (s *QueryService) queryAll(ctx context.Context, request *QueryRequest) ([]*prop.Resource, error) {
+	sort := request.Sort
+	if sort == nil {
+		sort = &crud.Sort{By: "id", Order: crud.SortAsc}
+	}
+
+	var top *crud.Pagination
+	if request.Pagination != nil {
+		top = &crud.Pagination{
+			StartIndex: 1,
+			Count:      request.Pagination.StartIndex - 1 + request.Pagination.Count,
+		}
+	}
+
+	// the sort attribute must survive the projection, so that the merged results can be sorted
+	projection := request.Projection
+	if projection != nil {
+		if len(projection.Attributes) > 0 {
+			projection = &crud.Projection{
+				Attributes: append(append([]string{}, projection.Attributes...), sort.By),
+			}
+		} else if len(projection.ExcludedAttributes) > 0 {
+			by := strings.ToLower(sort.By)
+			projection = &crud.Projection{ExcludedAttributes: make([]string, 0, len(projection.ExcludedAttributes))}
+			for _, excluded := range request.Projection.ExcludedAttributes {
+				if e := strings.ToLower(excluded); e != by && !strings.HasPrefix(by, e+".") {
+					projection.ExcludedAttributes = append(projection.ExcludedAttributes, excluded)
+				}
+			}
+		}
+	}
+
+	merged := make([]*prop.Resource, 0)
+	for _, database := range s.Databases {
+		resources, err := database.Query(ctx, request.Filter, sort, top, projection)
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, resources...)
+	}
+
+	if err := sort.Sort(merged); err != nil {
+		return nil, err
+	}
+
+	if request.Pagination != nil {
+		lb := request.Pagination.StartIndex - 1
+		if lb > len(merged) {
+			lb = len(merged)
+		}
+		ub := lb + request.Pagination.Count
+		if ub > len(merged) {
+			ub = len(merged)
+		}
+		merged = merged[lb:ub]
+	}
+
+	return merged, nil
+}
+
+// Validate and default the request, and validate its filter against the resource type, if any.
+func (s *QueryService) ValidateAndDefault(request *QueryRequest) error {
+	if err := request.ValidateAndDefault(); err != nil {
+		return err
+	}
+	if s.ResourceType != nil {
+		filter, err := expr.CompileFilter(request.Filter)
+		if err != nil {
+			return err
+		}
+		if err := crud.Validate(filter, s.ResourceType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (q *QueryRequest) ValidateAndDefault() error {
 	if len(q.Filter) == 0 {
 		q.Filter = "id pr"
@@ -93,10 +259,16 @@ func (q *QueryRequest) ValidateAndDefault() error {
 		}
 	}
 	if q.Pagination != nil {
+		if q.Cursor != nil {
+			return errors.InvalidValue("only one of startIndex and cursor may be used")
+		}
 		if q.Pagination.StartIndex <= 0 {
 			q.Pagination.StartIndex = 1
 		}
 	}
+	if q.Cursor != nil && q.Cursor.Count < 0 {
+		return errors.InvalidValue("count must not be negative")
+	}
 	if q.Sort != nil {
 		if len(q.Sort.By) == 0 {
 			q.Sort.By = "id"
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
@@ -32,6 +33,81 @@ func (s *QueryServiceTestSuite) TestQuery() {
 	_ = s.mustSchema("/user_schema.json")
 	resourceType := s.mustResourceType("/user_resource_type.json")
 	spc := s.mustServiceProviderConfig("/service_provider_config.json")
+	_ = s.mustSchema("/group_schema.json")
+	groupResourceType := s.mustResourceType("/group_resource_type.json")
+
+	multiTypeService := func(t *testing.T) *QueryService {
+		userDatabase := db.Memory()
+		for _, f := range []string{
+			"/user_003.json",
+			"/user_002.json",
+			"/user_001.json",
+			"/user_004.json",
+			"/user_005.json",
+			"/user_009.json",
+			"/user_010.json",
+			"/user_008.json",
+			"/user_007.json",
+			"/user_006.json",
+		} {
+			err := userDatabase.Insert(context.Background(), s.mustResource(f, resourceType))
+			require.Nil(t, err)
+		}
+		groupDatabase := db.Memory()
+		for _, f := range []string{
+			"/group_002.json",
+			"/group_001.json",
+		} {
+			err := groupDatabase.Insert(context.Background(), s.mustResource(f, groupResourceType))
+			require.Nil(t, err)
+		}
+		return &QueryService{
+			Logger:                log.None(),
+			Databases:             []db.DB{userDatabase, groupDatabase},
+			ServiceProviderConfig: spc,
+		}
+	}
+	validatingService := func(t *testing.T) *QueryService {
+		database := db.Memory()
+		err := database.Insert(context.Background(), s.mustResource("/user_001.json", resourceType))
+		require.Nil(t, err)
+		return &QueryService{
+			Logger:                log.None(),
+			Database:              database,
+			ServiceProviderConfig: spc,
+			ResourceType:          resourceType,
+		}
+	}
+	cursorService := func(t *testing.T) *QueryService {
+		database := db.Memory()
+		for _, f := range []string{
+			"/user_003.json",
+			"/user_002.json",
+			"/user_001.json",
+			"/user_004.json",
+			"/user_005.json",
+			"/user_009.json",
+			"/user_010.json",
+			"/user_008.json",
+			"/user_007.json",
+			"/user_006.json",
+		} {
+			err := database.Insert(context.Background(), s.mustResource(f, resourceType))
+			require.Nil(t, err)
+		}
+		return &QueryService{
+			Logger:                log.None(),
+			Database:              database,
+			ServiceProviderConfig: spc,
+		}
+	}
+	ids := func(resources []*prop.Resource) []string {
+		result := make([]string, 0, len(resources))
+		for _, r := range resources {
+			result = append(result, r.ID())
+		}
+		return result
+	}
 
 	tests := []struct {
 		name       string
@@ -39,6 +115,39 @@ func (s *QueryServiceTestSuite) TestQuery() {
 		request    *QueryRequest
 		expect     func(t *testing.T, response *QueryResponse, err error)
 	}{
+		{
+			name:       "validated filter",
+			getService: validatingService,
+			request: &QueryRequest{
+				Filter: `userName pr and active eq true`,
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 1, response.TotalResults)
+			},
+		},
+		{
+			name:       "filter with nonexistent attribute",
+			getService: validatingService,
+			request: &QueryRequest{
+				Filter: `nonexistent eq "x"`,
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidFilter, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name:       "filter with operator not applicable to boolean",
+			getService: validatingService,
+			request: &QueryRequest{
+				Filter: `active gt true`,
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidFilter, err.(*errors.Error).Type)
+			},
+		},
 		{
 			name: "simple count",
 			getService: func(t *testing.T) *QueryService {
@@ -215,6 +324,180 @@ func (s *QueryServiceTestSuite) TestQuery() {
 				assert.Equal(t, "user006", userNames[1])
 			},
 		},
+		{
+			name: "multi-type filter across resource types",
+			getService: multiTypeService,
+			request: &QueryRequest{
+				Filter: "displayName sw \"Group\" or userName eq \"user001\"",
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 3, response.TotalResults)
+				assert.Equal(t, []string{
+					"5be76be1-6248-4580-9227-eee45d6ec56e",
+					"8f33f3e4-33ff-4549-adc1-12694c9cbdc3",
+					"a5866759-32ca-4e2a-9808-a0fe74f94b18",
+				}, ids(response.Resources))
+			},
+		},
+		{
+			name: "multi-type filter on meta.resourceType",
+			getService: multiTypeService,
+			request: &QueryRequest{
+				Filter: "meta.resourceType eq \"Group\"",
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 2, response.TotalResults)
+				assert.Equal(t, []string{
+					"5be76be1-6248-4580-9227-eee45d6ec56e",
+					"8f33f3e4-33ff-4549-adc1-12694c9cbdc3",
+				}, ids(response.Resources))
+			},
+		},
+		{
+			name: "multi-type sort by attribute not projected",
+			getService: multiTypeService,
+			request: &QueryRequest{
+				Filter:     "displayName sw \"Group\" or userName eq \"user001\"",
+				Sort:       &crud.Sort{By: "displayName", Order: crud.SortDesc},
+				Projection: &crud.Projection{Attributes: []string{"userName"}},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{
+					"a5866759-32ca-4e2a-9808-a0fe74f94b18",
+					"5be76be1-6248-4580-9227-eee45d6ec56e",
+					"8f33f3e4-33ff-4549-adc1-12694c9cbdc3",
+				}, ids(response.Resources))
+			},
+		},
+		{
+			name: "multi-type sort by excluded attribute",
+			getService: multiTypeService,
+			request: &QueryRequest{
+				Filter:     "displayName sw \"Group\" or userName eq \"user001\"",
+				Sort:       &crud.Sort{By: "displayName", Order: crud.SortAsc},
+				Projection: &crud.Projection{ExcludedAttributes: []string{"displayName"}},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, []string{
+					"8f33f3e4-33ff-4549-adc1-12694c9cbdc3",
+					"5be76be1-6248-4580-9227-eee45d6ec56e",
+					"a5866759-32ca-4e2a-9808-a0fe74f94b18",
+				}, ids(response.Resources))
+			},
+		},
+		{
+			name: "multi-type paginate",
+			getService: multiTypeService,
+			request: &QueryRequest{
+				Pagination: &crud.Pagination{
+					StartIndex: 4,
+					Count:      3,
+				},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 12, response.TotalResults)
+				assert.Equal(t, []string{
+					"42c1f39d-136f-4b47-bc56-e3775b22a9b0",
+					"5be76be1-6248-4580-9227-eee45d6ec56e",
+					"66fd936f-c0e2-428e-b626-5bb164b57518",
+				}, ids(response.Resources))
+			},
+		},
+		{
+			name: "multi-type paginate beyond results",
+			getService: multiTypeService,
+			request: &QueryRequest{
+				Pagination: &crud.Pagination{
+					StartIndex: 20,
+					Count:      3,
+				},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 12, response.TotalResults)
+				assert.Empty(t, response.Resources)
+			},
+		},
+		{
+			name:       "cursor paginate",
+			getService: cursorService,
+			request: &QueryRequest{
+				Filter: "userName pr",
+				Sort:   &crud.Sort{By: "userName"},
+				Cursor: &crud.CursorPagination{Count: 2},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, 10, response.TotalResults)
+				assert.Equal(t, 2, response.ItemsPerPage)
+				assert.Equal(t, []string{
+					"a5866759-32ca-4e2a-9808-a0fe74f94b18",
+					"23d22b2d-4fc4-49f9-90fb-ee10882c69ed",
+				}, ids(response.Resources))
+				assert.NotEmpty(t, response.NextCursor)
+			},
+		},
+		{
+			name:       "cursor paginate with default page size",
+			getService: cursorService,
+			request: &QueryRequest{
+				Cursor: &crud.CursorPagination{},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, response.Resources, 4)
+			},
+		},
+		{
+			name:       "cursor paginate beyond maximum page size",
+			getService: cursorService,
+			request: &QueryRequest{
+				Cursor: &crud.CursorPagination{Count: 8},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.Nil(t, err)
+				assert.Len(t, response.Resources, 5)
+			},
+		},
+		{
+			name:       "cursor paginate with start index",
+			getService: cursorService,
+			request: &QueryRequest{
+				Pagination: &crud.Pagination{StartIndex: 1, Count: 2},
+				Cursor:     &crud.CursorPagination{Count: 2},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidValue, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name:       "cursor paginate with invalid cursor",
+			getService: cursorService,
+			request: &QueryRequest{
+				Cursor: &crud.CursorPagination{Cursor: "foo", Count: 2},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidCursor, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name:       "multi-type cursor paginate",
+			getService: multiTypeService,
+			request: &QueryRequest{
+				Cursor: &crud.CursorPagination{Count: 2},
+			},
+			expect: func(t *testing.T, response *QueryResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypeInvalidRequest, err.(*errors.Error).Type)
+			},
+		},
 		{
 			name: "too many",
 			getService: func(t *testing.T) *QueryService {
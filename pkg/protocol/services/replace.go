@@ -7,6 +7,7 @@ import (
 	"github.com/imulab/go-scim/pkg/core/spec"
 	"github.com/imulab/go-scim/pkg/protocol/db"
 	"github.com/imulab/go-scim/pkg/protocol/event"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/imulab/go-scim/pkg/protocol/services/filter"
 )
@@ -29,12 +30,21 @@ type (
 		Database              db.DB
 		ServiceProviderConfig *spec.ServiceProviderConfig
 		Event                 event.Publisher
+		// Optional lock which serializes the replacement with other updates to the same resource.
+		Lock lock.Lock
 	}
 )
 
 func (s *ReplaceService) ReplaceResource(ctx context.Context, request *ReplaceRequest) (*ReplaceResponse, error) {
 	s.Logger.Debug("received replace request [id=%s]", request.ResourceID)
 
+	if s.Lock != nil {
+		if err := lockResource(ctx, s.Lock, request.ResourceID); err != nil {
+			return nil, err
+		}
+		defer s.Lock.Unlock(request.ResourceID)
+	}
+
 	ref, err := s.Database.Get(ctx, request.ResourceID, nil)
 	if err != nil {
 		return nil, err
@@ -54,7 +64,7 @@ func (s *ReplaceService) ReplaceResource(ctx context.Context, request *ReplaceRe
 
 	// Only replace when version is bumped
 	if request.Payload.Version() != ref.Version() {
-		err = s.Database.Replace(ctx, request.Payload)
+		err = s.Database.CompareAndReplace(ctx, request.Payload, ref.Version())
 		if err != nil {
 			s.Logger.Error("resource [id=%s] failed to save into persistence: %s", request.ResourceID, err.Error())
 			return nil, err
@@ -3,10 +3,12 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"github.com/imulab/go-scim/pkg/core/errors"
 	scimJSON "github.com/imulab/go-scim/pkg/core/json"
 	"github.com/imulab/go-scim/pkg/core/prop"
 	"github.com/imulab/go-scim/pkg/core/spec"
 	"github.com/imulab/go-scim/pkg/protocol/db"
+	"github.com/imulab/go-scim/pkg/protocol/lock"
 	"github.com/imulab/go-scim/pkg/protocol/log"
 	"github.com/imulab/go-scim/pkg/protocol/services/filter"
 	"github.com/stretchr/testify/assert"
@@ -15,6 +17,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestReplaceService(t *testing.T) {
@@ -141,6 +144,69 @@ func (s *ReplaceServiceTestSuite) TestReplace() {
 				assert.NotNil(t, err)
 			},
 		},
+		{
+			name: "replace a resource modified concurrently",
+			setup: func(t *testing.T) *ReplaceService {
+				memoryDB := db.Memory()
+				require.Nil(t, memoryDB.Insert(
+					context.Background(),
+					s.mustResource("/user_000.json", resourceType)),
+				)
+				return &ReplaceService{
+					Logger: log.None(),
+					Filters: []filter.ForResource{
+						&concurrentUpdate{database: memoryDB},
+						filter.ClearReadOnly(),
+						filter.CopyReadOnly(),
+						filter.Password(10),
+						filter.Validation(memoryDB),
+						filter.Meta(),
+					},
+					Database: memoryDB,
+					ServiceProviderConfig:spc,
+				}
+			},
+			getRequest: func() *ReplaceRequest {
+				resource := s.mustResource("/user_001.json", resourceType)
+				return &ReplaceRequest{
+					ResourceID: "3cc032f5-2361-417f-9e2f-bc80adddf4a3",
+					Payload:    resource,
+				}
+			},
+			expect: func(t *testing.T, resp *ReplaceResponse, err error) {
+				assert.NotNil(t, err)
+				assert.Equal(t, errors.TypePreCondition, err.(*errors.Error).Type)
+			},
+		},
+		{
+			name: "replace a resource locked by a concurrent operation",
+			setup: func(t *testing.T) *ReplaceService {
+				memoryDB := db.Memory()
+				require.Nil(t, memoryDB.Insert(
+					context.Background(),
+					s.mustResource("/user_000.json", resourceType)),
+				)
+				locks := lock.Default(10 * time.Millisecond)
+				require.Nil(t, locks.Lock(context.Background(), "3cc032f5-2361-417f-9e2f-bc80adddf4a3"))
+				return &ReplaceService{
+					Logger:                log.None(),
+					Database:              memoryDB,
+					ServiceProviderConfig: spc,
+					Lock:                  locks,
+				}
+			},
+			getRequest: func() *ReplaceRequest {
+				return &ReplaceRequest{
+					ResourceID: "3cc032f5-2361-417f-9e2f-bc80adddf4a3",
+					Payload:    s.mustResource("/user_001.json", resourceType),
+				}
+			},
+			expect: func(t *testing.T, resp *ReplaceResponse, err error) {
+				require.NotNil(t, err)
+				assert.Equal(t, 503, err.(*errors.Error).Status)
+				assert.Equal(t, errors.TypeUnavailable, err.(*errors.Error).Type)
+			},
+		},
 	}
 
 	for _, test := range tests {